@@ -696,6 +696,57 @@ Nf0O1oAZj5Slh8rMCkTM/l8AAAD//16/SW7QjwAA
 `,
 	},
 
+	"/resources/cloudformation/spec.json": {
+		local:   "resources/cloudformation/spec.json",
+		size:    23028,
+		modtime: 1792336621,
+		compressed: `
+H4sIAAAAAAAC/9RcQXPbOg6+91d4cu57M7tvdg++OXbynid242e5zRmmEBkbilRJyqm60/++Q8l27aSO
+LUBNuoc2B4sQCYIfgA+g/vuu17uYo7elU5gUqOieFASy5hM6T9Zc9HsXvgAX4LdlaVKN6W//uHi/P2pR
+Fegv+r0oqte7GNwl/f6goD8h4CNU/f4IC22rHE3YPRQfC8HRsgx7Q3u9Xu/i+9PjNP7ybfPTt/fbkTNn
+C3SBjo8cggFXJRgCmezwqXren0tyGKXfg/a4++3b+31hXjkqQqOB9uPn6MOgoGYNR0YHV/54cBIgQ+kM
+aiEfIMfzR7/b//vt/ZH9nGJY2fT4Xp7eqUFBN1jtzaP96gZlWFlHX2tTTZQt0MvlREsWSUE35i3nrxCK
+Z3o9217GJmDmgG0qzZvn6AtrPFORtwU2M2hncgdH5nOJPkxtitpLJMzAQY4BnUjKJ9CUQrDcLd3CIw8C
+2uPHeYd3O6tzoPhwBecD8QzcDr7brnsGYTUDF34xnUW5Z6rs+xQO5mZt4Oqzgctk52xZ5nhJ0SdOMSU4
+dNithNi0Yg9M/phYxQepobYGr53N38Sfj8jDUuPVF1RlwEFBVyYtLJnAkrYdPLTmnrJSAN3XQPrW3IEz
+7FBnSobyMh/avHDoY8yX0FeekUXsZg1kOw0h1s+sJsUz6QW00fd5SFPHbKLISin0fmKzTfDLO2mgVjjU
+pQ/orkw0+1Qsh21SB7E8Ezgo5gRRXXVug0yH/jQzeX0MsqqMr3+SnnHjPVF+JM9v2Ee+3cHbH+hAkckk
+Jv0JHMXRnZz8obZlem1dXm9ovz8sfbD56QjtNAwk6NakcGEf0HQQDD2daBJAPUjm98GGHc8wmH/wbwH9
+fDPCvNAQ8ON8wjH+BeVoyzA2UzLPAkiJJd1BUKt+f6DB5efEqgNnWsahKu6Xl5yfenIbOXwBUiCthbDx
+JwZK4Mhb0+S81nHMYAQB6hjQL+zTLWsTluZoPFubV2vQJQSc2McE8kLj0JYmzNApNIE0SoSSNTN0ZFPP
+Uc/Vl4AmxTSJ3s4HUqypjI0v7+9JxQAgKlxieVMMjhTbaprhnh0d+wIU7823N5JlN3vIZSAFe7dYOfQr
+q1kRxsIhhCl5TyaLG8+awUdDoWt0HoFfLS04EX+6E3IiH38BfDYCuuKHR5WB3I4u+/1FdA5t3M+BwTiE
+vL1f2r5hhPdkiG/rl6Q1mYydxQ6taWZi3dh4ylbBH5RUmFmDxhpMnQ1YH2SJA/5T2yXoBJU1KbhqbFL8
+wiSDxnlhXUieV45Y0iLDpVaYA8eeb8igJ9/Yj3wukafqRke3ZoQ5mHSxcrbMVkUZmDQFmTA2MXSco7Jr
+dJV8lTNn1xRjB5ROb5uyCNiUJLmSr6ir/a8xbKjBe/7wN0hvKceFndC6g9N4By7nGMVxD3G1RhN8vz8v
+27mHFuVfWVJQT/Cy9OyNqwXMIAR05nVJ0LnVOGC+NIJuWkZme8v+smM9rr27DEMnafB4MO33p2Agw/QZ
+Fh21tObJV7e3P50tC2Yqsr9EAXUeVgLSfMtGsip1VjMd6kffiu85YSvnG0nbmp1gd+XqPcswfvnNifNg
+JhJxaOsqq/dljnGkfAOk2HBwwAeOy1jAl6SB9JGkxMjHCXQ51e/3l7asY2n+gSCmTcYNfaVY7Lg9b/KT
+fr+JUH9K/CPouwlovtN1f9mSyagnK3BpzSDyikJRNVdGuaoQZgAxgR9hANL+Tbd9AvkyhUjJE/izNj0+
++OqRyHVp1BmdW0d3bjv+dEnyqAjuq/dy2KE1qnQOjaqatgomXJSxKNpWwmkbqLODhiyZQlEcFrOPGsTz
+UT/wdq09XQ5frdn4mBu4f4C91wh0dxkpTnah/5I8qlDLuDVbk7pyzjqWuBH6QKb2eoIlbYOA0WU3KpJQ
+d3sz4CZ716QDuqGjgI7gTcFi223Kb0a7yf0NVlxVTOFL7Hqq7Y1MdkcmtY9j07B+XiIyMnQuHWTYkbTg
+qkEImBdBUrjyAquNlXatUW/apK9BBeap3MPrmdUanWBWf5dYMk0nUaAZKL8vAfX9BkH3zqVUVIdgvAGK
+uh/soM3Qc/kdFx3jzHqSXEk4EBIZSx8gL16ZKLUFtxK7KPOllsDF6UBhC5DcGpqBotbzFmBPPvD7oCh0
+dXta0u+3RaB8e+ciQCh9y8DDqRXFIlbpmAd3aFOW14njEsrM7tBz3cYIIZ1g5HglUYWUqjZrctacYCde
+GF+sMEcHOgnWHXactoolMKl8wLzRhP+Z4cQLN2cgXkpzzAomZBKMlUUgE6i4vWsTm2Uy/zXF3LqKHazP
+QD1Axr+NMEdVOk9rnFhbMCV4dOu9tC80vflsD/eEbjwbWualCcSlXpqxjfevby4KHP4Wrl+/6mjLIGnI
+FSz6U6G6T9W/85ayVvzAJEP2AsBTHbw/P0/76HS83cg+6TNHRlEBmkfvbAbfumw8ksXBJ3jJkxLaoPxp
+G3vOlR0N8rY78YNgb0/K+VFYHQut4J//+vebBC9Sw+ye8mtAuG3zygt7bDNf/18XAX9OswME+N4QJui7
+uSbUad3hJKqyNJEQ817MVlP8jputBHkNZGxGUL1x1aA2n4a1aQg7iRtqJLAV0ww/3dVy9LieuTVHxzd6
+WDgw/n57A8V3cKel0XJSLndoJtf1Huc84GlrRL55JRtd/8+3u3Ur0/EdTv7o9y9L9YCBSaWMbA6081MH
+v5Qxdwb1cPyROWZkDejjT9zh0tPuGlGbazgKdbx4gvLrwzt+MDireRIM6Crs+GURydjslrAE3AgR3O5x
+Xq7X+EUOrSlDExaEbpfkiNQzNjExsK7qQNaE7lFVSndgQwdkRCn7DAmkEKBpv+1CnOvGLvevK8rndbv8
+D6owseqhS1mSyuLto0HnV1RsgIDZt1YuNakGUS51J8ubY6E70zub4thkWZ3Y+Ab0mYLOcXanehpPO5hn
+TrPXrvGwdefcC8v6kBwGaT+pdVxTvFMhyKNEnwPZRF38t8dU0CrL4ljm8DhF7+svjjVqYB7V1NFacgWk
+iZjYOAGS7ds3MUk3fV1abBP7nzD9Wt45Nr//4mcT2kWf7Sp1ot2MriReqACP6QjTcgfkPNKpK75ja+NN
++XITunC/CBSNTpBq3dun29trQ7hMIX5YhU+7xHooxFKs5FMiR7C590pVfH5nL6/0cM5xlTtgeQP6yRaH
+s1Ho76TfrzttmEl0PfZH6W/9w0en25LXncLKvoT6K5JcUIFK0usVseCpklsiye6y3oRyClxMiThbf5qz
+9Ni0g0uW1QFIbbrgNhEK/8Nkzfgnre78tjMB0ayQ1riZzx1QiAVUiZI3gddAa/soir6k8Vvy2W/a2BKP
+knSUn6iRpyVpClXrqvQ5CPgrAPvJpseXgP1d/Pft3f8GAEto4kP0WQAA
+`,
+	},
+
 	"/resources/describe/template.html": {
 		local:   "resources/describe/template.html",
 		size:    7729,
@@ -863,53 +914,91 @@ AP//YP+tPQcGAAA=
 
 	"/resources/index.js": {
 		local:   "resources/index.js",
-		size:    7809,
-		modtime: 1448557953,
+		size:    16437,
+		modtime: 1792337193,
 		compressed: `
-H4sIAAAJbogA/6xZfW/jNtL/P5+CDdBKwjpyttsWWxvpwkm82fRJbMN20H2QCwxZom1tJFEnSkl8rb/7
-zZCURMryvuEMJJbImeFwXn6coZ+8jBR5GJEzktF/F2FGbQvfLad/hHMrrs+seDm+yfNUn8H3ci718o0+
-h+/lnL8Jo2CRZsyn3BBtTJTUV+ObwehqcTEezeaD0XymM7jdNYu8ZH3is4TnXpJz9xNnCbIedbvz8eW4
-R2aUknAllOW9bnfFsiLmrvcMf7H3H5a4Pou7MSzorSlwp9479XJ9efbbmzdvT38GUWTjcbKkNCFFGng5
-DchzCBtM6DMJExAZe3nIkiOlukuTJ3cymH8AXfeGXhGr14WNdXOPP1qgqNjlbDKYzgeL8+vRYPr/i9Hg
-dgi81iz1stxzIy9eBh7oG/z2i9VvoS/XAiO7n1iY2FY3j1Or0yJWWfV28PH69u52MR0CyV8jMO/daA4i
-fu1LfSbT8cfr4eXidnx5dzNEo99b/A1ItHjC8ctLwzUY4tnbWg+KR/piUvk1KaJIRZAXRhesSHIYPQXq
-VZH4aDASe490Ct6kPLdR+w6hTzTJOwQcmtOX3CF/HxGCIjJJdc6CLQjBUSJpe4pFjCi2XvkAgztYT4rg
-eRYm63AVgvvOyJ+z8cgth7a2Jt+pOISUJL+hyVqE83mxWtHMXW5zKsdsTSYYpchXJ28x+iQ7S3GTvNJ3
-wzioZkXM9yJ8tqTSKctg+Hf4qHcwRE/8l+8xzTcsAMbJeDZXPBvqBTTjPSWZEOtC6noy36bU6qGD0ij0
-RVx2RVJ0mpRyC1bP3Kag2hmGA9vAHjCDXGUmW22tQ0pXggG5o7SBR5fTfJj4LADrIJqslFmkwKX0omX1
-K3qQYEFmeZYm0t8UyaNTbVFwvTojYlhy7hxTAk0CXUDNCyks4eA8C4M1JfmGkhEL6J8z4iWBCl3yzLIo
-gETfQlb7UYHKC0o5XYv6MJ9PIJy8vOAEwwzIBR3NMobm4inYRsAOeCFjaRZCqriEzDchJzTxlhHltTTk
-HEyuyZXMJxCW03UmPFfLyhkp4AtRLvCySuOZUGKOOmR0TV/SWixgkr+hHDljLwFAM9QG14DNFLV0slzp
-EpyAEbvrq0l9wkU2gcDgYSHoAgZaKdU6Z3sA7qIaC3ie380W8+HH+b0pzc3ZTGSV7Ty0Slaxr9RQb62U
-0h9nxDZXIH+ckV9OTx3yTsZUjxRJQFdhQoNWMfBSRLlasCn9Xc0MglBeKSPPtlX8aRF4saH+Y5lzJzmk
-K5wscIZQSPkMXOzlOY3TvGKEKLLb1HEqinqRg4oLtBPy22X1KxE79bQjPgYQsalj7mI0nhyZpBg+YIxD
-9sFTcojP9h7m1tSOA9aTJ4YZkyAVZb8Tk0CjM5W0Cu3dgCXUBuqOoHJ0jJD/Ab4kTqA6OlJUmzRFdcSq
-DQHPWZhTHfmrGYAfG1528jyM2BqUr5Zgy08Lli0kn3OEy6FrMQDYqjFLfjgDeIQx6ucWOloqZxBhksZ8
-3TNHRdrulGxjBhPAf6yFYc3EIirdZDslH404rWhwGy9Y34gjs8SEsdDLfaRb3tDbBdqh52/s6oS3Kbz+
-H93WQaTk3asJSPe7NKXZhQex6TzAMobIkuzBBPxSebBxM6qUfEftaCdqQXLLniTqr8JIoClWSPiNycYy
-LwujLeL/I/EyqFQCUfR9T83469s3v78+FQFAE15k9IrdAFKfh4mXbfV4gCIgWgqPSMsAXBzp2YxbswRY
-oLNBB7IUMuBshyJyvwCscnglsW62TXz7M2RwQl/HYCg8mypltIAv87uCgRbtWLpF5dRhJPWzHD2FwVIx
-nrBnosVwZa0MpX5K3O6PnPzI+3Cexywgr17gpSpSDn/2S9pv5UErfA9PtS+jT3HpC/Vttc0aUYjEIZ4H
-rMh1BMW8hKl29DZzEsn62my9Ypjbr1swu8refcnoL6lMfb6W9WqrpDIijH2UhLsqQnaq+PczCnH0nmXP
-UJ3QTA90rGTrQn4lSebsqtEu1FhstgAVGKHlfjCajNqu+6lmt1SBZK9HMV0JmfyctORMB7ofdHORU+x9
-Tk54uE68CJ5LzjQMHjoAkLJ3aFnLVcZvq3OXxcrZcxYMft5T2vPeSuCz/+1K1Qu6MKdZDDZGiR8g6CPT
-3cJ9Iy+mTqMwyYssqcmevKhokDTj30AM2QsDRvTg71/QzpBqIfMI6TRbO7mSY6QS0XpSaClem3MYafX8
-H+3dclP3z+SnTBX9rb1XNvRrzRMbCQ2QVtI1dx2KC73w2XdhOeccjCvBD1s7xI5TOne6z9eak9LeBxK7
-TZHHMIpsc/utabF3KTGFXmHbErLmcqXeGY2haLgJORTquMHZ9dXdbPozbOOgUEOpAx5swFur43TTfcuy
-O+PkxsNAnje4JPnpJx1T6+Phi5cwff1OoM7k1u31qxOBQzU8LdvXg/AOi4lqXXY0qFLdNultqXFPuEpO
-ynH9WiFQrava13A6HU97hELvoDeVertXlgGQ2Hc381mv1IT8809NdKQ5p1zWxd3tbwWWqijeD65vhpda
-x+LO7i4uhrNZRyhaFdxlfVXaqgxGUWGJPQhTYp1VSpIlYMWhbc4sei8KnrMYyFiR+ZRcsGQVrgt1tUBf
-8OKJHzWu+g6U8JK3PsYlN6Jvs7T7kRvrIFDX/KieWvi+FvHwNce/3k6jBoO/jLtgqM5PePBoVp8wKBdG
-iIU2FHhcOWDrZzWY6nolugPRI5GQ442OvOolUJxCiEI/aOUkoBHFa09tCUjVl+3c448I4/fVjUU97KYF
-162J974X5zrkyAvzzIu5Fr34maE2aCB1yemKgeugDTK+ZrslbUWH/dNaYKE6eU15fsSK4H15va0LNSbs
-Sp62hsnrBpT7WbikYgfcltuFg0QaQ8ev2ifjBHqy0uSiV6s9JN2lXCT9BSZfZ4iamndYIha8FKunahd1
-qHWkOG26mYX4ERDaXrDrINeGbI2D2izUDUnyhnpP10P6yVDgADhfoLg/fQAM+nvXb1trVl7SNYVIZjUN
-YHh83G+c1zo/3lYc300uB/Mh1Ea3k5shPtwMB6O7yeJ6tACIuQKInR07mhDzhDcvy+QHEbA5RspfAvCe
-u0yLaT2039ht5BnZ02Bon0j/KaExuWsUcsp8T76GPgYAYmNrot6eBOB2lV6H6wGxeOstnBLybbEnxOk1
-034MqkPnfJt6nOOB41dIzhDysQPCsAwKkY7yRlUeRlo4NNb8kprHo/Hk+FA1V+JCv0aFK5qL3PeLLMOy
-RgKC1EXH5X1A/QKc7ox7i++Gvq8Dvn1gKsusFo+bbZH1HloTGqAHIuYFhpN6ojBoFgTfGC2q0hNgrA5r
-N/ZCtEKjzbetLh644JVBEIS4Cy8qb4NUfIsfIJaUBNvEi0O8VdjiryKgDWxhSSP2fPTfAAAA//8s7zL0
-gR4AAA==
+H4sIAAAAAAAC/6R763LbOLLwfz1Fb6qypCoy7Zlkt2bkT5tSbMXjHdtySfI3cyrrckEkJGFMARwAtK2T
+8bufwo0ESMq5rH8kEtBoNLobfUPrAXEoJclhBBz/WRKO40h9j/rHPTW3Ev7MSrjxjZSFP6O+u7kCyY0/
+p767uXRD8uyu4CzFIkAdTDhoiqUPQ7F0M2fTi/HV2d3J9Gq+GF8t5j5YcrhmOaLrg5RRIRGVIvlDMKqW
+9g4PF9PT6RDmGANZ6WOI4eHhivFyKxL0KBK0Rf/LaJKy7eEWC4HWOPlDFOi9/XJ+Ovrn27c/Hf3YOzyE
+DRKwxJhCWWRI4gweidwAxY9A6IrxLZKE0Z49VILpQ3I9XvwCI2gNvYFoePiA+KFE4j467vX0KefX49li
+fPfh/Go8+5+7q/HlBEYQzQvEJUpytF1mKEHb7J/vouMOeLcXkpvkD0ZoHB3KbRENOtBarl6Ofz+/vLm8
+m03m1+Pfru5OpjdXCxjBPzTnYMERFQXjEkqBM5AMVow/Ip4BfsBUCjUiNxgM+6tDwpRiYCuFwejJACIh
+M8IiYByikpKnKAGYPmD+yImUmMJyZxBhirlmbMqoxFTCEufsMfEPu5iNr+bX05mi06APeDGfnvw6Wezn
+hVL2xMgqtpw9eJ0lgqX30aA6QkGyft8xodSq0z4oiA0r8wyKcpkTsYEtlpykmisnOSuz35BMN4BoptYq
+VOpQiFDMHej3c+Fyspidn6hrsEK5wAELTqZXi/H51WTWhlJUXKP0Hq0xrDkrC2DByZaEIr5LACbbQu6g
+pLk6pwIQmD+QFCtNEArLh5Lk2bRQCi+SeZETafH+F4e6Hp/8Oj6b3J3NpjfXSrzqYqj569n09/PJ6d3l
+9PTmYqLO8ykSb7VeUaH+QwVZI4kf0S66tWvMia4ry0PLPLc2DpH8hJVUwgiODE9OKskQKiQvt5hKfZkH
+8Lgh6QaIAI7VVTD3oK0MCou2BhilG22bsJB6O0IfWKqReZuqiZI6lDMsCvRIhZk7PITTkusVwsmn2CCB
+lSSQBCGRoaPj6sHMoqzo0autmYUV4UI66gzv7dy12cDnU01fY1Lh/sjRVtFQmQghkcRDoyuSY7Q1xNq9
+BCCOwemEZOpaKDSaOkwzQtdGMZ6kGMA93uFMaY5dDeenRnJq0984kZgHlFoMM7fXCD4/O4/yJO3w+WnF
+3vNTx1eN0VDaZqemFwtgNMVAZCSAY5TtNJeNfDUuYSQCSMIPhqe/jK9O57+Mf53cfZyNLyd3buveqqSp
+kiukbFvkWGKLKLZnH2g2luKEZXgAG4wyzMUAlizb9eFzD0AZJO3QPnCSrbEm+opl+N9zxVFH/yPjeSYU
+AwlN81Jztz6eQfPLYnFtdwO1NRCqYTDnjAPHomBUaMuHioKzghMkcQKw2BABmKJljoXBpFaNr8/hzNxA
+IFTitdHfGo9kynSoDWmmPIildK4JWKj9OV7jp8Kg3CrTibUp3SKqrJVPbsoyLJIegOK12+EUSWQFD8Fg
+osBh5HG2BWGmYNQKMxK17d18MV7czO8Wk98Xn2osiWRzyQldx/3bFkYrOhg5IbYgDJ9HENcY4V8jeHd0
+1If3WuIwhJJmeEUozlrLORZlLk1I1cL6vl4IQ41LrZd8p3XI06KTDU7vnUU+kLsCwxKvGMdQIC4wICnx
+tpB6EVlB3EVCX88axHuJ/Pd8epVonN04jvXy5x7AM6RK+BDjfk3t1fS6Z6aVyDHn+86tYrGJ+hzrHYUW
+EFntgl37fRha61HrEIw03vd6AoYBegVnb2iSMYpjzPlAQ/SPe8/etd6ie6xUxl1rFX8MTLQ0gOqOV3GA
+OaGhQC/4oMQ+sufWy4Z2tR6xGIYVqmpUoxvWHxW3jnsWueMCwZkThc+Yaud+tcJqxAWmax3bfyhXK8yT
+5U5iMxZ7OAcQlXJ18FPUd/xkJi6oTrJhQg4hylmKcvU5MoQrvzGEn3/++Wf7HcnNUP9rvm+x3LBsCNH1
+dL6wa+x1GlYKF51Y7V3sChwNVSxQ5MQ43EOdBwyakOYI0TA8Zq2BFRs4/lPdYCmLxLIptkcbgJO50iyn
+qRyLRGA5oSlTNlelVivLFoNwaeQbRWZIwTMaRxmSKPJQppuS3verI+pVb0agh+1N6YcYMM18BPXavY5G
+0+o5G/U9dDj+TuZfjv80u6kb5u9X3dXwlgz0ZWog0E7V159qBtMsNhfKBRgmbEDwDpTqwZKsD5SrRxRy
+o5orlufs0QQLcoO1cmvq6zup99PYYhOYDOAB5SWuL5+VirIdRtGbxsPAt/Xc8Ctc+k7Pmhlz1ptzKt/+
++GESq30SQ/gAjjScocjyxN6ylNEUyfiTwWGEcduvGDPDsuRUAAKjN5ATITHF3IQx2siK2vGuLB9pVumC
+aEZeVrlhxbiJXxk1rn5fRIRgg2gmNuheJzZ6D4hJBkd9L1rSOQNdWxJcKJ2iPBfA6C8OQ+KFRRwjK6yZ
+Pn3swXkC04zCWcj5I6tIij177pK3sMFsNzMwt+zWav/jhuQYYjdrpacddX3HqtC0spcVvAoYK/kfWaTG
+lzZx/j94B298PPUGAEuO0b1b/dzc17qNCqPISYrjd4MWxjpm8VW5wZkmmgYOt6IOJxwtlc4F/r6isFoZ
+xhIJyWA0GnUEzT4DwNeY2ENlbA6hJa7HngOyrE2CUTNP+OSRcFuvzrC6JF8JrE5iNwjJfcnwGjyps7vm
+a2V8q5FlyDR3qnaEpP5yto6jj4jkJkPVvG9YgSFE8Ab8wLUfqpT1ftYAI5J3WQmxpxqCn4gU9VVWWfZ1
+yEIVNtWX2KFtiUXR1J3RAUyXf+BUJvd4J2IL01flnAlKN3HtjlC6+RXvHHss4Cc7fOvFcIF/akZy1g59
+QyintOFvXpba6RbrGNUWoLwsuqToAZFcZVdR3yPP2bVjGwaHee2bEfzQwbVPAdQtjBwdCtZzix7BA0sw
+yYbhHt0R2n8boracmtKrCsLUFLRXUuPUZIkm864EpUar8o1JJ8ML2ay/OGaZyNgmfk5MeTaXSEWlcXOZ
+MlE/9M1xGnPD5sDAxWa6qjPsqPRUAa4qAw73Vu7MfYS9pSKjcc1CjdM6c7qkcNWbJpxRrL1lHnNSVw77
+SLiQe8oVfaeW1vmaUSfbC7a2cs0zWyqxJOlQoW1KGnUqUzp1oUsl+K8krbI3eucF2WIYwamqZVD2GFeh
+XMWkoB7mZtW9hVFwjZs5IYy8cLhKD60kjHdMGc8qXQPYivXQVfc95lT5iqeNVpLViANpKaIF7NTHSiMt
+bFUHddMrj4+XYuhxCQ5q9hlPUec0kkmUX5pKhDphEqIxgIHdNkraMtug7bZmfN+rJyicn6oZ9XJyKSJl
+zAyeeqryyo6iN3th6vRJU1wfwX467jmXaiAseEPeTg1Af0pUYFurn18icF418C4vOZZaa2tjOHrR1Dlb
+0H4lGbl3EsfUby1SaPoB5wJ7CL7JNxrz8GwK8zlb+3eFLf+4Y/zOJFv9nvOiqhbFVo1Z+Js6DNO6FPV7
+ruoUAKkLpm9WMPrsLJTCHcyoJDi9r5GpR0SWY1NRiivLpo5fwahjPKlHJJxVkUmo4yHde1TdC1HA4XNB
+SiLZTVFgfoIEjvtK3QOUVSwT6rMjXmluI4e1+PtOGMdfk0zmbC1MRugMMOREv+51PH+wUhalTMAk4UZK
+GlxAjNcDhYyXQs9+1A9wEnPAVHKiXBbSRUchsH0X4Kxcb+AB8yWSZAuCVVV6wmFFsCpwqzV/lpjvVLwE
+hPrvbhdsLRKFaio3mFs6zCMEKgpTMVBs6puSvck8LwjFF2y91tetrqb0qpgVcUlQrsCqMk4z4VyWK9/s
+m41HEPtr36gkq52M9ROhXtLi6D/UZWbhjhpZUrDCZUBmoFu91Jpav8iqHq1SzhEc9e28g/MjzWaySYQS
+ntHz+lGxA3+6QXws46O+MT+fo/YuYfoITdzOAHiJZHUmi9XZAS9D6syMvApyI5HySPe3bxPr36uKjCaH
+rIkIV76w4rnyDOYuXrIHEw2tSI5BMlBP1up/VYNnHHGS79TTzj0gzkrzfvZdDQ3/+Ontzz8cabXHVJQc
+n7ELRNcf9OOvr/jKoy21dTQHknwXFPt1zqnfEHSZh3H7gGwSzXZ3QsWAlak9znc0jV8AE1ieb7c4I0ji
+ipggKzKcrKTeQR0rdoq44IG71hnjYrdbRJUlD5oD0gKSw9cCXotjSDdblsGbJ3gtqvBs/1+73+Jb1ygu
+fM+a6lxBe02Cn3Aa22PWVVswcaqQGSuD4oW+ypz3q4GwruH7RwV27M3WOxIZ/9DvKsl41yTErORliOmw
+jF2YnEYE56juWKUhz8c9z8R/NC0soYVXAUwdc9kulwU7a/QQVPCNaK0KDHT+H3QehFVCo37zvZmI+mtf
+ybijrm/w6XD+ZXQGEPG10G0TShVKiVXTxMGBIGuK8rDvRU9UBYm6e6iKKL3il6oSGV5pu3WhO5RgQh8I
+Z1RlZvD/ESf6qXgQPkX7KOrk9+P5manNi8GeLovW4aeNpybHw4eh33RVK09YuQu7aUKnoViWFKXYxNHB
+gc3Uo0499DAFLSwv4CtMs8yZ6sGpeRyu/sJWjRBft1W9sKPqcMKePL1GKX8nfT1f2MY0crXc62xPm4cC
+p/rxwZUiDYiqi8OKsy2sMnjr4fKFmujNtNoWpNA62/n/bRenmv0/oUXU23S4noFm2iAgo3GVdA/Il+6c
+ZonO96vuHcjqpp7uuofpMkLZLtjPTqr3kMA5hzJotvH4c1C55Ya9OGgapNDnaOhh88AHDSzhGg3cKB2E
+CDzwZ991dNShWrUY31WEJwyKKQ226v0bftscXFU5gk0SM97BhzaoHu44fRtSDzchO0sxPnP8a6mfpDsU
+O7EO03tFbmYyse88W4sx519cHKijxHxLqFZk9R6TY95yjFdo2wjAmzmS9wC7L7LoaNSE12IIr8V/aDSA
+aqMwUR5A59NtPwhSoOZ3VQ3u0sOq1toGaivgl1RQo2oFjxwjweiwPk4b4MWSXbU3eiLbcjsLoDvbe4OF
+zw2+KLtf7QH/6sbQlNsLUV9okrvMcl1qdn9dTX5N2TUfluonlW618RWmrQyd4V6sdg5yjZbNet53r/we
+ifZ9cXP9/ovrn4jct1xN+auL9ro9bkIJeE982kXIPcnz8LH1udelO98cL1yTAgv70lw3YirHq8M+jiMB
+lAHF5jXzERHp3oFCRI17JhmYoNZUi4KmzcUG7/e0GpltXRB1d4OhCxDn5MH0HHaracuuEupzrT3NPr29
+bRtev/PB9/uhyu5R2EZSctyyv/seyAPq9H4dlr0z3Eg43rIHfGGLhnE0Pz+7mc9+jAb7kQaEfVM8axoX
+O6RYB1QpoxRrgqOWFJuYrFCDYZ1X6EhZ19tlYhHGn83TZzt4ft5719paYjAfN2G+WZxtw20wf69CBev3
+N3eF7i+a6zWmYfiF1oKvtevdlh2/ePDgWaKhm/qc8dfZ+hd4/dxl7n2D+y0q/xyUrapUS28Pf/+7X1Co
+ayNffCw69ps36mCr86jHVTlEYJrNbFK2v7YxANOjYjp9PwdtxI2en/q3XSt64Mb97sfMtmjbc01ms+ls
+CBjeB4rjtz27MGc2md9cLOZDRwn89VcN5D9Ium0Tdbr2UeB9DfFxfH4xOfU6fZP5zcnJZD4faEKrlx9X
+XHS8crdB3wF9Bs1K0yFjQOxtcCuat6J6fTkphWQqaGMlT7H6FcqKrG2WCPhJRaGi1/jxy55iv1lb17DM
+ahXvNOuar0WwjwqN6vWKPLvxpxrF7dfUvmq9MBSMfwt+pYcexYHI7sPSK3q0xNhOvvFv88QMxJ+f68xD
+/cxiZX9agtJ7IAKI+xEebHUbVcZoJHXr1hpJ3AvS56fdAol7Xf+qSgX1sKmQ1NxUv8g7+dCstBWIo20z
+sZ4raq50Z5XmSqIHzrMuk/E1x3WwFVySu8comxyF+FL13PXR/fDQRxpMxBU+b49wbZJhkXKyxPoEIjbH
+HYBlhm+/aplMab6rWO4KdlZCRlxWREZeBWdrrqymJx1G9YanevfCnqJWtQGIxnTzFlb16u5qtW/kuixb
+I7wPq9QBJttC0qR1H31GFQS8/xLEp6NbGFZvyI295q4/qYkkmXvTf/0Fr141oyp/vXo2f3VzfTpeTO5O
+ppfXFxP14WIyvrq5vju/urueTc9mk/n8Vd9DEjrV5nPdviQYXNeOasd312JWD7VfNTbGRw49M9QG8hvN
+GpPPrUhDs+8h9axPYADVq05o9VoYxEOaWLq+EIfteXH8Dt1r5sqdsY12Oh926qHc/mTOWnLGwZT2lVpm
+JTbFVyV944w8dWjs+SUyX11Nr1/tC4l6DTt3eAhn2PyWLi05x1SanS0tvRcN6hfM6XPwaPfdpu/rDF/b
+MLkwq0PiYeXK68LNGcoCIQ33hsnfoC2uTVcZY+usky0iiguNN644OlQO9/AQxllG1ClQ7lJmq986Z15i
+yHYUbYl6UtuBapCgGc7Mb3R7/zcACrF5ZjVAAAA=
 `,
 	},
 
@@ -16110,6 +16199,11 @@ eZWcDGPdQSYOO92O0jzdDXtiE+Okj0bmOUm3a9c2PzA1v6UP2LKFxcaXBLdFjVBH8RdXV38YGLl9iT0B
 		local: "/resources/bootstrap/js",
 	},
 
+	"/resources/cloudformation": {
+		isDir: true,
+		local: "/resources/cloudformation",
+	},
+
 	"/resources/describe": {
 		isDir: true,
 		local: "/resources/describe",
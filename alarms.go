@@ -0,0 +1,281 @@
+package sparta
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Default alarm period (seconds)
+const defaultAlarmPeriod = 60
+
+// Default number of periods evaluated by each alarm
+const defaultAlarmEvaluationPeriods = 1
+
+// LambdaAlarmOptions defines the CloudWatch alarms provisioned for a Lambda
+// function.  Each alarm is disabled unless its threshold is defined.  Alarm
+// names are exported as stack outputs.
+type LambdaAlarmOptions struct {
+	// Alarm when the percentage of invocations that fail exceeds this value
+	ErrorRatePercentage float64
+	// Alarm when the number of throttled invocations reaches this value
+	Throttles float64
+	// Alarm when the maximum duration exceeds this percentage of
+	// LambdaFunctionOptions.Timeout
+	DurationPercentage float64
+	// Alarm when the maximum age of the records read by the stream based
+	// EventSourceMappings exceeds this value (milliseconds)
+	IteratorAgeMilliseconds float64
+	// Alarm period (seconds).  Defaults to 60.
+	Period int64
+	// Number of periods evaluated by each alarm.  Defaults to 1.
+	EvaluationPeriods int64
+	// SNS topic ARNs notified when an alarm enters the ALARM state
+	AlarmActions []string
+	// SNS topic ARNs notified when an alarm returns to the OK state
+	OKActions []string
+}
+
+// Returns a metric query for the given AWS/Lambda metric
+func lambdaMetricQuery(queryID string, metricName string, functionName interface{}, period int64) ArbitraryJSONObject {
+	return ArbitraryJSONObject{
+		"Id": queryID,
+		"MetricStat": ArbitraryJSONObject{
+			"Metric": ArbitraryJSONObject{
+				"Namespace":  "AWS/Lambda",
+				"MetricName": metricName,
+				"Dimensions": []ArbitraryJSONObject{
+					{
+						"Name":  "FunctionName",
+						"Value": functionName,
+					},
+				},
+			},
+			"Period": period,
+			"Stat":   "Sum",
+		},
+		"ReturnData": false,
+	}
+}
+
+// Add the alarms defined by info.Alarms and export their names as outputs
+func (info *LambdaAWSInfo) exportAlarms(resources ArbitraryJSONObject, outputs ArbitraryJSONObject) error {
+	options := info.Alarms
+	period := options.Period
+	if period <= 0 {
+		period = defaultAlarmPeriod
+	}
+	evaluationPeriods := options.EvaluationPeriods
+	if evaluationPeriods <= 0 {
+		evaluationPeriods = defaultAlarmEvaluationPeriods
+	}
+	functionName := ArbitraryJSONObject{
+		"Ref": info.logicalName(),
+	}
+	functionDimensions := []ArbitraryJSONObject{
+		{
+			"Name":  "FunctionName",
+			"Value": functionName,
+		},
+	}
+	// Returns an alarm with the common properties
+	newAlarm := func(description string, threshold float64, properties ArbitraryJSONObject) ArbitraryJSONObject {
+		properties["AlarmDescription"] = fmt.Sprintf("%s (%s)", description, info.lambdaFnName)
+		if nil == properties["ComparisonOperator"] {
+			properties["ComparisonOperator"] = "GreaterThanThreshold"
+		}
+		properties["EvaluationPeriods"] = evaluationPeriods
+		properties["Threshold"] = threshold
+		properties["TreatMissingData"] = "notBreaching"
+		if len(options.AlarmActions) > 0 {
+			properties["AlarmActions"] = options.AlarmActions
+		}
+		if len(options.OKActions) > 0 {
+			properties["OKActions"] = options.OKActions
+		}
+		return ArbitraryJSONObject{
+			"Type":       "AWS::CloudWatch::Alarm",
+			"Properties": properties,
+		}
+	}
+
+	alarms := make(ArbitraryJSONObject, 0)
+	if options.ErrorRatePercentage > 0 {
+		alarms[CloudFormationResourceName("LambdaErrorRateAlarm", info.lambdaFnName)] = newAlarm("Error rate (%)",
+			options.ErrorRatePercentage,
+			ArbitraryJSONObject{
+				"Metrics": []ArbitraryJSONObject{
+					lambdaMetricQuery("errors", "Errors", functionName, period),
+					lambdaMetricQuery("invocations", "Invocations", functionName, period),
+					{
+						"Id":         "errorRate",
+						"Expression": "100 * errors / invocations",
+						"Label":      "Error rate (%)",
+						"ReturnData": true,
+					},
+				},
+			})
+	}
+	if options.Throttles > 0 {
+		alarms[CloudFormationResourceName("LambdaThrottlesAlarm", info.lambdaFnName)] = newAlarm("Throttles",
+			options.Throttles,
+			ArbitraryJSONObject{
+				"ComparisonOperator": "GreaterThanOrEqualToThreshold",
+				"Namespace":          "AWS/Lambda",
+				"MetricName":         "Throttles",
+				"Dimensions":         functionDimensions,
+				"Period":             period,
+				"Statistic":          "Sum",
+			})
+	}
+	if options.DurationPercentage > 0 {
+		if nil == info.Options || info.Options.Timeout <= 0 {
+			return fmt.Errorf("DurationPercentage alarm for %s requires LambdaFunctionOptions.Timeout", info.lambdaFnName)
+		}
+		alarms[CloudFormationResourceName("LambdaDurationAlarm", info.lambdaFnName)] = newAlarm(
+			fmt.Sprintf("Duration exceeds %s%% of timeout", strconv.FormatFloat(options.DurationPercentage, 'f', -1, 64)),
+			float64(info.Options.Timeout*1000)*options.DurationPercentage/100,
+			ArbitraryJSONObject{
+				"Namespace":  "AWS/Lambda",
+				"MetricName": "Duration",
+				"Dimensions": functionDimensions,
+				"Period":     period,
+				"Statistic":  "Maximum",
+				"Unit":       "Milliseconds",
+			})
+	}
+	if options.IteratorAgeMilliseconds > 0 {
+		if len(info.EventSourceMappings) <= 0 {
+			return fmt.Errorf("IteratorAgeMilliseconds alarm for %s requires EventSourceMappings", info.lambdaFnName)
+		}
+		alarms[CloudFormationResourceName("LambdaIteratorAgeAlarm", info.lambdaFnName)] = newAlarm("Iterator age",
+			options.IteratorAgeMilliseconds,
+			ArbitraryJSONObject{
+				"Namespace":  "AWS/Lambda",
+				"MetricName": "IteratorAge",
+				"Dimensions": functionDimensions,
+				"Period":     period,
+				"Statistic":  "Maximum",
+				"Unit":       "Milliseconds",
+			})
+	}
+	for eachAlarmName, eachAlarm := range alarms {
+		resources[eachAlarmName] = eachAlarm
+		outputs[eachAlarmName] = ArbitraryJSONObject{
+			"Description": eachAlarm.(ArbitraryJSONObject)["Properties"].(ArbitraryJSONObject)["AlarmDescription"],
+			"Value": ArbitraryJSONObject{
+				"Ref": eachAlarmName,
+			},
+		}
+	}
+	return nil
+}
+
+// Placeholder for a CloudFormation expression in a JSON string
+var joinPlaceholderRegexp = regexp.MustCompile(`@@SPARTA([0-9]+)@@`)
+
+// Returns an Fn::Join expression that produces the JSON representation of
+// value.  Each expression in value must first be replaced by
+// placeholder(expression).
+type jsonJoinBuilder struct {
+	expressions []interface{}
+}
+
+// Returns the string that stands in for the expression
+func (builder *jsonJoinBuilder) placeholder(expression interface{}) string {
+	builder.expressions = append(builder.expressions, expression)
+	return fmt.Sprintf("@@SPARTA%d@@", len(builder.expressions)-1)
+}
+
+func (builder *jsonJoinBuilder) join(value interface{}) (ArbitraryJSONObject, error) {
+	valueJSON, err := json.Marshal(value)
+	if nil != err {
+		return nil, err
+	}
+	var parts []interface{}
+	remaining := string(valueJSON)
+	for {
+		match := joinPlaceholderRegexp.FindStringSubmatchIndex(remaining)
+		if nil == match {
+			break
+		}
+		index, _ := strconv.Atoi(remaining[match[2]:match[3]])
+		parts = append(parts, remaining[0:match[0]], builder.expressions[index])
+		remaining = remaining[match[1]:]
+	}
+	parts = append(parts, remaining)
+	return ArbitraryJSONObject{
+		"Fn::Join": []interface{}{"", parts},
+	}, nil
+}
+
+// Returns the logical name of the service dashboard
+func dashboardLogicalName(serviceName string) string {
+	return CloudFormationResourceName("Dashboard", serviceName)
+}
+
+// Returns the AWS::CloudWatch::Dashboard resource that displays the
+// invocation, error, throttle and duration metrics of each function
+func dashboardResource(lambdaAWSInfos []*LambdaAWSInfo) (ArbitraryJSONObject, error) {
+	builder := &jsonJoinBuilder{}
+	region := builder.placeholder(ArbitraryJSONObject{
+		"Ref": "AWS::Region",
+	})
+	var widgets []interface{}
+	for index, eachLambda := range lambdaAWSInfos {
+		functionName := builder.placeholder(ArbitraryJSONObject{
+			"Ref": eachLambda.logicalName(),
+		})
+		// Use the golang function name, without the package path
+		title := eachLambda.lambdaFnName[strings.LastIndex(eachLambda.lambdaFnName, "/")+1:]
+		widgets = append(widgets,
+			ArbitraryJSONObject{
+				"type":   "metric",
+				"x":      0,
+				"y":      index * 6,
+				"width":  12,
+				"height": 6,
+				"properties": ArbitraryJSONObject{
+					"title":  fmt.Sprintf("%s invocations", title),
+					"region": region,
+					"stat":   "Sum",
+					"period": 300,
+					"metrics": []interface{}{
+						[]interface{}{"AWS/Lambda", "Invocations", "FunctionName", functionName},
+						[]interface{}{".", "Errors", ".", "."},
+						[]interface{}{".", "Throttles", ".", "."},
+					},
+				},
+			},
+			ArbitraryJSONObject{
+				"type":   "metric",
+				"x":      12,
+				"y":      index * 6,
+				"width":  12,
+				"height": 6,
+				"properties": ArbitraryJSONObject{
+					"title":  fmt.Sprintf("%s duration", title),
+					"region": region,
+					"period": 300,
+					"metrics": []interface{}{
+						[]interface{}{"AWS/Lambda", "Duration", "FunctionName", functionName, ArbitraryJSONObject{"stat": "Average"}},
+						[]interface{}{"...", ArbitraryJSONObject{"stat": "Maximum"}},
+					},
+				},
+			})
+	}
+	dashboardBody, err := builder.join(ArbitraryJSONObject{
+		"widgets": widgets,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return ArbitraryJSONObject{
+		"Type": "AWS::CloudWatch::Dashboard",
+		"Properties": ArbitraryJSONObject{
+			"DashboardBody": dashboardBody,
+		},
+	}, nil
+}
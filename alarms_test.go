@@ -0,0 +1,95 @@
+package sparta
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testAlarmTopicArn = "arn:aws:sns:us-west-2:123412341234:alarms"
+
+func testAlarmLambdaData() []*LambdaAWSInfo {
+	lambdas := testLambdaData()
+	lambdas[0].Alarms = &LambdaAlarmOptions{
+		ErrorRatePercentage:     5,
+		Throttles:               1,
+		DurationPercentage:      80,
+		IteratorAgeMilliseconds: 60000,
+		AlarmActions:            []string{testAlarmTopicArn},
+	}
+	return lambdas
+}
+
+func TestAlarmsExport(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdaFn := testAlarmLambdaData()[0]
+	resources := make(ArbitraryJSONObject, 0)
+	outputs := make(ArbitraryJSONObject, 0)
+	err = lambdaFn.export("S3Bucket", "S3Key", make(map[string]interface{}, 0), nil, resources, outputs, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	for _, eachPrefix := range []string{"LambdaErrorRateAlarm", "LambdaThrottlesAlarm", "LambdaDurationAlarm", "LambdaIteratorAgeAlarm"} {
+		alarmName := CloudFormationResourceName(eachPrefix, lambdaFn.lambdaFnName)
+		alarm, _ := resources[alarmName].(ArbitraryJSONObject)
+		if nil == alarm || alarm["Type"] != "AWS::CloudWatch::Alarm" {
+			t.Fatalf("Failed to find alarm resource %s", alarmName)
+		}
+		properties := alarm["Properties"].(ArbitraryJSONObject)
+		if !reflect.DeepEqual(properties["AlarmActions"], []string{testAlarmTopicArn}) {
+			t.Errorf("Unexpected %s actions: %#v", eachPrefix, properties["AlarmActions"])
+		}
+		output, _ := outputs[alarmName].(ArbitraryJSONObject)
+		if nil == output || !reflect.DeepEqual(output["Value"], ArbitraryJSONObject{"Ref": alarmName}) {
+			t.Errorf("Failed to find %s output", alarmName)
+		}
+	}
+	durationAlarm := resources[CloudFormationResourceName("LambdaDurationAlarm", lambdaFn.lambdaFnName)]
+	threshold := durationAlarm.(ArbitraryJSONObject)["Properties"].(ArbitraryJSONObject)["Threshold"]
+	if threshold != float64(2400) {
+		t.Errorf("Unexpected duration threshold: %v", threshold)
+	}
+}
+
+func TestAlarmsRequireEventSourceMappings(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdas := testLambdaData()
+	lambdas[1].Alarms = &LambdaAlarmOptions{
+		IteratorAgeMilliseconds: 60000,
+	}
+	err = Validate("SampleProvision", "", lambdas, nil, nil, logger)
+	if nil == err {
+		t.Fatal("Expected IteratorAge alarm without EventSourceMappings to fail validation")
+	}
+}
+
+func TestDashboardValidate(t *testing.T) {
+	logger, err := NewLogger("info")
+	options := &ProvisionOptions{
+		Dashboard: true,
+	}
+	err = Validate("SampleProvision", "", testAlarmLambdaData(), nil, options, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestDashboardResource(t *testing.T) {
+	lambdas := testLambdaData()
+	dashboard, err := dashboardResource(lambdas)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	body := dashboard["Properties"].(ArbitraryJSONObject)["DashboardBody"].(ArbitraryJSONObject)
+	parts := body["Fn::Join"].([]interface{})[1].([]interface{})
+	var refs []interface{}
+	for _, eachPart := range parts {
+		if _, isString := eachPart.(string); !isString {
+			refs = append(refs, eachPart)
+		}
+	}
+	// Each lambda has two widgets that reference both the function and
+	// AWS::Region
+	if len(refs) != 4*len(lambdas) {
+		t.Errorf("Unexpected dashboard references: %#v", refs)
+	}
+}
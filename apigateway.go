@@ -253,11 +253,9 @@ type Resource struct {
 // CloudFormation template representation.
 func (resource Resource) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]interface{}{
-		"PathPart": resource.pathPart,
-		"LambdaArn": ArbitraryJSONObject{
-			"Fn::GetAtt": []string{resource.parentLambda.logicalName(), "Arn"},
-		},
-		"Methods": resource.Methods,
+		"PathPart":  resource.pathPart,
+		"LambdaArn": resource.parentLambda.functionARN(),
+		"Methods":   resource.Methods,
 	})
 }
 
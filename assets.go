@@ -0,0 +1,41 @@
+package sparta
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// DefaultAssetPrefix is the ZIP archive directory that contains the asset
+// files when AssetOptions.Prefix is not defined
+const DefaultAssetPrefix = "assets"
+
+// Default AWS Lambda code directory
+const defaultLambdaTaskRoot = "/var/task"
+
+// AssetOptions defines additional files (templates, certificates, data files)
+// that are included in the Lambda ZIP archive.  Use AssetPath to resolve
+// their location at runtime.
+type AssetOptions struct {
+	// Local file paths or glob patterns (see filepath.Glob).  Directories are
+	// added recursively.  Relative paths are preserved in the archive, while
+	// absolute paths are added by their base name.
+	Paths []string
+	// Optional filesystem whose files are all added
+	FileSystem http.FileSystem
+	// Archive directory that contains the files.  Defaults to
+	// DefaultAssetPrefix.
+	Prefix string
+}
+
+// AssetPath returns the runtime path of a file included via
+// ProvisionOptions.Assets.  The name is relative to the Lambda code
+// directory ($LAMBDA_TASK_ROOT, typically /var/task) and includes the asset
+// prefix (eg, AssetPath("assets/templates/index.html")).
+func AssetPath(name string) string {
+	taskRoot := os.Getenv("LAMBDA_TASK_ROOT")
+	if "" == taskRoot {
+		taskRoot = defaultLambdaTaskRoot
+	}
+	return filepath.Join(taskRoot, filepath.FromSlash(name))
+}
@@ -0,0 +1,75 @@
+package sparta
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestAssetArchiveEntries(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "SpartaAssets")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(tmpDir)
+	for _, eachPath := range []string{"certs/ca.pem", "data/geo.json", "static/index.html"} {
+		fullPath := filepath.Join(tmpDir, filepath.FromSlash(eachPath))
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		err = ioutil.WriteFile(fullPath, []byte(eachPath), 0644)
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+	}
+
+	entries, err := assetArchiveEntries(&AssetOptions{
+		Paths: []string{
+			filepath.Join(tmpDir, "certs"),
+			filepath.Join(tmpDir, "data", "*.json"),
+			"resources/provision/s3.js",
+		},
+		FileSystem: http.Dir(filepath.Join(tmpDir, "static")),
+		Prefix:     "files",
+	})
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	var names []string
+	for _, eachEntry := range entries {
+		names = append(names, eachEntry.name)
+	}
+	sort.Strings(names)
+	expected := []string{
+		"files/certs/ca.pem",
+		"files/geo.json",
+		"files/index.html",
+		"files/resources/provision/s3.js",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected asset entries: %v", names)
+	}
+
+	_, err = assetArchiveEntries(&AssetOptions{
+		Paths: []string{filepath.Join(tmpDir, "undefined", "*")},
+	})
+	if nil == err {
+		t.Error("Expected unmatched asset pattern to fail")
+	}
+}
+
+func TestAssetPath(t *testing.T) {
+	taskRoot := os.Getenv("LAMBDA_TASK_ROOT")
+	defer os.Setenv("LAMBDA_TASK_ROOT", taskRoot)
+
+	os.Setenv("LAMBDA_TASK_ROOT", "")
+	if AssetPath("assets/geo.json") != filepath.FromSlash("/var/task/assets/geo.json") {
+		t.Errorf("Unexpected asset path: %s", AssetPath("assets/geo.json"))
+	}
+	os.Setenv("LAMBDA_TASK_ROOT", filepath.FromSlash("/opt/task"))
+	if AssetPath("assets/geo.json") != filepath.FromSlash("/opt/task/assets/geo.json") {
+		t.Errorf("Unexpected asset path: %s", AssetPath("assets/geo.json"))
+	}
+}
@@ -0,0 +1,201 @@
+package sparta
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildCommand(t *testing.T) {
+	cmd := buildCommand("SampleProvision.lambda.amd64", nil)
+	expected := []string{"go", "build", "-o", "SampleProvision.lambda.amd64", "-tags", "lambdabinary", "."}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Unexpected default build command: %v", cmd.Args)
+	}
+
+	cmd = buildCommand("SampleProvision.lambda.amd64", &BuildOptions{
+		Tags:        []string{"netgo", "production"},
+		LDFlags:     "-s -w -X main.version=1.0.0",
+		Flags:       []string{"-a"},
+		PackagePath: "./cmd/service",
+		Env:         []string{"CGO_ENABLED=0"},
+	})
+	expected = []string{"go", "build",
+		"-o", "SampleProvision.lambda.amd64",
+		"-tags", "lambdabinary netgo production",
+		"-ldflags", "-s -w -X main.version=1.0.0",
+		"-a",
+		"./cmd/service"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Unexpected build command: %v", cmd.Args)
+	}
+	if cmd.Env[len(cmd.Env)-1] != "CGO_ENABLED=0" {
+		t.Errorf("Expected build environment to include user variables: %v", cmd.Env)
+	}
+}
+
+func TestBuildExecutableFailure(t *testing.T) {
+	logger, err := NewLogger("info")
+	err = buildExecutable("SampleProvision.lambda.amd64", &BuildOptions{
+		PackagePath: "./undefined",
+	}, logger)
+	if nil == err {
+		t.Fatal("Expected build of undefined package to fail")
+	}
+}
+
+func TestCompressBinaryUnsupported(t *testing.T) {
+	_, err := lambdaPackages(testLambdaData(), &BuildOptions{
+		CompressBinary: true,
+	})
+	if nil == err {
+		t.Fatal("Expected BuildOptions.CompressBinary to be rejected")
+	}
+}
+
+func TestLambdaPackages(t *testing.T) {
+	lambda1 := NewLambda(LambdaExecuteARN, mockLambda1, nil)
+	lambda2 := NewLambda(LambdaExecuteARN, mockLambda2, nil)
+	lambda2.PackageGroup = "reports"
+	lambda3 := NewLambda(LambdaExecuteARN, mockLambda3, nil)
+	lambda3.PackageGroup = "reports"
+	lambdaAWSInfos := []*LambdaAWSInfo{lambda1, lambda2, lambda3}
+
+	packages, err := lambdaPackages(lambdaAWSInfos, nil)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(packages) != 1 || len(packages[0].lambdas) != 3 || "" != packages[0].group {
+		t.Fatalf("Expected a single package for all functions: %#v", packages)
+	}
+
+	packages, err = lambdaPackages(lambdaAWSInfos, &BuildOptions{
+		SplitPackages: true,
+	})
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(packages) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(packages))
+	}
+	if packages[0].group != lambda1.jsHandlerName() || len(packages[0].lambdas) != 1 {
+		t.Errorf("Expected ungrouped function to be packaged individually: %#v", packages[0])
+	}
+	if packages[1].group != "reports" || len(packages[1].lambdas) != 2 {
+		t.Errorf("Expected grouped functions to share a package: %#v", packages[1])
+	}
+
+	_, err = lambdaPackages(lambdaAWSInfos, &BuildOptions{
+		SplitPackages:  true,
+		PrebuiltBinary: "SampleProvision.lambda.amd64",
+	})
+	if nil == err {
+		t.Error("Expected SplitPackages with PrebuiltBinary to fail")
+	}
+
+	lambda2.PackageGroup = "daily reports"
+	lambda3.PackageGroup = "daily-reports"
+	_, err = lambdaPackages(lambdaAWSInfos, &BuildOptions{
+		SplitPackages: true,
+	})
+	if nil == err {
+		t.Error("Expected groups with the same sanitized name to fail")
+	}
+}
+
+func TestLambdaPackageContents(t *testing.T) {
+	logger, _ := NewLogger("info")
+	lambda1 := NewLambda(LambdaExecuteARN, mockLambda1, nil)
+	lambda2 := NewLambda(LambdaExecuteARN, mockLambda2, nil)
+	lambda2.PackageGroup = "reports"
+	lambdaAWSInfos := []*LambdaAWSInfo{lambda1, lambda2}
+	ctx := &workflowContext{
+		lambdaAWSInfos: lambdaAWSInfos,
+		logger:         logger,
+	}
+	packages, err := lambdaPackages(lambdaAWSInfos, &BuildOptions{
+		SplitPackages: true,
+	})
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	var sources []string
+	for _, eachPackage := range packages {
+		source, err := nodeJSProxySource(ctx, eachPackage, "SampleProvision.lambda.amd64", TransportHTTP)
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) != 2 || sources[0] == sources[1] {
+		t.Fatal("Expected each package group to have its own NodeJS proxy")
+	}
+	// Each proxy only exports the functions in its group and identifies
+	// the group to the binary
+	for index, eachPackage := range packages {
+		otherPackage := packages[1-index]
+		if !strings.Contains(sources[index], fmt.Sprintf("exports[\"%s\"]", eachPackage.lambdas[0].jsHandlerName())) {
+			t.Errorf("Expected %s proxy to export its function", eachPackage.group)
+		}
+		if strings.Contains(sources[index], fmt.Sprintf("exports[\"%s\"]", otherPackage.lambdas[0].jsHandlerName())) {
+			t.Errorf("Expected %s proxy to exclude the %s function", eachPackage.group, otherPackage.group)
+		}
+		if !strings.Contains(sources[index], fmt.Sprintf("SPARTA_PACKAGE_GROUP=\"%s\";", eachPackage.group)) {
+			t.Errorf("Expected %s proxy to define its package group", eachPackage.group)
+		}
+	}
+	// Each binary only dispatches to the functions in its group
+	for _, eachPackage := range packages {
+		groupLambdas := packageGroupLambdas(lambdaAWSInfos, eachPackage.group)
+		if !reflect.DeepEqual(groupLambdas, eachPackage.lambdas) {
+			t.Errorf("Unexpected %s dispatch functions: %v", eachPackage.group, groupLambdas)
+		}
+	}
+	if len(packageGroupLambdas(lambdaAWSInfos, "")) != 2 {
+		t.Error("Expected ungrouped binary to dispatch to every function")
+	}
+}
+
+func TestLambdaPackageBuildOptions(t *testing.T) {
+	options := &BuildOptions{
+		Tags: []string{"netgo"},
+	}
+	pkg := &lambdaPackage{}
+	if pkg.buildOptions(options) != options {
+		t.Error("Expected ungrouped package to use the service build options")
+	}
+	pkg.group = "github.com/example.Reports"
+	groupOptions := pkg.buildOptions(options)
+	expected := []string{"netgo", "sparta_group_github_com_example_Reports"}
+	if !reflect.DeepEqual(groupOptions.Tags, expected) {
+		t.Errorf("Unexpected group build tags: %v", groupOptions.Tags)
+	}
+	if len(options.Tags) != 1 {
+		t.Errorf("Expected service build options to be unchanged: %v", options.Tags)
+	}
+	cmd := buildCommand("SampleProvision-reports.lambda.amd64", pkg.buildOptions(nil))
+	if cmd.Args[5] != "lambdabinary sparta_group_github_com_example_Reports" {
+		t.Errorf("Unexpected group build command: %v", cmd.Args)
+	}
+}
+
+func TestStackPackages(t *testing.T) {
+	packages := []*lambdaPackage{
+		{
+			archivePath: "/tmp/SampleProvision-archive",
+			archiveHash: "e08b554042b716cbf25e50c5d109d89c0617cf61",
+			s3Key:       "SampleProvision-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip",
+		},
+	}
+	copies := stackPackages(packages, "SampleProvision-east")
+	if copies[0].s3Key != "SampleProvision_east-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip" {
+		t.Errorf("Unexpected stack package key: %s", copies[0].s3Key)
+	}
+	if copies[0].archivePath != packages[0].archivePath {
+		t.Error("Expected stack package to share the archive")
+	}
+	if packages[0].s3Key != "SampleProvision-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip" {
+		t.Errorf("Expected source package key to be unchanged: %s", packages[0].s3Key)
+	}
+}
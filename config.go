@@ -0,0 +1,193 @@
+package sparta
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// Environment variable that maps each configuration key to the
+// environment variable that stores its value
+const configEnvVarName = "SPARTA_CONFIG"
+
+// Prefix of the environment variables that store configuration values
+const configValueEnvVarPrefix = "SPARTA_CONFIG_"
+
+// Prefix of the environment variables that store KMS encrypted
+// configuration values
+const configKMSValueEnvVarPrefix = "SPARTA_CONFIG_KMS_"
+
+// SSMParameterValue returns a Config value that CloudFormation resolves from
+// the named SSM Parameter Store String parameter when the stack is
+// provisioned.  See
+// http://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/dynamic-references.html
+// for more information.
+func SSMParameterValue(parameterName string) string {
+	return fmt.Sprintf("{{resolve:ssm:%s}}", parameterName)
+}
+
+// KMSEncryptedValue is a Config value that is decrypted by the Lambda
+// function when it starts.  The Lambda execution role is granted
+// kms:Decrypt permission for KeyArn.
+type KMSEncryptedValue struct {
+	// Base64 encoded ciphertext produced by KMS Encrypt
+	// (eg, `aws kms encrypt --query CiphertextBlob --output text ...`)
+	CiphertextBlob string
+	// ARN of the KMS key used to encrypt the value
+	KeyArn string
+}
+
+// Returns the Lambda Environment Variables that deliver config to the
+// function and the ARNs of the KMS keys needed to decrypt the values.
+// Each value is stored in its own variable s.t. values resolved by
+// CloudFormation don't need to be escaped.
+func configEnvironment(config map[string]interface{}) (ArbitraryJSONObject, []string, error) {
+	if len(config) <= 0 {
+		return nil, nil, nil
+	}
+	var keys []string
+	for eachKey := range config {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+
+	variables := make(ArbitraryJSONObject, 0)
+	valueVariables := make(map[string]string, 0)
+	var kmsKeyArns []string
+	for index, eachKey := range keys {
+		variableName := fmt.Sprintf("%s%d", configValueEnvVarPrefix, index)
+		switch typedValue := config[eachKey].(type) {
+		case string, ArbitraryJSONObject, map[string]interface{}:
+			variables[variableName] = typedValue
+		case bool, int, int64, float64:
+			variables[variableName] = fmt.Sprintf("%v", typedValue)
+		case KMSEncryptedValue:
+			if "" == typedValue.CiphertextBlob || "" == typedValue.KeyArn {
+				return nil, nil, fmt.Errorf("KMSEncryptedValue for config key %s requires CiphertextBlob and KeyArn", eachKey)
+			}
+			variableName = fmt.Sprintf("%s%d", configKMSValueEnvVarPrefix, index)
+			variables[variableName] = typedValue.CiphertextBlob
+			kmsKeyArns = appendUnique(kmsKeyArns, typedValue.KeyArn)
+		default:
+			return nil, nil, fmt.Errorf("Unsupported value type for config key %s: %T", eachKey, typedValue)
+		}
+		valueVariables[eachKey] = variableName
+	}
+	variablesJSON, err := json.Marshal(valueVariables)
+	if nil != err {
+		return nil, nil, err
+	}
+	variables[configEnvVarName] = string(variablesJSON)
+	return variables, kmsKeyArns, nil
+}
+
+// Returns the union of the service and lambda config.  Lambda values
+// take precedence.
+func mergedConfig(serviceConfig map[string]interface{}, lambdaConfig map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(serviceConfig)+len(lambdaConfig))
+	for eachKey, eachValue := range serviceConfig {
+		merged[eachKey] = eachValue
+	}
+	for eachKey, eachValue := range lambdaConfig {
+		merged[eachKey] = eachValue
+	}
+	return merged
+}
+
+// LambdaConfig is the resolved configuration delivered to a Lambda function.
+// See LambdaAWSInfo.Config.
+type LambdaConfig map[string]string
+
+// Value returns the value for key and whether it is defined
+func (config LambdaConfig) Value(key string) (string, bool) {
+	value, exists := config[key]
+	return value, exists
+}
+
+// Int returns the value for key parsed as an integer
+func (config LambdaConfig) Int(key string) (int64, error) {
+	value, exists := config[key]
+	if !exists {
+		return 0, fmt.Errorf("Config key %s is not defined", key)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Bool returns the value for key parsed as a boolean
+func (config LambdaConfig) Bool(key string) (bool, error) {
+	value, exists := config[key]
+	if !exists {
+		return false, fmt.Errorf("Config key %s is not defined", key)
+	}
+	return strconv.ParseBool(value)
+}
+
+// Returns the config delivered to this process via the Lambda
+// Environment Variables.  KMS encrypted values are decrypted.
+func loadLambdaConfig(logger *logrus.Logger) (LambdaConfig, error) {
+	config := make(LambdaConfig, 0)
+	configVariables := os.Getenv(configEnvVarName)
+	if "" == configVariables {
+		return config, nil
+	}
+	var valueVariables map[string]string
+	err := json.Unmarshal([]byte(configVariables), &valueVariables)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse %s: %s", configEnvVarName, err.Error())
+	}
+	var kmsSvc *kms.KMS
+	for eachKey, eachVariable := range valueVariables {
+		value := os.Getenv(eachVariable)
+		if strings.HasPrefix(eachVariable, configKMSValueEnvVarPrefix) {
+			ciphertext, err := base64.StdEncoding.DecodeString(value)
+			if nil != err {
+				return nil, fmt.Errorf("Failed to decode KMS encrypted config key %s: %s", eachKey, err.Error())
+			}
+			if nil == kmsSvc {
+				kmsSvc = kms.New(session.New())
+			}
+			decryptOutput, err := kmsSvc.Decrypt(&kms.DecryptInput{
+				CiphertextBlob: ciphertext,
+			})
+			if nil != err {
+				return nil, fmt.Errorf("Failed to decrypt config key %s: %s", eachKey, err.Error())
+			}
+			value = string(decryptOutput.Plaintext)
+		}
+		config[eachKey] = value
+	}
+	logger.WithFields(logrus.Fields{
+		"KeyCount": len(config),
+	}).Debug("Loaded Lambda config")
+	return config, nil
+}
+
+// Returns the IAM policy resource that grants the lambda's role kms:Decrypt
+// access to the config keys
+func configKMSPolicy(lambdaFnName string, roleName interface{}, kmsKeyArns []string) ArbitraryJSONObject {
+	return ArbitraryJSONObject{
+		"Type": "AWS::IAM::Policy",
+		"Properties": ArbitraryJSONObject{
+			"PolicyName": CloudFormationResourceName("ConfigKMSPolicy", lambdaFnName),
+			"Roles":      []interface{}{roleName},
+			"PolicyDocument": ArbitraryJSONObject{
+				"Version": "2012-10-17",
+				"Statement": []ArbitraryJSONObject{
+					{
+						"Effect":   "Allow",
+						"Action":   []string{"kms:Decrypt"},
+						"Resource": kmsKeyArns,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package sparta
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+const testKMSKeyArn = "arn:aws:kms:us-west-2:123412341234:key/12345678-1234-1234-1234-123456789012"
+
+func testConfigLambdaData() []*LambdaAWSInfo {
+	lambdas := testIAMRoleDefinitionLambdaData()
+	lambdas[0].Config = map[string]interface{}{
+		"TableName": ArbitraryJSONObject{
+			"Ref": "AWS::StackName",
+		},
+		"BatchSize":   10,
+		"DatabaseURL": SSMParameterValue("/sample/databaseURL"),
+		"APIKey": KMSEncryptedValue{
+			CiphertextBlob: "c2FtcGxl",
+			KeyArn:         testKMSKeyArn,
+		},
+	}
+	return lambdas
+}
+
+func TestConfigExport(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdaFn := testConfigLambdaData()[0]
+	serviceConfig := map[string]interface{}{
+		"Stage":     "production",
+		"BatchSize": 1,
+	}
+	resources := make(ArbitraryJSONObject, 0)
+	outputs := make(ArbitraryJSONObject, 0)
+	err = lambdaFn.export("S3Bucket", "S3Key", make(map[string]interface{}, 0), serviceConfig, resources, outputs, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	properties := resources[lambdaFn.logicalName()].(ArbitraryJSONObject)["Properties"].(ArbitraryJSONObject)
+	variables := properties["Environment"].(ArbitraryJSONObject)["Variables"].(ArbitraryJSONObject)
+	expected := ArbitraryJSONObject{
+		"SPARTA_CONFIG":       `{"APIKey":"SPARTA_CONFIG_KMS_0","BatchSize":"SPARTA_CONFIG_1","DatabaseURL":"SPARTA_CONFIG_2","Stage":"SPARTA_CONFIG_3","TableName":"SPARTA_CONFIG_4"}`,
+		"SPARTA_CONFIG_KMS_0": "c2FtcGxl",
+		"SPARTA_CONFIG_1":     "10",
+		"SPARTA_CONFIG_2":     "{{resolve:ssm:/sample/databaseURL}}",
+		"SPARTA_CONFIG_3":     "production",
+		"SPARTA_CONFIG_4":     ArbitraryJSONObject{"Ref": "AWS::StackName"},
+		"SPARTA_STACK_ID":     ArbitraryJSONObject{"Ref": "AWS::StackId"},
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("Unexpected config variables: %#v", variables)
+	}
+	kmsPolicy, exists := resources[CloudFormationResourceName("ConfigKMSPolicy", lambdaFn.lambdaFnName)]
+	if !exists {
+		t.Fatal("Failed to find KMS config policy")
+	}
+	roles := kmsPolicy.(ArbitraryJSONObject)["Properties"].(ArbitraryJSONObject)["Roles"]
+	if !reflect.DeepEqual(roles, []interface{}{ArbitraryJSONObject{"Ref": lambdaFn.RoleDefinition.logicalName()}}) {
+		t.Errorf("Unexpected KMS config policy roles: %#v", roles)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	logger, err := NewLogger("info")
+	err = Validate("SampleProvision", "", testConfigLambdaData(), nil, nil, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	lambdas := testConfigLambdaData()
+	lambdas[0].Config["Invalid"] = []string{"unsupported"}
+	err = Validate("SampleProvision", "", lambdas, nil, nil, logger)
+	if nil == err {
+		t.Fatal("Expected unsupported config value type to fail validation")
+	}
+}
+
+func TestLoadLambdaConfig(t *testing.T) {
+	logger, err := NewLogger("info")
+	variables, _, err := configEnvironment(map[string]interface{}{
+		"TableName": "SampleTable",
+		"BatchSize": 10,
+		"Enabled":   true,
+	})
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	for eachKey, eachValue := range variables {
+		os.Setenv(eachKey, eachValue.(string))
+		defer os.Unsetenv(eachKey)
+	}
+	config, err := loadLambdaConfig(logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if value, _ := config.Value("TableName"); value != "SampleTable" {
+		t.Errorf("Unexpected TableName value: %s", value)
+	}
+	if value, err := config.Int("BatchSize"); nil != err || value != 10 {
+		t.Errorf("Unexpected BatchSize value: %d (%v)", value, err)
+	}
+	if value, err := config.Bool("Enabled"); nil != err || !value {
+		t.Errorf("Unexpected Enabled value: %t (%v)", value, err)
+	}
+	if _, err := config.Int("Undefined"); nil == err {
+		t.Error("Expected undefined config key to return an error")
+	}
+}
@@ -3,33 +3,176 @@
 package sparta
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 )
 
-// Delete the provided serviceName.  Failing to delete a non-existent
-// service is not considered an error.  Note that the delete does
-func Delete(serviceName string, logger *logrus.Logger) error {
-	session := awsSession(logger)
-	awsCloudFormation := cloudformation.New(session)
+// Issue the DeleteStack request and wait for the stack to reach a terminal
+// state.  Returns the resources that failed to delete, if any.
+func deleteStack(stackID string,
+	retainResources []string,
+	awsCloudFormation *cloudformation.CloudFormation,
+	logger *logrus.Logger) ([]*cloudformation.StackEvent, error) {
+
+	startTime := time.Now()
+	params := &cloudformation.DeleteStackInput{
+		StackName: aws.String(stackID),
+	}
+	if len(retainResources) > 0 {
+		params.RetainResources = aws.StringSlice(retainResources)
+	}
+	resp, err := awsCloudFormation.DeleteStack(params)
+	if nil != err {
+		return nil, err
+	}
+	logger.Info("Stack delete issued: ", resp)
+
+	stackInfo, err := waitForStackOperationComplete(stackID, startTime, awsCloudFormation, logger)
+	if nil != err {
+		return nil, err
+	}
+	if *stackInfo.StackStatus == cloudformation.StackStatusDeleteComplete {
+		return nil, nil
+	}
+	failedEvents, err := stackFailureEvents(stackID, startTime, awsCloudFormation)
+	if nil != err {
+		return nil, err
+	}
+	// Only report the most recent failure for each resource
+	var deleteFailures []*cloudformation.StackEvent
+	reported := make(map[string]bool, 0)
+	for index := len(failedEvents) - 1; index >= 0; index-- {
+		eachEvent := failedEvents[index]
+		if *eachEvent.ResourceStatus == cloudformation.ResourceStatusDeleteFailed &&
+			!reported[*eachEvent.LogicalResourceId] {
+			reported[*eachEvent.LogicalResourceId] = true
+			deleteFailures = append(deleteFailures, eachEvent)
+		}
+	}
+	if len(deleteFailures) <= 0 {
+		return nil, fmt.Errorf("Stack delete finished with status: %s", *stackInfo.StackStatus)
+	}
+	return deleteFailures, nil
+}
+
+// Delete the service's stackName stack and, if s3Bucket is defined, the
+// service artifacts
+func deleteServiceStack(serviceName string,
+	stackName string,
+	s3Bucket string,
+	retainResources []string,
+	awsSession *session.Session,
+	logger *logrus.Logger) error {
 
-	exists, err := stackExists(serviceName, awsCloudFormation, logger)
+	awsCloudFormation := cloudformation.New(awsSession)
+
+	exists, err := stackExists(stackName, awsCloudFormation, logger)
 	if nil != err {
 		return err
 	}
 	if exists {
-		logger.Info("Stack exists: ", serviceName)
-		params := &cloudformation.DeleteStackInput{
-			StackName: aws.String(serviceName),
+		logger.Info("Stack exists: ", stackName)
+		describeStacksOutput, err := awsCloudFormation.DescribeStacks(&cloudformation.DescribeStacksInput{
+			StackName: aws.String(stackName),
+		})
+		if nil != err {
+			return err
 		}
-		resp, err := awsCloudFormation.DeleteStack(params)
-		if nil != resp {
-			logger.Info("Stack delete issued: ", resp)
+		stackInfo := describeStacksOutput.Stacks[0]
+		stackID := *stackInfo.StackId
+
+		// RetainResources is only valid for stacks that previously
+		// failed to delete
+		var retained []string
+		if *stackInfo.StackStatus == cloudformation.StackStatusDeleteFailed {
+			retained = retainResources
+		}
+		deleteFailures, err := deleteStack(stackID, retained, awsCloudFormation, logger)
+		if nil != err {
+			return err
 		}
-		return err
-	}
 
-	logger.Info("Stack does not exist: ", serviceName)
+		// Retry with the failed resources that the caller is willing to retain
+		if len(deleteFailures) > 0 && len(retained) <= 0 && len(retainResources) > 0 {
+			retained = nil
+			for _, eachEvent := range deleteFailures {
+				for _, eachRetain := range retainResources {
+					if eachRetain == *eachEvent.LogicalResourceId {
+						retained = append(retained, eachRetain)
+					}
+				}
+			}
+			if len(retained) > 0 {
+				logger.Info("Retrying stack delete. Retaining resources: ", strings.Join(retained, ", "))
+				deleteFailures, err = deleteStack(stackID, retained, awsCloudFormation, logger)
+				if nil != err {
+					return err
+				}
+			}
+		}
+
+		if len(deleteFailures) > 0 {
+			logger.Error("Stack delete failed.")
+			var failedResources []string
+			for _, eachEvent := range deleteFailures {
+				logger.WithFields(logrus.Fields{
+					"Resource": *eachEvent.LogicalResourceId,
+					"Type":     aws.StringValue(eachEvent.ResourceType),
+					"Reason":   aws.StringValue(eachEvent.ResourceStatusReason),
+				}).Error("\tFailed to delete resource")
+				failedResources = append(failedResources, *eachEvent.LogicalResourceId)
+			}
+			logger.Info("Use -r/--retain to retain resources that block stack deletion")
+			return fmt.Errorf("Failed to delete %s. Resources: %s",
+				stackName,
+				strings.Join(failedResources, ", "))
+		}
+		logger.Info("Stack deleted: ", stackName)
+	} else {
+		logger.Info("Stack does not exist: ", stackName)
+	}
+	if "" != s3Bucket {
+		err := pruneArtifacts(stackName, s3Bucket, 0, true, awsSession, logger)
+		if nil != err {
+			return err
+		}
+		return deleteDeploymentHistory(stackName, s3Bucket, awsSession, logger)
+	}
 	return nil
 }
+
+// Delete the provided serviceName and wait for the stack deletion to complete.
+// Failing to delete a non-existent service is not considered an error.  If the
+// deletion fails, the resources that could not be deleted are reported.
+func Delete(serviceName string, logger *logrus.Logger) error {
+	return DeleteEx(serviceName, "", nil, logger)
+}
+
+// DeleteEx deletes the provided serviceName like Delete().  Resources whose
+// logical IDs are included in retainResources (eg, non-empty S3 buckets) are
+// retained if they block the deletion.  If s3Bucket is defined, all code
+// archives and templates uploaded on behalf of the service are deleted once
+// the stack is deleted, along with the deployment history.
+func DeleteEx(serviceName string, s3Bucket string, retainResources []string, logger *logrus.Logger) error {
+	return deleteServiceStack(serviceName, serviceName, s3Bucket, retainResources, awsSession(logger), logger)
+}
+
+// DeleteTargets deletes the provided serviceName from each of the deployment
+// targets.  Each target's service artifacts are deleted from the target's S3
+// bucket.  See DeleteEx() for more information.
+func DeleteTargets(serviceName string, targets []*DeploymentTarget, retainResources []string, logger *logrus.Logger) error {
+	return forEachTarget(serviceName, targets, "delete", logger, func(target *DeploymentTarget, targetSession *session.Session) error {
+		return deleteServiceStack(serviceName,
+			target.stackName(serviceName),
+			target.S3Bucket,
+			retainResources,
+			targetSession,
+			logger)
+	})
+}
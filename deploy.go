@@ -0,0 +1,232 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Deploy provisions (either via create or update) the service artifacts
+// previously written to inputDir by Package().  The s3Bucket value is
+// required if the package was created without an S3 bucket.  If both
+// are defined, they must match.  The optional options value defines the
+// stack tags, parameters and create/update behavior.  Versioned Lambda
+// aliases are routed directly to the new version; LambdaVersionOptions
+// TrafficShift schedules are only applied by Provision().
+func Deploy(serviceName string, inputDir string, s3Bucket string, options *ProvisionOptions, logger *logrus.Logger) error {
+	manifest, err := readPackageManifest(inputDir)
+	if nil != err {
+		return err
+	}
+	if manifest.ServiceName != serviceName {
+		return fmt.Errorf("Package in %s was created for service %s, not %s",
+			inputDir,
+			manifest.ServiceName,
+			serviceName)
+	}
+	if manifest.SpartaVersion != SpartaVersion {
+		logger.WithFields(logrus.Fields{
+			"PackageVersion": manifest.SpartaVersion,
+			"SpartaVersion":  SpartaVersion,
+		}).Warn("Package was created with a different Sparta version")
+	}
+
+	templateBody, err := ioutil.ReadFile(filepath.Join(inputDir, manifest.Template))
+	if nil != err {
+		return fmt.Errorf("Failed to read CloudFormation template: %s", err.Error())
+	}
+
+	// Resolve the bucket the template should refer to
+	replaceBucket := false
+	switch {
+	case "" == s3Bucket && manifest.S3Bucket == PackageS3BucketPlaceholder:
+		return fmt.Errorf("Package in %s requires an S3 bucket (-b/--s3Bucket)", inputDir)
+	case "" == s3Bucket:
+		s3Bucket = manifest.S3Bucket
+	case manifest.S3Bucket == PackageS3BucketPlaceholder:
+		replaceBucket = true
+	case manifest.S3Bucket != s3Bucket:
+		return fmt.Errorf("Package in %s was created for S3 bucket %s, not %s",
+			inputDir,
+			manifest.S3Bucket,
+			s3Bucket)
+	}
+
+	replacePlaceholder := func(body []byte) []byte {
+		return bytes.Replace(body,
+			[]byte(fmt.Sprintf("\"%s\"", PackageS3BucketPlaceholder)),
+			[]byte(fmt.Sprintf("\"%s\"", s3Bucket)),
+			-1)
+	}
+	// Nested stack templates are stored under their content hash, so
+	// replacing the bucket name changes the key the parent refers to
+	nestedTemplates := make(map[string][]byte, 0)
+	for _, eachName := range manifest.NestedTemplates {
+		nestedBody, err := ioutil.ReadFile(filepath.Join(inputDir, eachName))
+		if nil != err {
+			return fmt.Errorf("Failed to read nested stack template: %s", err.Error())
+		}
+		nestedKey := eachName
+		if replaceBucket {
+			nestedBody = replacePlaceholder(nestedBody)
+			nestedKey = templateS3Key(serviceName, nestedBody)
+			templateBody = bytes.Replace(templateBody,
+				[]byte(fmt.Sprintf("\"%s\"", eachName)),
+				[]byte(fmt.Sprintf("\"%s\"", nestedKey)),
+				-1)
+		}
+		nestedTemplates[nestedKey] = nestedBody
+	}
+	if replaceBucket {
+		templateBody = replacePlaceholder(templateBody)
+	}
+
+	ctx := &workflowContext{
+		serviceName:     serviceName,
+		stackName:       serviceName,
+		s3Bucket:        s3Bucket,
+		options:         options,
+		nestedTemplates: nestedTemplates,
+		awsSession:      awsSession(logger),
+		logger:          logger,
+	}
+	uploader := s3manager.NewUploader(ctx.awsSession)
+
+	// Keys are content-addressable, so an object that already exists may be
+	// referenced by the current stack.  Only the objects that this deploy
+	// created are deleted if the stack isn't updated.
+	var createdKeys []string
+	deleteCreatedObjects := func() {
+		for _, eachKey := range createdKeys {
+			logger.Info("Attempting to cleanup S3 object: ", eachKey)
+			_, deleteErr := s3.New(ctx.awsSession).DeleteObject(&s3.DeleteObjectInput{
+				Bucket: aws.String(s3Bucket),
+				Key:    aws.String(eachKey),
+			})
+			if nil != deleteErr {
+				logger.Warn("Failed to delete S3 object: ", eachKey)
+			}
+		}
+	}
+	// Returns true if the object exists.  Otherwise the key is recorded as
+	// created by this deploy.
+	objectExists := func(keyName string) (bool, error) {
+		exists, err := s3ObjectExists(s3Bucket, keyName, ctx.awsSession)
+		if nil == err && !exists {
+			createdKeys = append(createdKeys, keyName)
+		}
+		return exists, err
+	}
+	uploadTemplate := func(keyName string, body []byte) (*s3manager.UploadOutput, error) {
+		_, err := objectExists(keyName)
+		if nil != err {
+			return nil, err
+		}
+		return uploader.Upload(&s3manager.UploadInput{
+			Bucket:      aws.String(s3Bucket),
+			Key:         aws.String(keyName),
+			ContentType: aws.String("application/json"),
+			Body:        bytes.NewReader(body),
+		})
+	}
+
+	// Code archives
+	for _, eachArchive := range manifest.codeArchives() {
+		exists, err := objectExists(eachArchive)
+		if nil == err && exists {
+			logger.WithFields(logrus.Fields{
+				"Bucket": s3Bucket,
+				"Key":    eachArchive,
+			}).Info("Bypassing S3 ZIP upload for unchanged archive")
+			continue
+		}
+		if nil == err {
+			err = uploadArchive(uploader, filepath.Join(inputDir, eachArchive), s3Bucket, eachArchive, logger)
+		}
+		if nil != err {
+			deleteCreatedObjects()
+			return err
+		}
+	}
+	hookContext := ctx.hookContext(manifest.CodeArchive)
+	hookContext.Template = templateBody
+	err = runWorkflowHooks(ctx, hookPhasePreTemplateUpload, hookContext)
+	if nil != err {
+		deleteCreatedObjects()
+		return err
+	}
+	var nestedKeys []string
+	for eachKey := range nestedTemplates {
+		nestedKeys = append(nestedKeys, eachKey)
+	}
+	sort.Strings(nestedKeys)
+	for _, eachKey := range nestedKeys {
+		uploadResult, err := uploadTemplate(eachKey, nestedTemplates[eachKey])
+		if nil != err {
+			deleteCreatedObjects()
+			return err
+		}
+		logger.Info("Nested stack template uploaded: ", uploadResult.Location)
+	}
+
+	// Template, whose key changes if the bucket name was substituted
+	templateKey := templateS3Key(serviceName, templateBody)
+	logger.Info("Uploading CloudFormation template")
+	templateUploadResult, err := uploadTemplate(templateKey, templateBody)
+	if nil != err {
+		deleteCreatedObjects()
+		return err
+	}
+	logger.Info("CloudFormation template uploaded: ", templateUploadResult.Location)
+
+	stack, err := convergeStackState(templateUploadResult.Location, ctx)
+	if nil != err {
+		deleteCreatedObjects()
+		return err
+	}
+	logger.Info("Stack provisioned: ", stack)
+	recordServiceDeployment(ctx, templateKey, templateUploadResult.Location, manifest.codeArchives())
+	hookContext.Outputs = stackOutputs(stack)
+	err = runWorkflowHooks(ctx, hookPhasePostConverge, hookContext)
+	if nil != err {
+		return err
+	}
+	pruneServiceArtifacts(ctx)
+	return nil
+}
+
+// Upload a packaged ZIP archive to S3
+func uploadArchive(uploader *s3manager.Uploader,
+	archivePath string,
+	s3Bucket string,
+	keyName string,
+	logger *logrus.Logger) error {
+
+	archiveReader, err := os.Open(archivePath)
+	if nil != err {
+		return fmt.Errorf("Failed to open ZIP archive: %s", err.Error())
+	}
+	defer archiveReader.Close()
+	logger.Info("Uploading ZIP archive to S3")
+	archiveUploadResult, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s3Bucket),
+		Key:         aws.String(keyName),
+		ContentType: aws.String("application/zip"),
+		Body:        archiveReader,
+	})
+	if nil != err {
+		return err
+	}
+	logger.Info("ZIP archive uploaded: ", archiveUploadResult.Location)
+	return nil
+}
@@ -42,7 +42,7 @@ func Describe(serviceName string, serviceDescription string, lambdaAWSInfos []*L
 		// Export the template and insert it into an HTML page.  Let the page do the work...
 
 		for _, eachEntry := range ctx.lambdaAWSInfos {
-			err := eachEntry.export(ctx.s3Bucket, s3Key, ctx.lambdaIAMRoleNameMap, ctx.cloudformationResources, ctx.cloudformationOutputs, ctx.logger)
+			err := eachEntry.export(ctx.s3Bucket, s3Key, ctx.lambdaIAMRoleNameMap, ctx.provisionOptions().Config, ctx.cloudformationResources, ctx.cloudformationOutputs, ctx.logger)
 			if nil != err {
 				return nil, err
 			}
@@ -0,0 +1,199 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// Template sections compared by Diff
+var diffTemplateSections = []string{"Parameters", "Resources", "Outputs"}
+
+// Returns the named template section, or an empty map if the
+// section isn't defined
+func templateSection(template map[string]interface{}, sectionName string) map[string]interface{} {
+	section, _ := template[sectionName].(map[string]interface{})
+	if nil == section {
+		section = make(map[string]interface{}, 0)
+	}
+	return section
+}
+
+// Returns the CloudFormation type of a template section entry, if any
+func templateEntryType(entry interface{}) string {
+	if entryMap, ok := entry.(map[string]interface{}); ok {
+		if entryType, ok := entryMap["Type"].(string); ok {
+			return entryType
+		}
+	}
+	return ""
+}
+
+// Write the differences between the stack's current template and the new
+// template to writer.  Returns the number of changed entries.
+func templateDiff(newTemplate []byte, stackTemplate []byte, writer io.Writer) (int, error) {
+	var newValues map[string]interface{}
+	err := json.Unmarshal(newTemplate, &newValues)
+	if nil != err {
+		return 0, err
+	}
+	stackValues := make(map[string]interface{}, 0)
+	if len(stackTemplate) > 0 {
+		err = json.Unmarshal(stackTemplate, &stackValues)
+		if nil != err {
+			return 0, fmt.Errorf("Failed to parse stack template: %s", err.Error())
+		}
+	}
+
+	changeCount := 0
+	for _, eachSectionName := range diffTemplateSections {
+		newSection := templateSection(newValues, eachSectionName)
+		stackSection := templateSection(stackValues, eachSectionName)
+
+		keyMap := make(map[string]bool, 0)
+		for eachKey := range newSection {
+			keyMap[eachKey] = true
+		}
+		for eachKey := range stackSection {
+			keyMap[eachKey] = true
+		}
+		var keys []string
+		for eachKey := range keyMap {
+			keys = append(keys, eachKey)
+		}
+		sort.Strings(keys)
+
+		for _, eachKey := range keys {
+			newEntry, newExists := newSection[eachKey]
+			stackEntry, stackExists := stackSection[eachKey]
+			change := ""
+			entryType := templateEntryType(newEntry)
+			switch {
+			case newExists && !stackExists:
+				change = "+"
+			case !newExists && stackExists:
+				change = "-"
+				entryType = templateEntryType(stackEntry)
+			case !reflect.DeepEqual(newEntry, stackEntry):
+				change = "~"
+			}
+			if "" == change {
+				continue
+			}
+			changeCount++
+			if "" != entryType {
+				fmt.Fprintf(writer, "  %s %s.%s (%s)\n", change, eachSectionName, eachKey, entryType)
+			} else {
+				fmt.Fprintf(writer, "  %s %s.%s\n", change, eachSectionName, eachKey)
+			}
+		}
+	}
+	if changeCount <= 0 {
+		fmt.Fprintf(writer, "  No changes\n")
+	}
+	return changeCount, nil
+}
+
+// Build the template for the stack and write its differences from
+// the provisioned template to writer
+func diffStack(ctx *workflowContext, writer io.Writer) error {
+	// Build the template without uploading or provisioning anything
+	ctx.noop = true
+	err := runWorkflow(ctx)
+	if nil != err {
+		return err
+	}
+	if len(ctx.templateBody) <= 0 {
+		return errors.New("Failed to generate CloudFormation template")
+	}
+	stackTemplate, err := currentStackTemplate(ctx.stackName, ctx.awsSession, ctx.logger)
+	if nil != err {
+		return err
+	}
+	if "" == stackTemplate {
+		fmt.Fprintf(writer, "Stack: %s (does not exist)\n", ctx.stackName)
+	} else {
+		fmt.Fprintf(writer, "Stack: %s\n", ctx.stackName)
+	}
+	changeCount, err := templateDiff(ctx.templateBody, []byte(stackTemplate), writer)
+	if nil != err {
+		return err
+	}
+	ctx.logger.WithFields(logrus.Fields{
+		"StackName":   ctx.stackName,
+		"ChangeCount": changeCount,
+	}).Info("Template diff complete")
+	return nil
+}
+
+// Diff compares the CloudFormation template for the service with the template
+// of the currently provisioned stack and writes the added (+), removed (-) and
+// modified (~) Parameters, Resources and Outputs to writer.  Nothing is
+// uploaded or provisioned.
+func Diff(serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	s3Bucket string,
+	options *ProvisionOptions,
+	writer io.Writer,
+	logger *logrus.Logger) error {
+
+	if len(lambdaAWSInfos) <= 0 {
+		return errors.New("No lambda functions provided to Sparta.Diff()")
+	}
+	ctx := &workflowContext{
+		serviceName:             serviceName,
+		stackName:               serviceName,
+		serviceDescription:      serviceDescription,
+		lambdaAWSInfos:          lambdaAWSInfos,
+		api:                     api,
+		cloudformationResources: make(ArbitraryJSONObject, 0),
+		cloudformationOutputs:   make(ArbitraryJSONObject, 0),
+		s3Bucket:                s3Bucket,
+		options:                 options,
+		awsSession:              awsSession(logger),
+		logger:                  logger,
+	}
+	return diffStack(ctx, writer)
+}
+
+// DiffTargets compares the service template with the provisioned stack in each
+// of the deployment targets.  See Diff() for more information.
+func DiffTargets(serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	targets []*DeploymentTarget,
+	options *ProvisionOptions,
+	writer io.Writer,
+	logger *logrus.Logger) error {
+
+	if len(lambdaAWSInfos) <= 0 {
+		return errors.New("No lambda functions provided to Sparta.DiffTargets()")
+	}
+	return forEachTarget(serviceName, targets, "diff", logger, func(target *DeploymentTarget, targetSession *session.Session) error {
+		ctx := &workflowContext{
+			serviceName:             serviceName,
+			stackName:               target.stackName(serviceName),
+			serviceDescription:      serviceDescription,
+			lambdaAWSInfos:          lambdaAWSInfos,
+			api:                     api,
+			cloudformationResources: make(ArbitraryJSONObject, 0),
+			cloudformationOutputs:   make(ArbitraryJSONObject, 0),
+			s3Bucket:                target.S3Bucket,
+			options:                 options,
+			awsSession:              targetSession,
+			logger:                  logger,
+		}
+		return diffStack(ctx, writer)
+	})
+}
@@ -0,0 +1,49 @@
+package sparta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTemplateDiff(t *testing.T) {
+	stackTemplate := `{
+		"Resources": {
+			"Unchanged": {"Type": "AWS::SNS::Topic"},
+			"Modified": {"Type": "AWS::Lambda::Function", "Properties": {"Code": {"S3Key": "old.zip"}}},
+			"Removed": {"Type": "AWS::S3::Bucket"}
+		}
+	}`
+	newTemplate := `{
+		"Resources": {
+			"Unchanged": {"Type": "AWS::SNS::Topic"},
+			"Modified": {"Type": "AWS::Lambda::Function", "Properties": {"Code": {"S3Key": "new.zip"}}},
+			"Added": {"Type": "AWS::IAM::Role"}
+		},
+		"Outputs": {
+			"SpartaVersion": {"Value": "0.0.7"}
+		}
+	}`
+	var output bytes.Buffer
+	changeCount, err := templateDiff([]byte(newTemplate), []byte(stackTemplate), &output)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if changeCount != 4 {
+		t.Errorf("Expected 4 changes, got %d: %s", changeCount, output.String())
+	}
+	expected := []string{
+		"+ Resources.Added (AWS::IAM::Role)",
+		"~ Resources.Modified (AWS::Lambda::Function)",
+		"- Resources.Removed (AWS::S3::Bucket)",
+		"+ Outputs.SpartaVersion",
+	}
+	for _, eachLine := range expected {
+		if !strings.Contains(output.String(), eachLine) {
+			t.Errorf("Expected diff line: %s", eachLine)
+		}
+	}
+	if strings.Contains(output.String(), "Unchanged") {
+		t.Errorf("Unchanged resource reported in diff")
+	}
+}
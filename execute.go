@@ -3,12 +3,16 @@ package sparta
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 )
 
 // Port used for HTTP proxying communication
@@ -16,21 +20,79 @@ const defaultHTTPPort = 9999
 
 type dispatchMap map[string]*LambdaAWSInfo
 
+// Formatter that adds request-scoped fields to every entry
+type requestFieldsFormatter struct {
+	fields    logrus.Fields
+	formatter logrus.Formatter
+}
+
+func (formatter *requestFieldsFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+len(formatter.fields))
+	for eachKey, eachValue := range formatter.fields {
+		data[eachKey] = eachValue
+	}
+	// Fields supplied by the caller take precedence
+	for eachKey, eachValue := range entry.Data {
+		data[eachKey] = eachValue
+	}
+	requestEntry := *entry
+	requestEntry.Data = data
+	return formatter.formatter.Format(&requestEntry)
+}
+
+// io.Writer that serializes the writes of all the loggers that share it.
+// Each logrus.Logger only serializes its own writes.
+type synchronizedWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (writer *synchronizedWriter) Write(data []byte) (int, error) {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	return writer.writer.Write(data)
+}
+
+// Wrap the logger's output in a synchronizedWriter so that the request
+// loggers derived from it don't interleave their entries
+func synchronizeLoggerOutput(logger *logrus.Logger) {
+	if _, isSynchronized := logger.Out.(*synchronizedWriter); !isSynchronized {
+		logger.Out = &synchronizedWriter{writer: logger.Out}
+	}
+}
+
+// Returns a logger whose entries include the request's AWSRequestID,
+// FunctionName and FunctionVersion.  The logger shares the output of logger,
+// which must be synchronized with synchronizeLoggerOutput before requests
+// are dispatched concurrently.
+func requestLogger(logger *logrus.Logger, context *LambdaContext) *logrus.Logger {
+	return &logrus.Logger{
+		Out:   logger.Out,
+		Hooks: logger.Hooks,
+		Level: logger.Level,
+		Formatter: &requestFieldsFormatter{
+			fields: logrus.Fields{
+				"AWSRequestID":    context.AWSRequestID,
+				"FunctionName":    context.FunctionName,
+				"FunctionVersion": context.FunctionVersion,
+			},
+			formatter: logger.Formatter,
+		},
+	}
+}
+
 type lambdaHandler struct {
 	lambdaDispatchMap dispatchMap
+	config            LambdaConfig
 	logger            *logrus.Logger
+	// Number of dispatched requests.  Accessed atomically.
+	requestCount int64
 }
 
-func (handler *lambdaHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+// Dispatch the request to the golang handler registered for path
+func (handler *lambdaHandler) dispatch(path string, request *lambdaRequest, w http.ResponseWriter) {
 	// Remove the leading slash and dispatch it to the golang handler
-	lambdaFunc := strings.TrimLeft(req.URL.Path, "/")
-	decoder := json.NewDecoder(req.Body)
-	var request lambdaRequest
-	err := decoder.Decode(&request)
-	if nil != err {
-		http.Error(w, "Failed to decode request", http.StatusBadRequest)
-		return
-	}
+	lambdaFunc := strings.TrimLeft(path, "/")
 	handler.logger.WithFields(logrus.Fields{
 		"Request": request,
 	}).Debug("Dispatching")
@@ -40,24 +102,155 @@ func (handler *lambdaHandler) ServeHTTP(w http.ResponseWriter, req *http.Request
 		http.Error(w, "Unsupported path: "+lambdaFunc, http.StatusBadRequest)
 		return
 	}
-	lambdaAWSInfo.lambdaFn(&request.Event, &request.Context, w, handler.logger)
+	request.Context.Config = handler.config
+	request.Context.Metrics = newMetrics(request.Context.FunctionName)
+	processColdStart := (1 == atomic.AddInt64(&handler.requestCount, 1))
+	// Proxies that don't report the container state are treated as if each
+	// golang process were a new container
+	request.Context.ColdStart = processColdStart
+	if nil != request.Container {
+		request.Context.ColdStart = request.Container.ColdStart
+	}
+	logger := requestLogger(handler.logger, &request.Context)
+	if nil != request.Container {
+		recordContainerStatus(request.Container, request.Context.Metrics, logger)
+	}
+
+	statusWriter := &statusResponseWriter{ResponseWriter: w}
+	startTime := time.Now()
+	lambdaAWSInfo.lambdaFn(&request.Event, &request.Context, statusWriter, logger)
+
+	// Record the invocation metrics and publish them before the
+	// response is completed
+	sink := currentMetricsSink()
+	if nil == sink {
+		return
+	}
+	metrics := request.Context.Metrics
+	metrics.Timing(MetricDuration, time.Since(startTime), nil)
+	metrics.Count(MetricInvocations, 1, nil)
+	if statusWriter.code != 0 && (statusWriter.code < 200 || statusWriter.code >= 300) {
+		metrics.Count(MetricErrors, 1, nil)
+	}
+	if request.Context.ColdStart {
+		metrics.Count(MetricColdStarts, 1, nil)
+	}
+	err := metrics.flush(sink)
+	if nil != err {
+		logger.Warn("Failed to publish metrics: ", err.Error())
+	}
+}
+
+// Log the container state reported by the NodeJS proxy and, if enabled,
+// record it as metrics
+func recordContainerStatus(status *containerStatus, metrics *Metrics, logger *logrus.Logger) {
+	fields := logrus.Fields{
+		"ColdStart":       status.ColdStart,
+		"InvocationCount": status.InvocationCount,
+	}
+	var totalDuration float64
+	for eachPhase, eachDuration := range status.Phases {
+		fields[fmt.Sprintf("%sMs", eachPhase)] = eachDuration
+		totalDuration += eachDuration
+	}
+	if status.Respawns > 0 {
+		fields["Respawns"] = status.Respawns
+	}
+	if len(status.Phases) > 0 || status.Respawns > 0 {
+		logger.WithFields(fields).Info("Golang process started")
+	} else {
+		logger.WithFields(fields).Debug("Container invocation")
+	}
+	if !status.Metrics {
+		return
+	}
+	if len(status.Phases) > 0 {
+		milliseconds := func(value float64) time.Duration {
+			return time.Duration(value * float64(time.Millisecond))
+		}
+		metrics.Timing(MetricColdStartDuration, milliseconds(totalDuration), nil)
+		for eachPhase, eachDuration := range status.Phases {
+			metrics.Timing(MetricColdStartDuration, milliseconds(eachDuration), map[string]string{
+				"Phase": eachPhase,
+			})
+		}
+	}
+	if status.Respawns > 0 {
+		metrics.Count(MetricRespawns, float64(status.Respawns), nil)
+	}
+	metrics.Gauge(MetricContainerInvocations,
+		float64(status.InvocationCount),
+		cloudwatch.StandardUnitCount,
+		nil)
+}
+
+func (handler *lambdaHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var request lambdaRequest
+	err := decoder.Decode(&request)
+	if nil != err {
+		http.Error(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	handler.dispatch(req.URL.Path, &request, w)
 }
 
 // Execute creates an HTTP listener to dispatch execution. Typically
 // called via Main() via command line arguments.
 func Execute(lambdaAWSInfos []*LambdaAWSInfo, port int, parentProcessPID int, logger *logrus.Logger) error {
+	return ExecuteEx(lambdaAWSInfos, TransportHTTP, port, "", parentProcessPID, logger)
+}
+
+// ExecuteEx dispatches execution requests received over the given transport.
+// The TransportHTTP listener binds to port.  The TransportUnixSocket listener
+// binds to socketPath.  The TransportStdio transport reads requests from
+// stdin.  Typically called via Main() via command line arguments.
+func ExecuteEx(lambdaAWSInfos []*LambdaAWSInfo,
+	transport string,
+	port int,
+	socketPath string,
+	parentProcessPID int,
+	logger *logrus.Logger) error {
+
 	if port <= 0 {
 		port = defaultHTTPPort
 	}
-	logger.Info("Execute!")
+	if "" == transport {
+		transport = TransportHTTP
+	}
+	logger.WithFields(logrus.Fields{
+		"Transport": transport,
+	}).Info("Execute!")
 
 	lookupMap := make(dispatchMap, 0)
 	for _, eachLambdaInfo := range lambdaAWSInfos {
 		lookupMap[eachLambdaInfo.lambdaFnName] = eachLambdaInfo
 	}
+	config, err := loadLambdaConfig(logger)
+	if nil != err {
+		logger.Error("Failed to load config: " + err.Error())
+		return err
+	}
+	// Framed transports dispatch requests concurrently
+	synchronizeLoggerOutput(logger)
+	handler := &lambdaHandler{
+		lambdaDispatchMap: lookupMap,
+		config:            config,
+		logger:            logger,
+	}
+	switch transport {
+	case TransportHTTP:
+		// Handled below
+	case TransportStdio:
+		return executeStdio(handler, logger)
+	case TransportUnixSocket:
+		return executeUnixSocket(handler, socketPath, parentProcessPID, logger)
+	default:
+		return fmt.Errorf("Unsupported transport: %s", transport)
+	}
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      &lambdaHandler{lookupMap, logger},
+		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
@@ -66,7 +259,7 @@ func Execute(lambdaAWSInfos []*LambdaAWSInfo, port int, parentProcessPID int, lo
 		syscall.Kill(parentProcessPID, syscall.SIGUSR2)
 	}
 	logger.Debug("Binding to port: ", port)
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil {
 		logger.Error("FAILURE: " + err.Error())
 		return err
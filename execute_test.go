@@ -0,0 +1,92 @@
+package sparta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestRequestLogger(t *testing.T) {
+	logger, err := NewLogger("info")
+	var output bytes.Buffer
+	logger.Out = &output
+	logger.Formatter = new(logrus.JSONFormatter)
+
+	context := &LambdaContext{
+		AWSRequestID:    "7494a1b4-9b8a-11e5-8a2e-a1b2c3d4e5f6",
+		FunctionName:    "SampleFunction",
+		FunctionVersion: "$LATEST",
+	}
+	requestLogger(logger, context).WithFields(logrus.Fields{
+		"Key": "Value",
+	}).Info("Hello World")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single log line: %s", output.String())
+	}
+	var entry map[string]interface{}
+	err = json.Unmarshal([]byte(lines[0]), &entry)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := map[string]string{
+		"AWSRequestID":    context.AWSRequestID,
+		"FunctionName":    context.FunctionName,
+		"FunctionVersion": context.FunctionVersion,
+		"Key":             "Value",
+		"msg":             "Hello World",
+	}
+	for eachKey, eachValue := range expected {
+		if entry[eachKey] != eachValue {
+			t.Errorf("Unexpected %s value: %v", eachKey, entry[eachKey])
+		}
+	}
+}
+
+func TestRequestLoggerConcurrentWrites(t *testing.T) {
+	logger, err := NewLogger("info")
+	var output bytes.Buffer
+	logger.Out = &output
+	logger.Formatter = new(logrus.JSONFormatter)
+	synchronizeLoggerOutput(logger)
+	synchronizeLoggerOutput(logger)
+	if _, isSynchronized := logger.Out.(*synchronizedWriter); !isSynchronized {
+		t.Fatalf("Expected synchronized logger output: %#v", logger.Out)
+	}
+	if logger.Out.(*synchronizedWriter).writer != &output {
+		t.Fatal("Expected logger output to be synchronized once")
+	}
+
+	var waitGroup sync.WaitGroup
+	requestCount := 32
+	for index := 0; index < requestCount; index++ {
+		waitGroup.Add(1)
+		go func(index int) {
+			defer waitGroup.Done()
+			context := &LambdaContext{
+				AWSRequestID: fmt.Sprintf("request-%d", index),
+			}
+			requestLogger(logger, context).Info(strings.Repeat("x", 4096))
+			logger.Info("Parent logger")
+		}(index)
+	}
+	waitGroup.Wait()
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2*requestCount {
+		t.Fatalf("Expected %d log lines, got: %d", 2*requestCount, len(lines))
+	}
+	for _, eachLine := range lines {
+		var entry map[string]interface{}
+		err = json.Unmarshal([]byte(eachLine), &entry)
+		if nil != err {
+			t.Fatalf("Interleaved log line: %s", err.Error())
+		}
+	}
+}
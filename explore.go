@@ -13,9 +13,12 @@ import (
 
 type provisionedResources []*cloudformation.StackResourceSummary
 
+// Returns the AWS::Lambda::Function resources in the stack, including those
+// in nested stacks
 func stackLambdaResources(serviceName string, cf *cloudformation.CloudFormation, logger *logrus.Logger) (provisionedResources, error) {
 
 	resources := make(provisionedResources, 0)
+	var nestedStackIDs []string
 	nextToken := ""
 	for {
 		params := &cloudformation.ListStackResourcesInput{
@@ -33,6 +36,8 @@ func stackLambdaResources(serviceName string, cf *cloudformation.CloudFormation,
 		for _, eachSummary := range resp.StackResourceSummaries {
 			if *eachSummary.ResourceType == "AWS::Lambda::Function" {
 				resources = append(resources, eachSummary)
+			} else if *eachSummary.ResourceType == "AWS::CloudFormation::Stack" && nil != eachSummary.PhysicalResourceId {
+				nestedStackIDs = append(nestedStackIDs, *eachSummary.PhysicalResourceId)
 			}
 		}
 		if nil != resp.NextToken {
@@ -41,6 +46,13 @@ func stackLambdaResources(serviceName string, cf *cloudformation.CloudFormation,
 			break
 		}
 	}
+	for _, eachNestedStackID := range nestedStackIDs {
+		nestedResources, err := stackLambdaResources(eachNestedStackID, cf, logger)
+		if nil != err {
+			return nil, err
+		}
+		resources = append(resources, nestedResources...)
+	}
 	return resources, nil
 }
 
@@ -0,0 +1,339 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// Maximum number of deployments recorded in the history
+const maxDeploymentHistoryCount = 50
+
+// deploymentRecord describes a successful provision, deploy or rollback
+type deploymentRecord struct {
+	Timestamp time.Time
+	// S3 keyname and URL of the template applied to the stack
+	TemplateKey string
+	TemplateURL string
+	// S3 keyname of the code archive referenced by the template
+	CodeArchiveKey string
+	// S3 keynames of all the code archives, if the service used split
+	// packages.  The first archive is the CodeArchiveKey.
+	CodeArchiveKeys []string `json:",omitempty"`
+	// S3 keynames of the nested stack templates referenced by the template
+	NestedTemplateKeys []string `json:",omitempty"`
+	// HEAD commit of the working directory's git repository, if any
+	GitSHA        string `json:",omitempty"`
+	SpartaVersion string
+	// True if the deployment re-applied a previous template
+	Rollback bool `json:",omitempty"`
+}
+
+// Returns the S3 keynames of the template, nested stack templates and code
+// archives recorded for the deployment
+func (record *deploymentRecord) artifactKeys() []string {
+	artifactKeys := []string{record.TemplateKey}
+	artifactKeys = append(artifactKeys, record.NestedTemplateKeys...)
+	if len(record.CodeArchiveKeys) > 0 {
+		return append(artifactKeys, record.CodeArchiveKeys...)
+	}
+	return append(artifactKeys, record.CodeArchiveKey)
+}
+
+// Returns the S3 keyname of the stack's deployment history.  The keyname
+// doesn't match serviceArtifactRegexp s.t. it's never pruned.
+func deploymentHistoryKey(stackName string) string {
+	return fmt.Sprintf("%s-deployments.json", sanitizedName(stackName))
+}
+
+// Returns the HEAD commit of the working directory's git repository, or an
+// empty string if it's not available
+func gitHeadSHA() string {
+	output, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if nil != err {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Returns the stack's deployment history, oldest first.  The history is empty
+// if the stack has never been provisioned.
+func readDeploymentHistory(stackName string, s3Bucket string, awsSession *session.Session) ([]*deploymentRecord, error) {
+	getObjectOutput, err := s3.New(awsSession).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(deploymentHistoryKey(stackName)),
+	})
+	if nil != err {
+		if awsErr, ok := err.(awserr.RequestFailure); ok && awsErr.StatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer getObjectOutput.Body.Close()
+	historyJSON, err := ioutil.ReadAll(getObjectOutput.Body)
+	if nil != err {
+		return nil, err
+	}
+	var history []*deploymentRecord
+	err = json.Unmarshal(historyJSON, &history)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse deployment history: %s", err.Error())
+	}
+	return history, nil
+}
+
+// Returns the history with the record appended, limited to the newest
+// maxDeploymentHistoryCount records
+func appendDeploymentRecord(history []*deploymentRecord, record *deploymentRecord) []*deploymentRecord {
+	history = append(history, record)
+	if len(history) > maxDeploymentHistoryCount {
+		history = history[len(history)-maxDeploymentHistoryCount:]
+	}
+	return history
+}
+
+// Delete the stack's deployment history
+func deleteDeploymentHistory(stackName string,
+	s3Bucket string,
+	awsSession *session.Session,
+	logger *logrus.Logger) error {
+
+	_, err := s3.New(awsSession).DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(deploymentHistoryKey(stackName)),
+	})
+	if nil != err {
+		return err
+	}
+	logger.WithFields(logrus.Fields{
+		"Bucket": s3Bucket,
+		"Key":    deploymentHistoryKey(stackName),
+	}).Info("Deleted deployment history")
+	return nil
+}
+
+// Append a record to the stack's deployment history
+func recordDeployment(stackName string,
+	s3Bucket string,
+	record *deploymentRecord,
+	awsSession *session.Session,
+	logger *logrus.Logger) error {
+
+	history, err := readDeploymentHistory(stackName, s3Bucket, awsSession)
+	if nil != err {
+		return err
+	}
+	historyJSON, err := json.MarshalIndent(appendDeploymentRecord(history, record), "", " ")
+	if nil != err {
+		return err
+	}
+	_, err = s3manager.NewUploader(awsSession).Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(s3Bucket),
+		Key:         aws.String(deploymentHistoryKey(stackName)),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(historyJSON),
+	})
+	if nil != err {
+		return err
+	}
+	logger.WithFields(logrus.Fields{
+		"Bucket":      s3Bucket,
+		"Key":         deploymentHistoryKey(stackName),
+		"TemplateKey": record.TemplateKey,
+	}).Debug("Recorded deployment")
+	return nil
+}
+
+// Record a successful deployment.  Failing to record the deployment doesn't
+// fail the provision.
+func recordServiceDeployment(ctx *workflowContext, templateKey string, templateURL string, codeArchiveKeys []string) {
+	record := &deploymentRecord{
+		Timestamp:      time.Now().UTC(),
+		TemplateKey:    templateKey,
+		TemplateURL:    templateURL,
+		CodeArchiveKey: codeArchiveKeys[0],
+		GitSHA:         gitHeadSHA(),
+		SpartaVersion:  SpartaVersion,
+	}
+	if len(codeArchiveKeys) > 1 {
+		record.CodeArchiveKeys = codeArchiveKeys
+	}
+	for eachKey := range ctx.nestedTemplates {
+		record.NestedTemplateKeys = append(record.NestedTemplateKeys, eachKey)
+	}
+	sort.Strings(record.NestedTemplateKeys)
+	// A new stack doesn't inherit the history of a deleted stack with the
+	// same name
+	if ctx.stackCreated {
+		err := deleteDeploymentHistory(ctx.stackName, ctx.s3Bucket, ctx.awsSession, ctx.logger)
+		if nil != err {
+			ctx.logger.Warn("Failed to reset deployment history: ", err.Error())
+		}
+	}
+	err := recordDeployment(ctx.stackName, ctx.s3Bucket, record, ctx.awsSession, ctx.logger)
+	if nil != err {
+		ctx.logger.Warn("Failed to record deployment history: ", err.Error())
+	}
+}
+
+// Returns the record that precedes the newest record by to deployments
+func rollbackRecord(history []*deploymentRecord, to int) (*deploymentRecord, error) {
+	if to < 1 {
+		return nil, fmt.Errorf("Invalid rollback target: %d", to)
+	}
+	if to >= len(history) {
+		return nil, fmt.Errorf("Rollback target %d exceeds deployment history (%d deployments)", to, len(history))
+	}
+	return history[len(history)-1-to], nil
+}
+
+// Returns the S3 keynames of the record's template, the nested stack
+// templates it references and its code archives
+func deploymentArtifactKeys(record *deploymentRecord, s3Bucket string, awsSession *session.Session) ([]string, error) {
+	getObjectOutput, err := s3.New(awsSession).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(record.TemplateKey),
+	})
+	if nil != err {
+		if awsErr, ok := err.(awserr.RequestFailure); ok && awsErr.StatusCode() == http.StatusNotFound {
+			return nil, fmt.Errorf("Deployment artifact %s no longer exists in bucket %s", record.TemplateKey, s3Bucket)
+		}
+		return nil, err
+	}
+	defer getObjectOutput.Body.Close()
+	var template map[string]interface{}
+	err = json.NewDecoder(getObjectOutput.Body).Decode(&template)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse template %s: %s", record.TemplateKey, err.Error())
+	}
+	artifactKeys := nestedTemplateKeys(template)
+	if len(record.CodeArchiveKeys) > 0 {
+		artifactKeys = append(artifactKeys, record.CodeArchiveKeys...)
+	} else {
+		artifactKeys = append(artifactKeys, record.CodeArchiveKey)
+	}
+	return artifactKeys, nil
+}
+
+// Rollback re-applies the template of a previous deployment of the service,
+// without rebuilding or repackaging it.  Provision and Deploy record each
+// successful deployment in the s3Bucket.  The to value is the number of
+// deployments to go back.  A value of 1 re-applies the template of the
+// deployment that preceded the current one.  If noop is true, the deployment
+// history is logged and the stack is not updated.  The optional options value
+// defines the stack tags, parameters and update behavior.
+//
+// Rollback fails if a code archive, the template or a nested stack template
+// of the selected deployment has been pruned from the bucket.
+func Rollback(noop bool, serviceName string, s3Bucket string, to int, options *ProvisionOptions, logger *logrus.Logger) error {
+	return rollbackServiceStack(noop, serviceName, serviceName, s3Bucket, to, options, awsSession(logger), logger)
+}
+
+// RollbackTargets rolls back the provided serviceName in each of the
+// deployment targets, using each target's stack name and S3 bucket.  See
+// Rollback() for more information.
+func RollbackTargets(noop bool, serviceName string, targets []*DeploymentTarget, to int, options *ProvisionOptions, logger *logrus.Logger) error {
+	return forEachTarget(serviceName, targets, "rollback", logger, func(target *DeploymentTarget, targetSession *session.Session) error {
+		return rollbackServiceStack(noop,
+			serviceName,
+			target.stackName(serviceName),
+			target.S3Bucket,
+			to,
+			options,
+			targetSession,
+			logger)
+	})
+}
+
+// Rollback the stackName stack of the service
+func rollbackServiceStack(noop bool,
+	serviceName string,
+	stackName string,
+	s3Bucket string,
+	to int,
+	options *ProvisionOptions,
+	awsSession *session.Session,
+	logger *logrus.Logger) error {
+
+	ctx := &workflowContext{
+		noop:        noop,
+		serviceName: serviceName,
+		stackName:   stackName,
+		s3Bucket:    s3Bucket,
+		options:     options,
+		awsSession:  awsSession,
+		logger:      logger,
+	}
+	history, err := readDeploymentHistory(ctx.stackName, s3Bucket, ctx.awsSession)
+	if nil != err {
+		return err
+	}
+	logger.WithFields(logrus.Fields{
+		"StackName": ctx.stackName,
+		"Bucket":    s3Bucket,
+	}).Info("Deployment history")
+	for index := len(history) - 1; index >= 0; index-- {
+		eachRecord := history[index]
+		logger.WithFields(logrus.Fields{
+			"Timestamp":      eachRecord.Timestamp.Format(time.RFC3339),
+			"TemplateKey":    eachRecord.TemplateKey,
+			"CodeArchiveKey": eachRecord.CodeArchiveKey,
+			"GitSHA":         eachRecord.GitSHA,
+			"Rollback":       eachRecord.Rollback,
+		}).Info(fmt.Sprintf("\tDeployment %d", len(history)-1-index))
+	}
+	record, err := rollbackRecord(history, to)
+	if nil != err {
+		return err
+	}
+	artifactKeys, err := deploymentArtifactKeys(record, s3Bucket, ctx.awsSession)
+	if nil != err {
+		return err
+	}
+	for _, eachKey := range artifactKeys {
+		exists, err := s3ObjectExists(s3Bucket, eachKey, ctx.awsSession)
+		if nil != err {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("Deployment artifact %s no longer exists in bucket %s", eachKey, s3Bucket)
+		}
+	}
+	logger.WithFields(logrus.Fields{
+		"Deployment":  to,
+		"TemplateKey": record.TemplateKey,
+		"GitSHA":      record.GitSHA,
+	}).Info("Rolling back")
+	if noop {
+		logger.Info("Bypassing rollback due to -n/-noop command line argument")
+		return nil
+	}
+	stack, err := convergeStackState(record.TemplateURL, ctx)
+	if nil != err {
+		return err
+	}
+	logger.Info("Stack provisioned: ", stack)
+	rollback := *record
+	rollback.Timestamp = time.Now().UTC()
+	rollback.Rollback = true
+	err = recordDeployment(ctx.stackName, s3Bucket, &rollback, ctx.awsSession, logger)
+	if nil != err {
+		logger.Warn("Failed to record deployment history: ", err.Error())
+	}
+	return nil
+}
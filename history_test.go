@@ -0,0 +1,85 @@
+package sparta
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func testDeploymentHistory(count int) []*deploymentRecord {
+	var history []*deploymentRecord
+	for index := 0; index < count; index++ {
+		history = appendDeploymentRecord(history, &deploymentRecord{
+			TemplateKey: fmt.Sprintf("SampleProvision-%040d-cf.json", index),
+		})
+	}
+	return history
+}
+
+func TestAppendDeploymentRecord(t *testing.T) {
+	history := testDeploymentHistory(maxDeploymentHistoryCount + 5)
+	if len(history) != maxDeploymentHistoryCount {
+		t.Fatalf("Unexpected history length: %d", len(history))
+	}
+	if history[0].TemplateKey != fmt.Sprintf("SampleProvision-%040d-cf.json", 5) {
+		t.Errorf("Expected oldest records to be discarded: %s", history[0].TemplateKey)
+	}
+}
+
+func TestRollbackRecord(t *testing.T) {
+	history := testDeploymentHistory(3)
+	record, err := rollbackRecord(history, 1)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if record != history[1] {
+		t.Errorf("Unexpected rollback record: %s", record.TemplateKey)
+	}
+	record, err = rollbackRecord(history, 2)
+	if nil != err || record != history[0] {
+		t.Errorf("Unexpected rollback record: %#v", record)
+	}
+	for _, eachInvalid := range []int{0, 3} {
+		_, err = rollbackRecord(history, eachInvalid)
+		if nil == err {
+			t.Errorf("Expected rollback target %d to be invalid", eachInvalid)
+		}
+	}
+}
+
+func TestDeploymentHistoryKey(t *testing.T) {
+	if serviceArtifactRegexp("SampleProvision").MatchString(deploymentHistoryKey("SampleProvision")) {
+		t.Error("Deployment history must not be pruned")
+	}
+}
+
+func TestDeleteDeploymentHistory(t *testing.T) {
+	logger, _ := NewLogger("info")
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	awsSession := session.New(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		MaxRetries:       aws.Int(0),
+	})
+	err := deleteDeploymentHistory("SampleProvision", "SampleBucket", awsSession, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"DELETE /SampleBucket/SampleProvision-deployments.json"}
+	if !reflect.DeepEqual(expected, requests) {
+		t.Errorf("Unexpected deployment history requests: %v", requests)
+	}
+}
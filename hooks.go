@@ -0,0 +1,71 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Names of the hook phases, used in log and error messages
+const (
+	hookPhasePostBuild         = "PostBuild"
+	hookPhasePreTemplateUpload = "PreTemplateUpload"
+	hookPhasePostConverge      = "PostConverge"
+)
+
+// Returns the hook context for the provision
+func (ctx *workflowContext) hookContext(codeArchiveKey string) *WorkflowHookContext {
+	return &WorkflowHookContext{
+		ServiceName:    ctx.serviceName,
+		StackName:      ctx.stackName,
+		S3Bucket:       ctx.s3Bucket,
+		CodeArchiveKey: codeArchiveKey,
+		AWSSession:     ctx.awsSession,
+		Logger:         ctx.logger,
+	}
+}
+
+// Returns the stack outputs as a map
+func stackOutputs(stack *cloudformation.Stack) map[string]string {
+	outputs := make(map[string]string, 0)
+	if nil != stack {
+		for _, eachOutput := range stack.Outputs {
+			outputs[aws.StringValue(eachOutput.OutputKey)] = aws.StringValue(eachOutput.OutputValue)
+		}
+	}
+	return outputs
+}
+
+// Call the phase's hooks in order, stopping at the first error
+func runWorkflowHooks(ctx *workflowContext, phase string, hookContext *WorkflowHookContext) error {
+	options := ctx.provisionOptions()
+	if nil == options.Hooks {
+		return nil
+	}
+	var hooks []WorkflowHook
+	switch phase {
+	case hookPhasePostBuild:
+		hooks = options.Hooks.PostBuild
+	case hookPhasePreTemplateUpload:
+		hooks = options.Hooks.PreTemplateUpload
+	case hookPhasePostConverge:
+		hooks = options.Hooks.PostConverge
+	default:
+		return fmt.Errorf("Unsupported hook phase: %s", phase)
+	}
+	for index, eachHook := range hooks {
+		ctx.logger.WithFields(logrus.Fields{
+			"Phase": phase,
+			"Index": index,
+		}).Info("Calling workflow hook")
+		err := eachHook(hookContext)
+		if nil != err {
+			return fmt.Errorf("%s hook %d failed: %s", phase, index, err.Error())
+		}
+	}
+	return nil
+}
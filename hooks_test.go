@@ -0,0 +1,64 @@
+package sparta
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWorkflowHooks(t *testing.T) {
+	logger, err := NewLogger("info")
+	var calls []string
+	hook := func(name string, err error) WorkflowHook {
+		return func(context *WorkflowHookContext) error {
+			if context.ServiceName != "SampleProvision" {
+				t.Errorf("Unexpected hook context: %#v", context)
+			}
+			calls = append(calls, name)
+			return err
+		}
+	}
+	ctx := &workflowContext{
+		serviceName: "SampleProvision",
+		stackName:   "SampleProvision",
+		options: &ProvisionOptions{
+			Hooks: &WorkflowHooks{
+				PostBuild: []WorkflowHook{
+					hook("build1", nil),
+					hook("build2", nil),
+				},
+				PostConverge: []WorkflowHook{
+					hook("converge1", errors.New("Smoke test failed")),
+					hook("converge2", nil),
+				},
+			},
+		},
+		logger: logger,
+	}
+	err = runWorkflowHooks(ctx, hookPhasePostBuild, ctx.hookContext("S3Key"))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	err = runWorkflowHooks(ctx, hookPhasePreTemplateUpload, ctx.hookContext("S3Key"))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	err = runWorkflowHooks(ctx, hookPhasePostConverge, ctx.hookContext("S3Key"))
+	if nil == err {
+		t.Fatal("Expected failing hook to return an error")
+	}
+	expected := []string{"build1", "build2", "converge1"}
+	if len(calls) != len(expected) {
+		t.Fatalf("Unexpected hook calls: %v", calls)
+	}
+	for index, eachCall := range expected {
+		if calls[index] != eachCall {
+			t.Errorf("Unexpected hook calls: %v", calls)
+		}
+	}
+	// Undefined hooks are a NOP
+	ctx.options = nil
+	err = runWorkflowHooks(ctx, hookPhasePostBuild, ctx.hookContext("S3Key"))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+}
@@ -17,11 +17,60 @@ func Delete(serviceName string, logger *logrus.Logger) error {
 	return errors.New("Delete not supported for this binary")
 }
 
+func DeleteEx(serviceName string, s3Bucket string, retainResources []string, logger *logrus.Logger) error {
+	logger.Error("DeleteEx() not supported in AWS Lambda binary")
+	return errors.New("DeleteEx not supported for this binary")
+}
+
+func DeleteTargets(serviceName string, targets []*DeploymentTarget, retainResources []string, logger *logrus.Logger) error {
+	logger.Error("DeleteTargets() not supported in AWS Lambda binary")
+	return errors.New("DeleteTargets not supported for this binary")
+}
+
+func Diff(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, s3Bucket string, options *ProvisionOptions, writer io.Writer, logger *logrus.Logger) error {
+	logger.Error("Diff() not supported in AWS Lambda binary")
+	return errors.New("Diff not supported for this binary")
+}
+
+func DiffTargets(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, targets []*DeploymentTarget, options *ProvisionOptions, writer io.Writer, logger *logrus.Logger) error {
+	logger.Error("DiffTargets() not supported in AWS Lambda binary")
+	return errors.New("DiffTargets not supported for this binary")
+}
+
 func Provision(noop bool, serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, s3Bucket string, writer io.Writer, logger *logrus.Logger) error {
 	logger.Error("Deploy() not supported in AWS Lambda binary")
 	return errors.New("Deploy not supported for this binary")
 
 }
+func ProvisionEx(noop bool, serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, s3Bucket string, options *ProvisionOptions, writer io.Writer, logger *logrus.Logger) error {
+	logger.Error("ProvisionEx() not supported in AWS Lambda binary")
+	return errors.New("ProvisionEx not supported for this binary")
+}
+func ProvisionTargets(noop bool, serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, targets []*DeploymentTarget, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("ProvisionTargets() not supported in AWS Lambda binary")
+	return errors.New("ProvisionTargets not supported for this binary")
+}
+
+func Validate(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("Validate() not supported in AWS Lambda binary")
+	return errors.New("Validate not supported for this binary")
+}
+
+func Package(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, s3Bucket string, outputDir string, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("Package() not supported in AWS Lambda binary")
+	return errors.New("Package not supported for this binary")
+}
+
+func Deploy(serviceName string, inputDir string, s3Bucket string, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("Deploy() not supported in AWS Lambda binary")
+	return errors.New("Deploy not supported for this binary")
+}
+
+func Prune(serviceName string, s3Bucket string, keepCount int, logger *logrus.Logger) error {
+	logger.Error("Prune() not supported in AWS Lambda binary")
+	return errors.New("Prune not supported for this binary")
+}
+
 func Describe(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API, outputWriter io.Writer, logger *logrus.Logger) error {
 	logger.Error("Describe() not supported in AWS Lambda binary")
 	return errors.New("Describe not supported for this binary")
@@ -31,3 +80,23 @@ func Explore(serviceName string, logger *logrus.Logger) error {
 	logger.Error("Explore() not supported in AWS Lambda binary")
 	return errors.New("Explore not supported for this binary")
 }
+
+func Logs(serviceName string, options *LogsOptions, writer io.Writer, logger *logrus.Logger) error {
+	logger.Error("Logs() not supported in AWS Lambda binary")
+	return errors.New("Logs not supported for this binary")
+}
+
+func Status(serviceName string, outputFormat string, writer io.Writer, logger *logrus.Logger) error {
+	logger.Error("Status() not supported in AWS Lambda binary")
+	return errors.New("Status not supported for this binary")
+}
+
+func Rollback(noop bool, serviceName string, s3Bucket string, to int, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("Rollback() not supported in AWS Lambda binary")
+	return errors.New("Rollback not supported for this binary")
+}
+
+func RollbackTargets(noop bool, serviceName string, targets []*DeploymentTarget, to int, options *ProvisionOptions, logger *logrus.Logger) error {
+	logger.Error("RollbackTargets() not supported in AWS Lambda binary")
+	return errors.New("RollbackTargets not supported for this binary")
+}
@@ -0,0 +1,291 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// How often log groups are polled when following
+var logsPollInterval = 5 * time.Second
+
+// How far before a log group's latest event each poll starts.  CloudWatch
+// Logs events can be ingested after events with later timestamps.
+var logsFollowLookback = time.Minute
+
+// Subset of the CloudWatch Logs API used to tail log groups
+type logEventsFilterer interface {
+	FilterLogEventsPages(*cloudwatchlogs.FilterLogEventsInput, func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool) error
+}
+
+// Log event with the name of the function that produced it
+type functionLogEvent struct {
+	functionName string
+	event        *cloudwatchlogs.FilteredLogEvent
+}
+
+// Returns the log group name for the given function
+func lambdaLogGroupName(functionName string) string {
+	return fmt.Sprintf("/aws/lambda/%s", functionName)
+}
+
+// Returns the physical names of the functions that match the options
+func logsFunctionNames(resources provisionedResources, options *LogsOptions) []string {
+	var functionNames []string
+	for _, eachResource := range resources {
+		physicalName := aws.StringValue(eachResource.PhysicalResourceId)
+		if "" == physicalName {
+			continue
+		}
+		if "" != options.Function &&
+			!strings.Contains(physicalName, options.Function) &&
+			!strings.Contains(aws.StringValue(eachResource.LogicalResourceId), options.Function) {
+			continue
+		}
+		functionNames = append(functionNames, physicalName)
+	}
+	sort.Strings(functionNames)
+	return functionNames
+}
+
+// Poll state of a single function's log group
+type logGroupCursor struct {
+	// Start time (milliseconds) of the next poll
+	startTime int64
+	// Timestamps of the written events that may be returned by the next poll,
+	// keyed by EventId
+	writtenEventIDs map[string]int64
+}
+
+// Returns the events for each function's log group that were produced at or
+// after the function's start time, in timestamp order.  Log groups are
+// queried concurrently.  Missing log groups (functions that have never been
+// invoked) are ignored.
+func filterLogEvents(client logEventsFilterer,
+	functionNames []string,
+	startTimes map[string]int64,
+	filter string) ([]*functionLogEvent, error) {
+
+	var events []*functionLogEvent
+	var errs []string
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, eachFunctionName := range functionNames {
+		wg.Add(1)
+		go func(functionName string) {
+			defer wg.Done()
+			params := &cloudwatchlogs.FilterLogEventsInput{
+				LogGroupName: aws.String(lambdaLogGroupName(functionName)),
+				StartTime:    aws.Int64(startTimes[functionName]),
+				Interleaved:  aws.Bool(true),
+			}
+			if "" != filter {
+				params.FilterPattern = aws.String(filter)
+			}
+			var groupEvents []*functionLogEvent
+			err := client.FilterLogEventsPages(params, func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+				for _, eachEvent := range page.Events {
+					groupEvents = append(groupEvents, &functionLogEvent{
+						functionName: functionName,
+						event:        eachEvent,
+					})
+				}
+				return true
+			})
+			mutex.Lock()
+			defer mutex.Unlock()
+			if nil != err {
+				if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "ResourceNotFoundException" {
+					errs = append(errs, fmt.Sprintf("%s: %s", functionName, err.Error()))
+				}
+				return
+			}
+			events = append(events, groupEvents...)
+		}(eachFunctionName)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Failed to filter log events: %s", strings.Join(errs, ", "))
+	}
+	sort.Stable(functionLogEventsByTime(events))
+	return events, nil
+}
+
+type functionLogEventsByTime []*functionLogEvent
+
+func (events functionLogEventsByTime) Len() int {
+	return len(events)
+}
+func (events functionLogEventsByTime) Swap(i, j int) {
+	events[i], events[j] = events[j], events[i]
+}
+func (events functionLogEventsByTime) Less(i, j int) bool {
+	return aws.Int64Value(events[i].event.Timestamp) < aws.Int64Value(events[j].event.Timestamp)
+}
+
+// Returns the value of the first key that's defined in the JSON log entry
+func logEntryValue(entry map[string]interface{}, keys ...string) string {
+	for _, eachKey := range keys {
+		value, exists := entry[eachKey]
+		if exists {
+			delete(entry, eachKey)
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return ""
+}
+
+// Returns the human readable form of a log event.  JSON object messages
+// produced by the logrus JSONFormatter and the NodeJS proxy are formatted as
+// `LEVEL message key=value...`.  Other messages are returned as is.
+func formatLogEvent(logEvent *functionLogEvent) string {
+	timestamp := time.Unix(0, aws.Int64Value(logEvent.event.Timestamp)*int64(time.Millisecond)).UTC()
+	message := strings.TrimSpace(aws.StringValue(logEvent.event.Message))
+	// Console output is prefixed by the timestamp and request ID
+	if fields := strings.SplitN(message, "\t", 3); len(fields) == 3 {
+		message = fields[2]
+	}
+	var entry map[string]interface{}
+	if strings.HasPrefix(message, "{") && nil == json.Unmarshal([]byte(message), &entry) {
+		level := strings.ToUpper(logEntryValue(entry, "level", "LEVEL"))
+		if "" == level {
+			level = "INFO"
+		}
+		msg := logEntryValue(entry, "msg", "MSG")
+		logEntryValue(entry, "time", "TIME")
+		var keys []string
+		for eachKey := range entry {
+			keys = append(keys, eachKey)
+		}
+		sort.Strings(keys)
+		fields := []string{level, msg}
+		for _, eachKey := range keys {
+			fields = append(fields, fmt.Sprintf("%s=%v", eachKey, entry[eachKey]))
+		}
+		message = strings.Join(fields, " ")
+	}
+	return fmt.Sprintf("%s [%s] %s",
+		timestamp.Format(time.RFC3339),
+		logEvent.functionName,
+		message)
+}
+
+// Writes the log events produced since each log group's cursor that haven't
+// already been written, then advances the cursors.  Each log group is
+// re-read from logsFollowLookback before its latest event so that events
+// ingested late are still written.
+func pollLogEvents(client logEventsFilterer,
+	functionNames []string,
+	cursors map[string]*logGroupCursor,
+	filter string,
+	writer io.Writer) error {
+
+	startTimes := make(map[string]int64, len(cursors))
+	for eachName, eachCursor := range cursors {
+		startTimes[eachName] = eachCursor.startTime
+	}
+	events, err := filterLogEvents(client, functionNames, startTimes, filter)
+	if nil != err {
+		return err
+	}
+	lookback := int64(logsFollowLookback / time.Millisecond)
+	for _, eachEvent := range events {
+		cursor := cursors[eachEvent.functionName]
+		eventID := aws.StringValue(eachEvent.event.EventId)
+		if _, written := cursor.writtenEventIDs[eventID]; written {
+			continue
+		}
+		fmt.Fprintln(writer, formatLogEvent(eachEvent))
+		timestamp := aws.Int64Value(eachEvent.event.Timestamp)
+		cursor.writtenEventIDs[eventID] = timestamp
+		if timestamp-lookback > cursor.startTime {
+			cursor.startTime = timestamp - lookback
+		}
+	}
+	// Events before the cursor won't be returned again
+	for _, eachCursor := range cursors {
+		for eachEventID, eachTimestamp := range eachCursor.writtenEventIDs {
+			if eachTimestamp < eachCursor.startTime {
+				delete(eachCursor.writtenEventIDs, eachEventID)
+			}
+		}
+	}
+	return nil
+}
+
+// Writes the log events for the functions to writer.  If options.Follow is
+// true, new events are written until the process is terminated.
+func tailLogs(client logEventsFilterer,
+	functionNames []string,
+	options *LogsOptions,
+	writer io.Writer,
+	logger *logrus.Logger) error {
+
+	since := options.Since
+	if since <= 0 {
+		since = DefaultLogsSince
+	}
+	startTime := time.Now().Add(-since).UnixNano() / int64(time.Millisecond)
+	cursors := make(map[string]*logGroupCursor, len(functionNames))
+	for _, eachFunctionName := range functionNames {
+		cursors[eachFunctionName] = &logGroupCursor{
+			startTime:       startTime,
+			writtenEventIDs: make(map[string]int64, 0),
+		}
+	}
+	for {
+		err := pollLogEvents(client, functionNames, cursors, options.Filter, writer)
+		if nil != err {
+			return err
+		}
+		if !options.Follow {
+			return nil
+		}
+		logger.Debug("Polling for log events")
+		time.Sleep(logsPollInterval)
+	}
+}
+
+// Logs writes the CloudWatch Logs events of the previously provisioned
+// service's Lambda functions to writer, merged in timestamp order.  A nil
+// *LogsOptions value is equivalent to the zero value.
+func Logs(serviceName string, options *LogsOptions, writer io.Writer, logger *logrus.Logger) error {
+	if nil == options {
+		options = &LogsOptions{}
+	}
+	session := awsSession(logger)
+	awsCloudFormation := cloudformation.New(session)
+
+	exists, err := stackExists(serviceName, awsCloudFormation, logger)
+	if nil != err {
+		return err
+	} else if !exists {
+		return fmt.Errorf("Stack does not exist: %s", serviceName)
+	}
+	resources, err := stackLambdaResources(serviceName, awsCloudFormation, logger)
+	if nil != err {
+		return err
+	}
+	functionNames := logsFunctionNames(resources, options)
+	if len(functionNames) <= 0 {
+		return fmt.Errorf("No Lambda functions found in stack %s", serviceName)
+	}
+	logger.WithFields(logrus.Fields{
+		"Functions": functionNames,
+		"Filter":    options.Filter,
+		"Follow":    options.Follow,
+	}).Info("Fetching log events")
+	return tailLogs(cloudwatchlogs.New(session), functionNames, options, writer, logger)
+}
@@ -0,0 +1,154 @@
+package sparta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// Returns canned events for each log group
+type fakeLogEventsFilterer struct {
+	events map[string][]*cloudwatchlogs.FilteredLogEvent
+}
+
+func (fake *fakeLogEventsFilterer) FilterLogEventsPages(params *cloudwatchlogs.FilterLogEventsInput,
+	fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool) error {
+	events, exists := fake.events[*params.LogGroupName]
+	if !exists {
+		return awserr.New("ResourceNotFoundException", "The specified log group does not exist.", nil)
+	}
+	// Return one event per page
+	for index, eachEvent := range events {
+		if *eachEvent.Timestamp < *params.StartTime {
+			continue
+		}
+		page := &cloudwatchlogs.FilterLogEventsOutput{
+			Events: []*cloudwatchlogs.FilteredLogEvent{eachEvent},
+		}
+		if !fn(page, index == len(events)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func testLogEvent(eventID string, timestamp int64, message string) *cloudwatchlogs.FilteredLogEvent {
+	return &cloudwatchlogs.FilteredLogEvent{
+		EventId:   aws.String(eventID),
+		Timestamp: aws.Int64(timestamp),
+		Message:   aws.String(message),
+	}
+}
+
+func TestTailLogs(t *testing.T) {
+	logger, err := NewLogger("info")
+	// 2016-01-01T00:00:00Z
+	timestamp := int64(1451606400000)
+	client := &fakeLogEventsFilterer{
+		events: map[string][]*cloudwatchlogs.FilteredLogEvent{
+			"/aws/lambda/SampleFunction1": {
+				testLogEvent("1", timestamp, `{"level":"info","msg":"Hello World","time":"2016-01-01T00:00:00Z","Key":"Value"}`),
+				testLogEvent("3", timestamp+2000, "2016-01-01T00:00:02.000Z\t7494a1b4-9b8a-11e5-8a2e-a1b2c3d4e5f6\t{\"MSG\":\"Sparta proxy\"}"),
+			},
+			"/aws/lambda/SampleFunction2": {
+				testLogEvent("2", timestamp+1000, "START RequestId: 7494a1b4-9b8a-11e5-8a2e-a1b2c3d4e5f6"),
+			},
+		},
+	}
+	options := &LogsOptions{
+		// Include all of the canned events
+		Since: 24 * 365 * 100 * time.Hour,
+	}
+	var output bytes.Buffer
+	err = tailLogs(client,
+		[]string{"SampleFunction1", "SampleFunction2", "UninvokedFunction"},
+		options,
+		&output,
+		logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"2016-01-01T00:00:00Z [SampleFunction1] INFO Hello World Key=Value",
+		"2016-01-01T00:00:01Z [SampleFunction2] START RequestId: 7494a1b4-9b8a-11e5-8a2e-a1b2c3d4e5f6",
+		"2016-01-01T00:00:02Z [SampleFunction1] INFO Sparta proxy",
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected log output:\n%s", output.String())
+	}
+}
+
+func TestPollLogEventsLateIngestion(t *testing.T) {
+	// 2016-01-01T00:00:00Z
+	timestamp := int64(1451606400000)
+	client := &fakeLogEventsFilterer{
+		events: map[string][]*cloudwatchlogs.FilteredLogEvent{
+			"/aws/lambda/SampleFunction1": {
+				testLogEvent("1", timestamp+10000, "First"),
+			},
+			"/aws/lambda/SampleFunction2": {
+				testLogEvent("2", timestamp, "Second"),
+			},
+		},
+	}
+	functionNames := []string{"SampleFunction1", "SampleFunction2"}
+	cursors := make(map[string]*logGroupCursor, 0)
+	for _, eachFunctionName := range functionNames {
+		cursors[eachFunctionName] = &logGroupCursor{
+			startTime:       timestamp,
+			writtenEventIDs: make(map[string]int64, 0),
+		}
+	}
+	var output bytes.Buffer
+	err := pollLogEvents(client, functionNames, cursors, "", &output)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	// Events ingested after the first poll, with timestamps that precede
+	// the latest written events
+	client.events["/aws/lambda/SampleFunction1"] = append(client.events["/aws/lambda/SampleFunction1"],
+		testLogEvent("3", timestamp+9000, "Third"))
+	client.events["/aws/lambda/SampleFunction2"] = append(client.events["/aws/lambda/SampleFunction2"],
+		testLogEvent("4", timestamp+5000, "Fourth"))
+	err = pollLogEvents(client, functionNames, cursors, "", &output)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"2016-01-01T00:00:00Z [SampleFunction2] Second",
+		"2016-01-01T00:00:10Z [SampleFunction1] First",
+		"2016-01-01T00:00:05Z [SampleFunction2] Fourth",
+		"2016-01-01T00:00:09Z [SampleFunction1] Third",
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected log output:\n%s", output.String())
+	}
+}
+
+func TestLogsFunctionNames(t *testing.T) {
+	resources := provisionedResources{
+		{
+			LogicalResourceId:  aws.String("Lambdaf27edd097c0a7d67f932156408a483b551247e39"),
+			PhysicalResourceId: aws.String("SampleProvision-Lambdaf27edd097c0a7d-1ABCDEF"),
+		},
+		{
+			LogicalResourceId:  aws.String("Lambdaa1fc2c3bc13e3313aa457d65263ae9225b18b734"),
+			PhysicalResourceId: aws.String("SampleProvision-Lambdaa1fc2c3bc13e3-2ABCDEF"),
+		},
+	}
+	names := logsFunctionNames(resources, &LogsOptions{})
+	if len(names) != 2 {
+		t.Errorf("Unexpected function names: %#v", names)
+	}
+	names = logsFunctionNames(resources, &LogsOptions{Function: "Lambdaa1fc"})
+	if len(names) != 1 || names[0] != "SampleProvision-Lambdaa1fc2c3bc13e3-2ABCDEF" {
+		t.Errorf("Unexpected filtered function names: %#v", names)
+	}
+}
@@ -0,0 +1,242 @@
+package sparta
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// MetricsNamespace is the CloudWatch namespace of the metrics published
+// by Lambda functions
+var MetricsNamespace = "Sparta"
+
+// Maximum number of values per PutMetricData request
+const metricsBatchSize = 20
+
+// Names of the metrics automatically recorded for each invocation if a
+// MetricsSink is enabled
+const (
+	// MetricInvocations is the number of invocations
+	MetricInvocations = "Invocations"
+	// MetricErrors is the number of invocations that returned a non-2XX status
+	MetricErrors = "Errors"
+	// MetricDuration is the handler duration in milliseconds
+	MetricDuration = "Duration"
+	// MetricColdStarts is the number of invocations for which
+	// LambdaContext.ColdStart is true: the first invocation of a container,
+	// or of the golang process if the NodeJS proxy doesn't report the
+	// container state
+	MetricColdStarts = "ColdStarts"
+)
+
+// Names of the container metrics recorded if ProvisionOptions.ContainerMetrics
+// is enabled
+const (
+	// MetricColdStartDuration is the time in milliseconds to start the golang
+	// process.  Values with a Phase dimension are the duration of the binary,
+	// spawn and ready phases.
+	MetricColdStartDuration = "ColdStartDuration"
+	// MetricRespawns is the number of golang process respawns
+	MetricRespawns = "Respawns"
+	// MetricContainerInvocations is the number of invocations handled by the
+	// container
+	MetricContainerInvocations = "ContainerInvocations"
+)
+
+// MetricDatum is a single metric value
+type MetricDatum struct {
+	Name string
+	// CloudWatch unit (eg, cloudwatch.StandardUnitCount)
+	Unit       string
+	Value      float64
+	Dimensions map[string]string
+	Timestamp  time.Time
+}
+
+// MetricsSink publishes batches of metric values
+type MetricsSink interface {
+	Publish(namespace string, data []*MetricDatum) error
+}
+
+// CloudWatchMetricsSink publishes metric values via the CloudWatch
+// PutMetricData API
+type CloudWatchMetricsSink struct {
+	cloudWatch *cloudwatch.CloudWatch
+}
+
+// NewCloudWatchMetricsSink returns a MetricsSink that publishes values to
+// CloudWatch in the Lambda function's region
+func NewCloudWatchMetricsSink() *CloudWatchMetricsSink {
+	return &CloudWatchMetricsSink{
+		cloudWatch: cloudwatch.New(session.New()),
+	}
+}
+
+// Publish the values in batches of up to 20
+func (sink *CloudWatchMetricsSink) Publish(namespace string, data []*MetricDatum) error {
+	for len(data) > 0 {
+		batchSize := len(data)
+		if batchSize > metricsBatchSize {
+			batchSize = metricsBatchSize
+		}
+		params := &cloudwatch.PutMetricDataInput{
+			Namespace: aws.String(namespace),
+		}
+		for _, eachDatum := range data[0:batchSize] {
+			datum := &cloudwatch.MetricDatum{
+				MetricName: aws.String(eachDatum.Name),
+				Unit:       aws.String(eachDatum.Unit),
+				Value:      aws.Float64(eachDatum.Value),
+				Timestamp:  aws.Time(eachDatum.Timestamp),
+			}
+			// Sort the dimensions s.t. requests are stable
+			var dimensionNames []string
+			for eachName := range eachDatum.Dimensions {
+				dimensionNames = append(dimensionNames, eachName)
+			}
+			sort.Strings(dimensionNames)
+			for _, eachName := range dimensionNames {
+				datum.Dimensions = append(datum.Dimensions, &cloudwatch.Dimension{
+					Name:  aws.String(eachName),
+					Value: aws.String(eachDatum.Dimensions[eachName]),
+				})
+			}
+			params.MetricData = append(params.MetricData, datum)
+		}
+		_, err := sink.cloudWatch.PutMetricData(params)
+		if nil != err {
+			return err
+		}
+		data = data[batchSize:]
+	}
+	return nil
+}
+
+// MemoryMetricsSink records published metric values.  It's intended for
+// tests.
+type MemoryMetricsSink struct {
+	mutex sync.Mutex
+	// Published values
+	Data []*MetricDatum
+}
+
+// Publish appends the values to Data
+func (sink *MemoryMetricsSink) Publish(namespace string, data []*MetricDatum) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.Data = append(sink.Data, data...)
+	return nil
+}
+
+var metricsSink MetricsSink
+var metricsSinkMutex sync.Mutex
+
+// SetMetricsSink replaces the MetricsSink used to publish metrics.  There is
+// no default sink: metric values are discarded unless a sink is set, either
+// with this function or by provisioning with ProvisionOptions.Metrics, which
+// sets a CloudWatchMetricsSink.
+func SetMetricsSink(sink MetricsSink) {
+	metricsSinkMutex.Lock()
+	defer metricsSinkMutex.Unlock()
+	metricsSink = sink
+}
+
+// Returns the current MetricsSink, or nil if metrics aren't enabled
+func currentMetricsSink() MetricsSink {
+	metricsSinkMutex.Lock()
+	defer metricsSinkMutex.Unlock()
+	return metricsSink
+}
+
+// Metrics batches the metric values recorded during a single invocation.
+// The values are published when the handler returns.  Every value includes
+// a FunctionName dimension.
+type Metrics struct {
+	mutex        sync.Mutex
+	functionName string
+	data         []*MetricDatum
+}
+
+func newMetrics(functionName string) *Metrics {
+	return &Metrics{
+		functionName: functionName,
+	}
+}
+
+// Record a value
+func (metrics *Metrics) record(name string, unit string, value float64, dimensions map[string]string) {
+	datumDimensions := map[string]string{
+		"FunctionName": metrics.functionName,
+	}
+	for eachKey, eachValue := range dimensions {
+		datumDimensions[eachKey] = eachValue
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.data = append(metrics.data, &MetricDatum{
+		Name:       name,
+		Unit:       unit,
+		Value:      value,
+		Dimensions: datumDimensions,
+		Timestamp:  time.Now(),
+	})
+}
+
+// Count records a counter value
+func (metrics *Metrics) Count(name string, count float64, dimensions map[string]string) {
+	metrics.record(name, cloudwatch.StandardUnitCount, count, dimensions)
+}
+
+// Timing records a duration in milliseconds
+func (metrics *Metrics) Timing(name string, duration time.Duration, dimensions map[string]string) {
+	metrics.record(name,
+		cloudwatch.StandardUnitMilliseconds,
+		float64(duration)/float64(time.Millisecond),
+		dimensions)
+}
+
+// Gauge records a point-in-time value.  The unit is one of the
+// cloudwatch.StandardUnit* values and defaults to None.
+func (metrics *Metrics) Gauge(name string, value float64, unit string, dimensions map[string]string) {
+	if "" == unit {
+		unit = cloudwatch.StandardUnitNone
+	}
+	metrics.record(name, unit, value, dimensions)
+}
+
+// Publish the recorded values to the sink
+func (metrics *Metrics) flush(sink MetricsSink) error {
+	metrics.mutex.Lock()
+	data := metrics.data
+	metrics.data = nil
+	metrics.mutex.Unlock()
+	if len(data) <= 0 {
+		return nil
+	}
+	return sink.Publish(MetricsNamespace, data)
+}
+
+// http.ResponseWriter that captures the handler's status code
+type statusResponseWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (writer *statusResponseWriter) WriteHeader(code int) {
+	if 0 == writer.code {
+		writer.code = code
+	}
+	writer.ResponseWriter.WriteHeader(code)
+}
+
+func (writer *statusResponseWriter) Write(data []byte) (int, error) {
+	if 0 == writer.code {
+		writer.code = http.StatusOK
+	}
+	return writer.ResponseWriter.Write(data)
+}
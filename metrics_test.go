@@ -0,0 +1,178 @@
+package sparta
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func metricsLambda(event *json.RawMessage, context *LambdaContext, w http.ResponseWriter, logger *logrus.Logger) {
+	context.Metrics.Count("Processed", 3, map[string]string{"Source": "Test"})
+	context.Metrics.Timing("Lookup", 1500*time.Microsecond, nil)
+	http.Error(w, "Failed", http.StatusInternalServerError)
+}
+
+func TestInvocationMetrics(t *testing.T) {
+	logger, err := NewLogger("info")
+	sink := &MemoryMetricsSink{}
+	SetMetricsSink(sink)
+	defer SetMetricsSink(nil)
+
+	lambdaFn := NewLambda(LambdaExecuteARN, metricsLambda, nil)
+	handler := &lambdaHandler{
+		lambdaDispatchMap: dispatchMap{lambdaFn.lambdaFnName: lambdaFn},
+		logger:            logger,
+	}
+	for index := 0; index < 2; index++ {
+		body := `{"event": {}, "context": {"functionName": "SampleFunction"}}`
+		var request *http.Request
+		request, err = http.NewRequest("POST", "/"+lambdaFn.lambdaFnName, strings.NewReader(body))
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	counts := make(map[string]float64, 0)
+	for _, eachDatum := range sink.Data {
+		if eachDatum.Dimensions["FunctionName"] != "SampleFunction" {
+			t.Errorf("Missing FunctionName dimension: %#v", eachDatum)
+		}
+		counts[eachDatum.Name] += eachDatum.Value
+	}
+	expected := map[string]float64{
+		MetricInvocations: 2,
+		MetricErrors:      2,
+		MetricColdStarts:  1,
+		"Processed":       6,
+		"Lookup":          3,
+	}
+	for eachName, eachValue := range expected {
+		if counts[eachName] != eachValue {
+			t.Errorf("Unexpected %s value: %f", eachName, counts[eachName])
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	logger, err := NewLogger("info")
+	SetMetricsSink(nil)
+
+	lambdaFn := NewLambda(LambdaExecuteARN, metricsLambda, nil)
+	handler := &lambdaHandler{
+		lambdaDispatchMap: dispatchMap{lambdaFn.lambdaFnName: lambdaFn},
+		logger:            logger,
+	}
+	body := `{"event": {}, "context": {"functionName": "SampleFunction"}}`
+	request, err := http.NewRequest("POST", "/"+lambdaFn.lambdaFnName, strings.NewReader(body))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Unexpected response code: %d", recorder.Code)
+	}
+	if nil != currentMetricsSink() {
+		t.Error("Expected metrics to be disabled by default")
+	}
+}
+
+func coldStartLambda(event *json.RawMessage, context *LambdaContext, w http.ResponseWriter, logger *logrus.Logger) {
+	if context.ColdStart {
+		w.Header().Set("X-Cold-Start", "true")
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestContainerMetrics(t *testing.T) {
+	logger, err := NewLogger("info")
+	sink := &MemoryMetricsSink{}
+	SetMetricsSink(sink)
+	defer SetMetricsSink(nil)
+
+	lambdaFn := NewLambda(LambdaExecuteARN, coldStartLambda, nil)
+	handler := &lambdaHandler{
+		lambdaDispatchMap: dispatchMap{lambdaFn.lambdaFnName: lambdaFn},
+		logger:            logger,
+	}
+	// The golang process was respawned after the container's first
+	// invocation, so its first request isn't a cold start.  The last
+	// request is the first invocation of a new container.
+	bodies := []string{
+		`{"event": {}, "context": {"functionName": "SampleFunction"}, "container": {"coldStart": false, "invocationCount": 2, "respawns": 1, "phases": {"binary": 0, "spawn": 2, "ready": 40}, "metrics": true}}`,
+		`{"event": {}, "context": {"functionName": "SampleFunction"}, "container": {"coldStart": false, "invocationCount": 3, "respawns": 0, "metrics": true}}`,
+		`{"event": {}, "context": {"functionName": "SampleFunction"}, "container": {"coldStart": true, "invocationCount": 1, "respawns": 0, "metrics": true}}`,
+	}
+	for index, eachBody := range bodies {
+		var request *http.Request
+		request, err = http.NewRequest("POST", "/"+lambdaFn.lambdaFnName, strings.NewReader(eachBody))
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		coldStart := "" != recorder.Header().Get("X-Cold-Start")
+		if coldStart != (index == len(bodies)-1) {
+			t.Errorf("Expected container state to determine LambdaContext.ColdStart: %s", eachBody)
+		}
+		// ColdStarts matches LambdaContext.ColdStart rather than the
+		// golang process' first request
+		coldStartCount := 0
+		for _, eachDatum := range sink.Data {
+			if MetricColdStarts == eachDatum.Name {
+				coldStartCount++
+			}
+		}
+		expectedCount := 0
+		if coldStart {
+			expectedCount = 1
+		}
+		if coldStartCount != expectedCount {
+			t.Errorf("Unexpected %s count after request %d: %d", MetricColdStarts, index, coldStartCount)
+		}
+	}
+
+	values := make(map[string]float64, 0)
+	for _, eachDatum := range sink.Data {
+		name := eachDatum.Name
+		if phase, exists := eachDatum.Dimensions["Phase"]; exists {
+			name = name + "." + phase
+		}
+		values[name] += eachDatum.Value
+	}
+	expected := map[string]float64{
+		MetricColdStarts:                   1,
+		MetricColdStartDuration:            42,
+		MetricColdStartDuration + ".ready": 40,
+		MetricRespawns:                     1,
+		MetricContainerInvocations:         6,
+	}
+	for eachName, eachValue := range expected {
+		if values[eachName] != eachValue {
+			t.Errorf("Unexpected %s value: %f", eachName, values[eachName])
+		}
+	}
+
+	// Requests without container state use the golang process state
+	request, err := http.NewRequest("POST",
+		"/"+lambdaFn.lambdaFnName,
+		strings.NewReader(`{"event": {}, "context": {"functionName": "SampleFunction"}}`))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	handler = &lambdaHandler{
+		lambdaDispatchMap: dispatchMap{lambdaFn.lambdaFnName: lambdaFn},
+		logger:            logger,
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if "" == recorder.Header().Get("X-Cold-Start") {
+		t.Error("Expected golang process' first request to be a cold start")
+	}
+}
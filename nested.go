@@ -0,0 +1,607 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// CloudFormation template limits.  See
+// http://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/cloudformation-limits.html
+const (
+	maxTemplateResourceCount  = 200
+	maxTemplateParameterCount = 60
+	maxTemplateBodySize       = 460800
+)
+
+// The fraction of a template limit at which Lambda resources are moved
+// into nested stacks
+const nestedStackThreshold = 0.8
+
+// RE for removing characters that are illegal in logical IDs
+var reLogicalIDIllegal = regexp.MustCompile("[^A-Za-z0-9]+")
+
+// nestedStack represents a child AWS::CloudFormation::Stack that hosts
+// a subset of the service's Lambda resources
+type nestedStack struct {
+	logicalName string
+	resources   map[string]interface{}
+	parameters  map[string]interface{}
+	outputs     map[string]interface{}
+	// Parameter values supplied by the parent template
+	parameterValues map[string]interface{}
+	dependsOn       []string
+	size            int
+}
+
+// Returns true if a template with the given resource count and
+// body size should be split into nested stacks
+func nestedStacksRequired(resourceCount int, templateSize int) bool {
+	return float64(resourceCount) > nestedStackThreshold*maxTemplateResourceCount ||
+		float64(templateSize) > nestedStackThreshold*maxTemplateBodySize
+}
+
+// Returns the generic JSON representation of value
+func normalizedJSON(value interface{}) (map[string]interface{}, error) {
+	jsonBytes, err := json.Marshal(value)
+	if nil != err {
+		return nil, err
+	}
+	var normalized map[string]interface{}
+	err = json.Unmarshal(jsonBytes, &normalized)
+	if nil != err {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// Rewrite the Ref and Fn::GetAtt expressions in value.  If resolve returns a
+// non-nil value, it replaces the expression.
+func rewriteReferences(value interface{}, resolve func(logicalID string, attribute string) interface{}) interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		if len(typedValue) == 1 {
+			if logicalID, ok := typedValue["Ref"].(string); ok {
+				if replacement := resolve(logicalID, ""); nil != replacement {
+					return replacement
+				}
+				return typedValue
+			}
+			if getAttr, ok := typedValue["Fn::GetAtt"].([]interface{}); ok && len(getAttr) == 2 {
+				logicalID, idOK := getAttr[0].(string)
+				attribute, attrOK := getAttr[1].(string)
+				if idOK && attrOK {
+					if replacement := resolve(logicalID, attribute); nil != replacement {
+						return replacement
+					}
+				}
+				return typedValue
+			}
+		}
+		for eachKey, eachValue := range typedValue {
+			typedValue[eachKey] = rewriteReferences(eachValue, resolve)
+		}
+		return typedValue
+	case []interface{}:
+		for index, eachValue := range typedValue {
+			typedValue[index] = rewriteReferences(eachValue, resolve)
+		}
+		return typedValue
+	default:
+		return value
+	}
+}
+
+// Returns the resource's DependsOn values
+func resourceDependencies(resource map[string]interface{}) []string {
+	var dependencies []string
+	switch typedValue := resource["DependsOn"].(type) {
+	case string:
+		dependencies = append(dependencies, typedValue)
+	case []interface{}:
+		for _, eachValue := range typedValue {
+			if dependency, ok := eachValue.(string); ok {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies
+}
+
+// Returns the logical ID used to pass a cross-stack reference value
+func nestedReferenceName(logicalID string, attribute string) string {
+	return fmt.Sprintf("%s%s", logicalID, reLogicalIDIllegal.ReplaceAllString(attribute, ""))
+}
+
+// Returns the Ref or Fn::GetAtt expression for the logical ID
+func referenceExpression(logicalID string, attribute string) map[string]interface{} {
+	if "" == attribute {
+		return map[string]interface{}{
+			"Ref": logicalID,
+		}
+	}
+	return map[string]interface{}{
+		"Fn::GetAtt": []interface{}{logicalID, attribute},
+	}
+}
+
+// Returns true if the resource can be moved into a nested stack.  Moving a
+// resource to a different stack replaces it, so only the stateless
+// AWS::Lambda::* resources are moved.  All other resources remain in the
+// parent template.
+func nestedStackResource(resource interface{}) bool {
+	typedResource, _ := resource.(map[string]interface{})
+	resourceType, _ := typedResource["Type"].(string)
+	return strings.HasPrefix(resourceType, "AWS::Lambda::")
+}
+
+// Split the template by moving the Lambda resources of each group into a
+// nested stack.  A group whose first logical ID has an entry in assignments
+// remains in the named nested stack unless the stack would exceed the nested
+// stack threshold.  The remaining groups are packed into the first nested
+// stack with enough room, or into a new nested stack whose logical name is
+// derived from the group's first logical ID.  Cross-stack Ref and
+// Fn::GetAtt expressions are rewritten to use nested stack Parameters and
+// Outputs.  The template is modified in place.  The caller is responsible
+// for adding the nested AWS::CloudFormation::Stack resources.
+func splitNestedStacks(template map[string]interface{},
+	resourceGroups [][]string,
+	assignments map[string]string) ([]*nestedStack, error) {
+
+	resources, _ := template["Resources"].(map[string]interface{})
+	templateParameters, _ := template["Parameters"].(map[string]interface{})
+
+	type resourceGroup struct {
+		logicalIDs []string
+		size       int
+	}
+	var stacks []*nestedStack
+	stacksByName := make(map[string]*nestedStack, 0)
+	owners := make(map[string]*nestedStack, 0)
+	newStack := func(logicalName string) *nestedStack {
+		for nil != stacksByName[logicalName] {
+			logicalName = CloudFormationResourceName("NestedStack", logicalName)
+		}
+		stack := &nestedStack{
+			logicalName:     logicalName,
+			resources:       make(map[string]interface{}, 0),
+			parameters:      make(map[string]interface{}, 0),
+			outputs:         make(map[string]interface{}, 0),
+			parameterValues: make(map[string]interface{}, 0),
+		}
+		stacks = append(stacks, stack)
+		stacksByName[logicalName] = stack
+		return stack
+	}
+	fits := func(stack *nestedStack, group *resourceGroup) bool {
+		return len(stack.resources) <= 0 ||
+			!nestedStacksRequired(len(stack.resources)+len(group.logicalIDs), stack.size+group.size)
+	}
+	place := func(stack *nestedStack, group *resourceGroup) {
+		for _, eachKey := range group.logicalIDs {
+			stack.resources[eachKey] = resources[eachKey]
+			owners[eachKey] = stack
+			delete(resources, eachKey)
+		}
+		stack.size += group.size
+	}
+
+	// Assign the groups with an existing assignment, then pack the rest
+	var unassigned []*resourceGroup
+	for _, eachGroup := range resourceGroups {
+		group := &resourceGroup{}
+		for _, eachKey := range eachGroup {
+			if !nestedStackResource(resources[eachKey]) {
+				continue
+			}
+			resourceBody, err := json.Marshal(resources[eachKey])
+			if nil != err {
+				return nil, err
+			}
+			group.logicalIDs = append(group.logicalIDs, eachKey)
+			group.size += len(resourceBody)
+		}
+		if len(group.logicalIDs) <= 0 {
+			continue
+		}
+		if stackName, assigned := assignments[group.logicalIDs[0]]; assigned {
+			stack := stacksByName[stackName]
+			if nil == stack {
+				stack = newStack(stackName)
+			}
+			if fits(stack, group) {
+				place(stack, group)
+				continue
+			}
+		}
+		unassigned = append(unassigned, group)
+	}
+	for _, eachGroup := range unassigned {
+		var target *nestedStack
+		for _, eachStack := range stacks {
+			if fits(eachStack, eachGroup) {
+				target = eachStack
+				break
+			}
+		}
+		if nil == target {
+			target = newStack(CloudFormationResourceName("NestedStack", eachGroup.logicalIDs[0]))
+		}
+		place(target, eachGroup)
+	}
+
+	// Returns the expression that the parent template uses to access the
+	// logical ID owned by the nested stack
+	exportValue := func(owner *nestedStack, logicalID string, attribute string) interface{} {
+		outputName := nestedReferenceName(logicalID, attribute)
+		owner.outputs[outputName] = map[string]interface{}{
+			"Value": referenceExpression(logicalID, attribute),
+		}
+		return referenceExpression(owner.logicalName, fmt.Sprintf("Outputs.%s", outputName))
+	}
+
+	// Rewrite the nested stack references
+	for _, eachStack := range stacks {
+		stack := eachStack
+		resolve := func(logicalID string, attribute string) interface{} {
+			owner, owned := owners[logicalID]
+			if owner == stack || strings.HasPrefix(logicalID, "AWS::") {
+				return nil
+			}
+			if _, isParameter := templateParameters[logicalID]; isParameter && "" == attribute {
+				stack.parameters[logicalID] = map[string]interface{}{
+					"Type": "String",
+				}
+				stack.parameterValues[logicalID] = referenceExpression(logicalID, "")
+				return nil
+			}
+			_, inParent := resources[logicalID]
+			if !owned && !inParent {
+				return nil
+			}
+			parameterName := nestedReferenceName(logicalID, attribute)
+			stack.parameters[parameterName] = map[string]interface{}{
+				"Type": "String",
+			}
+			if owned {
+				stack.parameterValues[parameterName] = exportValue(owner, logicalID, attribute)
+			} else {
+				stack.parameterValues[parameterName] = referenceExpression(logicalID, attribute)
+			}
+			return referenceExpression(parameterName, "")
+		}
+		for eachKey, eachResource := range stack.resources {
+			resource, ok := eachResource.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			stack.resources[eachKey] = rewriteReferences(resource, resolve)
+			// DependsOn values outside the nested stack become dependencies
+			// of the nested stack resource
+			var localDependencies []interface{}
+			for _, eachDependency := range resourceDependencies(resource) {
+				owner, owned := owners[eachDependency]
+				switch {
+				case owner == stack:
+					localDependencies = append(localDependencies, eachDependency)
+				case owned:
+					stack.dependsOn = appendUnique(stack.dependsOn, owner.logicalName)
+				default:
+					stack.dependsOn = appendUnique(stack.dependsOn, eachDependency)
+				}
+			}
+			if len(localDependencies) > 0 {
+				resource["DependsOn"] = localDependencies
+			} else {
+				delete(resource, "DependsOn")
+			}
+		}
+		sort.Strings(stack.dependsOn)
+		if len(stack.parameters) > maxTemplateParameterCount {
+			return nil, fmt.Errorf("Nested stack %s requires %d parameters (limit: %d)",
+				stack.logicalName,
+				len(stack.parameters),
+				maxTemplateParameterCount)
+		}
+	}
+
+	// Rewrite the parent references to nested stack resources
+	resolveParent := func(logicalID string, attribute string) interface{} {
+		owner, owned := owners[logicalID]
+		if !owned {
+			return nil
+		}
+		return exportValue(owner, logicalID, attribute)
+	}
+	for eachKey, eachResource := range resources {
+		resource, ok := eachResource.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resources[eachKey] = rewriteReferences(resource, resolveParent)
+		var dependencies []string
+		for _, eachDependency := range resourceDependencies(resource) {
+			if owner, owned := owners[eachDependency]; owned {
+				eachDependency = owner.logicalName
+			}
+			dependencies = appendUnique(dependencies, eachDependency)
+		}
+		if len(dependencies) > 0 {
+			resource["DependsOn"] = dependencies
+		} else {
+			delete(resource, "DependsOn")
+		}
+	}
+	if outputs, ok := template["Outputs"].(map[string]interface{}); ok {
+		template["Outputs"] = rewriteReferences(outputs, resolveParent)
+	}
+	return stacks, nil
+}
+
+// Returns the child template for the nested stack
+func (stack *nestedStack) template(serviceDescription string) map[string]interface{} {
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              fmt.Sprintf("%s (%s)", serviceDescription, stack.logicalName),
+		"Resources":                stack.resources,
+	}
+	if len(stack.parameters) > 0 {
+		template["Parameters"] = stack.parameters
+	}
+	if len(stack.outputs) > 0 {
+		template["Outputs"] = stack.outputs
+	}
+	return template
+}
+
+// Returns the parent template resource for the nested stack whose template
+// is stored in the S3 bucket under templateKey
+func (stack *nestedStack) resource(s3Bucket string, templateKey string) map[string]interface{} {
+	properties := map[string]interface{}{
+		"TemplateURL": map[string]interface{}{
+			"Fn::Join": []interface{}{"", []interface{}{
+				"https://s3.",
+				map[string]interface{}{
+					"Ref": "AWS::Region",
+				},
+				".amazonaws.com/",
+				s3Bucket,
+				"/",
+				templateKey,
+			}},
+		},
+	}
+	if len(stack.parameterValues) > 0 {
+		properties["Parameters"] = stack.parameterValues
+	}
+	resource := map[string]interface{}{
+		"Type":       "AWS::CloudFormation::Stack",
+		"Properties": properties,
+	}
+	if len(stack.dependsOn) > 0 {
+		resource["DependsOn"] = stack.dependsOn
+	}
+	return resource
+}
+
+// Returns the S3 keynames of the nested stack templates referenced by the
+// parent template, sorted.  TemplateURL values are either the Fn::Join
+// expressions produced by (*nestedStack).resource or path-style S3 URLs.
+func nestedTemplateKeys(template map[string]interface{}) []string {
+	var templateKeys []string
+	resources, _ := template["Resources"].(map[string]interface{})
+	for _, eachResource := range resources {
+		resource, _ := eachResource.(map[string]interface{})
+		if "AWS::CloudFormation::Stack" != resource["Type"] {
+			continue
+		}
+		properties, _ := resource["Properties"].(map[string]interface{})
+		templateKey := ""
+		switch templateURL := properties["TemplateURL"].(type) {
+		case string:
+			urlParts := strings.SplitN(strings.TrimPrefix(templateURL, "https://"), "/", 3)
+			if len(urlParts) == 3 {
+				templateKey = urlParts[2]
+			}
+		case map[string]interface{}:
+			join, _ := templateURL["Fn::Join"].([]interface{})
+			if len(join) == 2 {
+				joinValues, _ := join[1].([]interface{})
+				if len(joinValues) > 0 {
+					templateKey, _ = joinValues[len(joinValues)-1].(string)
+				}
+			}
+		}
+		if "" != templateKey {
+			templateKeys = append(templateKeys, templateKey)
+		}
+	}
+	sort.Strings(templateKeys)
+	return templateKeys
+}
+
+// Move the Lambda resources into nested stacks and return the updated parent
+// template body.  The nested stack templates are saved in the workflow
+// context so that they can be uploaded alongside the parent template.
+func ensureNestedStacks(ctx *workflowContext,
+	cloudFormationTemplate ArbitraryJSONObject,
+	resourceGroups [][]string) ([]byte, error) {
+
+	template, err := normalizedJSON(cloudFormationTemplate)
+	if nil != err {
+		return nil, err
+	}
+	// Keep the Lambda resources in the nested stacks that they were
+	// provisioned in
+	assignments := make(map[string]string, 0)
+	if !ctx.noop && "" == ctx.packageOutputDir {
+		awsCloudFormation := cloudformation.New(ctx.awsSession)
+		exists, err := stackExists(ctx.stackName, awsCloudFormation, ctx.logger)
+		if nil != err {
+			return nil, err
+		}
+		if exists {
+			assignments, err = nestedStackAssignments(ctx.stackName, awsCloudFormation)
+			if nil != err {
+				return nil, err
+			}
+		}
+	}
+	stacks, err := splitNestedStacks(template, resourceGroups, assignments)
+	if nil != err {
+		return nil, err
+	}
+	resources, _ := template["Resources"].(map[string]interface{})
+	for _, eachMove := range nestedStackMoves(stacks, resources, assignments) {
+		ctx.logger.WithFields(logrus.Fields{
+			"Resource":      eachMove.logicalID,
+			"PreviousStack": eachMove.previousStack,
+			"Stack":         eachMove.stack,
+		}).Warn("Resource is moving to a different stack. CloudFormation will replace it.")
+	}
+	ctx.nestedTemplates = make(map[string][]byte, 0)
+	for _, eachStack := range stacks {
+		stackTemplate, err := json.Marshal(eachStack.template(ctx.serviceDescription))
+		if nil != err {
+			return nil, err
+		}
+		templateKey := templateS3Key(ctx.stackName, stackTemplate)
+		ctx.nestedTemplates[templateKey] = stackTemplate
+		resources[eachStack.logicalName] = eachStack.resource(ctx.s3Bucket, templateKey)
+		ctx.logger.WithFields(logrus.Fields{
+			"Stack":         eachStack.logicalName,
+			"ResourceCount": len(eachStack.resources),
+			"Template":      templateKey,
+		}).Info("Nested stack")
+	}
+	parentTemplate, err := json.Marshal(template)
+	if nil != err {
+		return nil, err
+	}
+	if nestedStacksRequired(len(resources), len(parentTemplate)) {
+		ctx.logger.WithFields(logrus.Fields{
+			"ResourceCount": len(resources),
+			"TemplateSize":  len(parentTemplate),
+		}).Warn("CloudFormation template is near the template limits after creating nested stacks")
+	}
+	return parentTemplate, nil
+}
+
+// Returns the logical ID -> nested stack logical ID map for the resources
+// in the stack's nested stacks
+func nestedStackAssignments(stackName string, cf *cloudformation.CloudFormation) (map[string]string, error) {
+	nestedStackIDs := make(map[string]string, 0)
+	err := cf.ListStackResourcesPages(&cloudformation.ListStackResourcesInput{
+		StackName: aws.String(stackName),
+	}, func(page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+		for _, eachSummary := range page.StackResourceSummaries {
+			physicalID := aws.StringValue(eachSummary.PhysicalResourceId)
+			if "" != physicalID && aws.StringValue(eachSummary.ResourceType) == "AWS::CloudFormation::Stack" {
+				nestedStackIDs[aws.StringValue(eachSummary.LogicalResourceId)] = physicalID
+			}
+		}
+		return true
+	})
+	if nil != err {
+		return nil, err
+	}
+	assignments := make(map[string]string, 0)
+	for eachLogicalID, eachStackID := range nestedStackIDs {
+		stackLogicalID := eachLogicalID
+		err = cf.ListStackResourcesPages(&cloudformation.ListStackResourcesInput{
+			StackName: aws.String(eachStackID),
+		}, func(page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+			for _, eachSummary := range page.StackResourceSummaries {
+				assignments[aws.StringValue(eachSummary.LogicalResourceId)] = stackLogicalID
+			}
+			return true
+		})
+		if nil != err {
+			return nil, err
+		}
+	}
+	return assignments, nil
+}
+
+// A resource that moves between the parent stack and a nested stack, or
+// between nested stacks.  An empty stack name denotes the parent stack.
+type nestedStackMove struct {
+	logicalID     string
+	previousStack string
+	stack         string
+}
+
+// Sort interface for nested stack moves
+type nestedStackMovesByLogicalID []*nestedStackMove
+
+func (moves nestedStackMovesByLogicalID) Len() int {
+	return len(moves)
+}
+func (moves nestedStackMovesByLogicalID) Swap(i, j int) {
+	moves[i], moves[j] = moves[j], moves[i]
+}
+func (moves nestedStackMovesByLogicalID) Less(i, j int) bool {
+	return moves[i].logicalID < moves[j].logicalID
+}
+
+// Returns the provisioned nested stack resources that the split stacks
+// assign to a different stack, sorted by logical ID
+func nestedStackMoves(stacks []*nestedStack,
+	parentResources map[string]interface{},
+	assignments map[string]string) []*nestedStackMove {
+
+	owners := make(map[string]string, 0)
+	for _, eachStack := range stacks {
+		for eachKey := range eachStack.resources {
+			owners[eachKey] = eachStack.logicalName
+		}
+	}
+	var moves []*nestedStackMove
+	for eachKey, eachPreviousStack := range assignments {
+		stackName, nested := owners[eachKey]
+		_, inParent := parentResources[eachKey]
+		if (nested || inParent) && stackName != eachPreviousStack {
+			moves = append(moves, &nestedStackMove{
+				logicalID:     eachKey,
+				previousStack: eachPreviousStack,
+				stack:         stackName,
+			})
+		}
+	}
+	sort.Sort(nestedStackMovesByLogicalID(moves))
+	return moves
+}
+
+// Upload the nested stack templates to the S3 bucket
+func uploadNestedTemplates(ctx *workflowContext) error {
+	var templateKeys []string
+	for eachKey := range ctx.nestedTemplates {
+		templateKeys = append(templateKeys, eachKey)
+	}
+	sort.Strings(templateKeys)
+
+	uploader := s3manager.NewUploader(ctx.awsSession)
+	for _, eachKey := range templateKeys {
+		uploadResult, err := uploader.Upload(&s3manager.UploadInput{
+			Bucket:      aws.String(ctx.s3Bucket),
+			Key:         aws.String(eachKey),
+			ContentType: aws.String("application/json"),
+			Body:        bytes.NewReader(ctx.nestedTemplates[eachKey]),
+		})
+		if nil != err {
+			return err
+		}
+		ctx.logger.Info("Nested stack template uploaded: ", uploadResult.Location)
+	}
+	return nil
+}
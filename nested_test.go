@@ -0,0 +1,215 @@
+package sparta
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSplitNestedStacks(t *testing.T) {
+	resources := map[string]interface{}{
+		"IAMRole": map[string]interface{}{
+			"Type": "AWS::IAM::Role",
+		},
+		"LambdaA": map[string]interface{}{
+			"Type": "AWS::Lambda::Function",
+			"Properties": map[string]interface{}{
+				"Role":        map[string]interface{}{"Fn::GetAtt": []interface{}{"IAMRole", "Arn"}},
+				"Description": map[string]interface{}{"Ref": "Stage"},
+			},
+			"DependsOn": []interface{}{"IAMRole"},
+		},
+		"LambdaB": map[string]interface{}{
+			"Type": "AWS::Lambda::Function",
+			"Properties": map[string]interface{}{
+				"Description": map[string]interface{}{"Fn::GetAtt": []interface{}{"LambdaA", "Arn"}},
+			},
+		},
+		"ConfigB": map[string]interface{}{
+			"Type": "AWS::CloudFormation::CustomResource",
+			"Properties": map[string]interface{}{
+				"ServiceToken": map[string]interface{}{"Fn::GetAtt": []interface{}{"LambdaA", "Arn"}},
+			},
+		},
+		"API": map[string]interface{}{
+			"Type": "AWS::CloudFormation::CustomResource",
+			"Properties": map[string]interface{}{
+				"Target": map[string]interface{}{"Fn::GetAtt": []interface{}{"LambdaB", "Arn"}},
+			},
+			"DependsOn": []interface{}{"LambdaB"},
+		},
+	}
+	groupA := []string{"LambdaA"}
+	groupB := []string{"LambdaB", "ConfigB"}
+	// Pad the groups s.t. they can't share a nested stack
+	for index := 0; index < 100; index++ {
+		for _, eachGroup := range []*[]string{&groupA, &groupB} {
+			logicalID := fmt.Sprintf("%sPad%d", (*eachGroup)[0], index)
+			resources[logicalID] = map[string]interface{}{
+				"Type": "AWS::Lambda::Permission",
+			}
+			*eachGroup = append(*eachGroup, logicalID)
+		}
+	}
+	template := map[string]interface{}{
+		"Parameters": map[string]interface{}{
+			"Stage": map[string]interface{}{"Type": "String"},
+		},
+		"Resources": resources,
+	}
+
+	stacks, err := splitNestedStacks(template, [][]string{groupA, groupB}, nil)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(stacks) != 2 {
+		t.Fatalf("Expected 2 nested stacks, got %d", len(stacks))
+	}
+	stackA := stacks[0]
+	stackB := stacks[1]
+	if len(resources) != 3 {
+		t.Errorf("Expected IAMRole, ConfigB and API to remain in the parent, got %d resources", len(resources))
+	}
+	if _, exists := stackB.resources["ConfigB"]; exists {
+		t.Errorf("Expected non-Lambda resource to remain in the parent")
+	}
+
+	// Parent resource references are passed as parameters
+	lambdaA := stackA.resources["LambdaA"].(map[string]interface{})
+	role := lambdaA["Properties"].(map[string]interface{})["Role"]
+	if !reflect.DeepEqual(role, map[string]interface{}{"Ref": "IAMRoleArn"}) {
+		t.Errorf("Unexpected Role reference: %v", role)
+	}
+	if !reflect.DeepEqual(stackA.parameterValues["IAMRoleArn"],
+		map[string]interface{}{"Fn::GetAtt": []interface{}{"IAMRole", "Arn"}}) {
+		t.Errorf("Unexpected IAMRoleArn parameter value: %v", stackA.parameterValues["IAMRoleArn"])
+	}
+	if _, exists := stackA.parameters["Stage"]; !exists {
+		t.Errorf("Template parameter not passed to nested stack")
+	}
+	if _, exists := lambdaA["DependsOn"]; exists {
+		t.Errorf("Cross-stack DependsOn not removed")
+	}
+	if !reflect.DeepEqual(stackA.dependsOn, []string{"IAMRole"}) {
+		t.Errorf("Unexpected nested stack dependencies: %v", stackA.dependsOn)
+	}
+
+	// Sibling references are passed through outputs
+	if _, exists := stackA.outputs["LambdaAArn"]; !exists {
+		t.Errorf("LambdaAArn not exported by nested stack")
+	}
+	if !reflect.DeepEqual(stackB.parameterValues["LambdaAArn"],
+		map[string]interface{}{"Fn::GetAtt": []interface{}{stackA.logicalName, "Outputs.LambdaAArn"}}) {
+		t.Errorf("Unexpected LambdaAArn parameter value: %v", stackB.parameterValues["LambdaAArn"])
+	}
+
+	// Parent references to nested resources use outputs
+	configB := resources["ConfigB"].(map[string]interface{})
+	serviceToken := configB["Properties"].(map[string]interface{})["ServiceToken"]
+	if !reflect.DeepEqual(serviceToken,
+		map[string]interface{}{"Fn::GetAtt": []interface{}{stackA.logicalName, "Outputs.LambdaAArn"}}) {
+		t.Errorf("Unexpected ConfigB service token: %v", serviceToken)
+	}
+	api := resources["API"].(map[string]interface{})
+	target := api["Properties"].(map[string]interface{})["Target"]
+	if !reflect.DeepEqual(target,
+		map[string]interface{}{"Fn::GetAtt": []interface{}{stackB.logicalName, "Outputs.LambdaBArn"}}) {
+		t.Errorf("Unexpected API target: %v", target)
+	}
+	if !reflect.DeepEqual(api["DependsOn"], []string{stackB.logicalName}) {
+		t.Errorf("Unexpected API dependencies: %v", api["DependsOn"])
+	}
+}
+
+// Returns a template whose nested stacks have room for two Lambda groups
+func testNestedStackTemplate(lambdaNames ...string) (map[string]interface{}, [][]string) {
+	resources := map[string]interface{}{
+		"Table": map[string]interface{}{
+			"Type": "AWS::DynamoDB::Table",
+		},
+	}
+	var groups [][]string
+	for _, eachName := range lambdaNames {
+		resources[eachName] = map[string]interface{}{
+			"Type": "AWS::Lambda::Function",
+		}
+		group := []string{eachName}
+		for index := 0; index < 60; index++ {
+			logicalID := fmt.Sprintf("%sPermission%d", eachName, index)
+			resources[logicalID] = map[string]interface{}{
+				"Type": "AWS::Lambda::Permission",
+			}
+			group = append(group, logicalID)
+		}
+		groups = append(groups, append(group, "Table"))
+	}
+	return map[string]interface{}{"Resources": resources}, groups
+}
+
+func TestSplitNestedStacksAssignments(t *testing.T) {
+	template, groups := testNestedStackTemplate("LambdaA", "LambdaB")
+	stacks, err := splitNestedStacks(template, groups, nil)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(stacks) != 1 {
+		t.Fatalf("Expected 1 nested stack, got %d", len(stacks))
+	}
+	assignments := make(map[string]string, 0)
+	for _, eachStack := range stacks {
+		for eachKey := range eachStack.resources {
+			assignments[eachKey] = eachStack.logicalName
+		}
+	}
+	// Adding a Lambda ahead of the provisioned Lambdas must not move them
+	template, groups = testNestedStackTemplate("LambdaNew", "LambdaA", "LambdaB")
+	stacks, err = splitNestedStacks(template, groups, assignments)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(stacks) != 2 {
+		t.Fatalf("Expected 2 nested stacks, got %d", len(stacks))
+	}
+	resources := template["Resources"].(map[string]interface{})
+	if _, exists := resources["Table"]; !exists {
+		t.Errorf("Expected stateful resource to remain in the parent")
+	}
+	moves := nestedStackMoves(stacks, resources, assignments)
+	if len(moves) != 0 {
+		t.Errorf("Expected no resources to move, got %d. First: %+v", len(moves), moves[0])
+	}
+
+	// Resources that were provisioned in a nested stack and are now
+	// assigned elsewhere are reported
+	assignments["Table"] = stacks[0].logicalName
+	moves = nestedStackMoves(stacks, resources, assignments)
+	if len(moves) != 1 || moves[0].logicalID != "Table" || moves[0].stack != "" {
+		t.Errorf("Expected Table to move to the parent stack: %v", moves)
+	}
+}
+
+func TestNestedTemplateKeys(t *testing.T) {
+	template := map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"NestedStackA": (&nestedStack{}).resource("sample-bucket", "SampleService-cf-a.json"),
+			"NestedStackB": map[string]interface{}{
+				"Type": "AWS::CloudFormation::Stack",
+				"Properties": map[string]interface{}{
+					"TemplateURL": "https://s3.amazonaws.com/sample-bucket/SampleService-cf-b.json",
+				},
+			},
+			"Topic": map[string]interface{}{
+				"Type": "AWS::SNS::Topic",
+			},
+		},
+	}
+	// Rollback decodes the template from JSON
+	normalized, err := normalizedJSON(template)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"SampleService-cf-a.json", "SampleService-cf-b.json"}
+	if keys := nestedTemplateKeys(normalized); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Unexpected nested template keys: %v", keys)
+	}
+}
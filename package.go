@@ -0,0 +1,185 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// PackageS3BucketPlaceholder is the S3 bucket name written into a packaged
+// CloudFormation template when `package` is run without an S3 bucket.  The
+// placeholder is replaced with the bucket supplied to `deploy`.
+const PackageS3BucketPlaceholder = "{{SpartaS3Bucket}}"
+
+// Name of the manifest file that describes the contents of a package directory
+const packageManifestName = "sparta-package.json"
+
+// packageManifest describes the artifacts produced by Package() so that
+// a subsequent Deploy() can provision them without rebuilding.
+type packageManifest struct {
+	ServiceName   string
+	SpartaVersion string
+	// S3 bucket name used in the template. May be PackageS3BucketPlaceholder.
+	S3Bucket string
+	// ZIP archive filename, which is also the S3 keyname
+	CodeArchive string
+	// All ZIP archive filenames if BuildOptions.SplitPackages was used.  The
+	// first archive is the CodeArchive.
+	CodeArchives []string `json:",omitempty"`
+	// CloudFormation template filename
+	Template string
+	// Nested stack template filenames, which are also the S3 keynames
+	NestedTemplates []string `json:",omitempty"`
+}
+
+// Copy the contents of the source file to a new file at destPath
+func copyFile(sourcePath string, destPath string) error {
+	reader, err := os.Open(sourcePath)
+	if nil != err {
+		return err
+	}
+	defer reader.Close()
+
+	writer, err := os.Create(destPath)
+	if nil != err {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	closeErr := writer.Close()
+	if nil != err {
+		return err
+	}
+	return closeErr
+}
+
+// Returns the filenames of the package's ZIP archives
+func (manifest *packageManifest) codeArchives() []string {
+	if len(manifest.CodeArchives) > 0 {
+		return manifest.CodeArchives
+	}
+	return []string{manifest.CodeArchive}
+}
+
+// Write the CloudFormation template and the manifest to the package output
+// directory.  The ZIP archives have already been written by the upload step.
+func writePackage(ctx *workflowContext, codeArchives []string, templateName string, templateBody []byte) error {
+	templatePath := filepath.Join(ctx.packageOutputDir, templateName)
+	err := ioutil.WriteFile(templatePath, templateBody, 0644)
+	if nil != err {
+		return fmt.Errorf("Failed to write CloudFormation template to %s: %s", templatePath, err.Error())
+	}
+	ctx.logger.Info("CloudFormation template written: ", templatePath)
+
+	var nestedTemplateNames []string
+	for eachKey, eachTemplate := range ctx.nestedTemplates {
+		nestedPath := filepath.Join(ctx.packageOutputDir, eachKey)
+		err = ioutil.WriteFile(nestedPath, eachTemplate, 0644)
+		if nil != err {
+			return fmt.Errorf("Failed to write nested stack template to %s: %s", nestedPath, err.Error())
+		}
+		nestedTemplateNames = append(nestedTemplateNames, eachKey)
+	}
+	sort.Strings(nestedTemplateNames)
+
+	manifest := packageManifest{
+		ServiceName:     ctx.serviceName,
+		SpartaVersion:   SpartaVersion,
+		S3Bucket:        ctx.s3Bucket,
+		CodeArchive:     codeArchives[0],
+		Template:        templateName,
+		NestedTemplates: nestedTemplateNames,
+	}
+	if len(codeArchives) > 1 {
+		manifest.CodeArchives = codeArchives
+	}
+	manifestBody, err := json.MarshalIndent(manifest, "", " ")
+	if nil != err {
+		return err
+	}
+	manifestPath := filepath.Join(ctx.packageOutputDir, packageManifestName)
+	err = ioutil.WriteFile(manifestPath, manifestBody, 0644)
+	if nil != err {
+		return fmt.Errorf("Failed to write package manifest to %s: %s", manifestPath, err.Error())
+	}
+	ctx.logger.WithFields(logrus.Fields{
+		"Manifest":    manifestPath,
+		"S3Bucket":    manifest.S3Bucket,
+		"CodeArchive": manifest.CodeArchive,
+		"Template":    manifest.Template,
+	}).Info("Package complete")
+	return nil
+}
+
+// Read the manifest produced by a previous Package() call
+func readPackageManifest(inputDir string) (*packageManifest, error) {
+	manifestPath := filepath.Join(inputDir, packageManifestName)
+	manifestBody, err := ioutil.ReadFile(manifestPath)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to read package manifest %s: %s", manifestPath, err.Error())
+	}
+	var manifest packageManifest
+	err = json.Unmarshal(manifestBody, &manifest)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse package manifest %s: %s", manifestPath, err.Error())
+	}
+	return &manifest, nil
+}
+
+// Package compiles and packages a Sparta application into outputDir without
+// making any AWS API calls.  The output directory will contain the Lambda ZIP
+// archive, the CloudFormation template and a manifest that Deploy() uses to
+// provision the service.  If s3Bucket is empty, the template refers to
+// PackageS3BucketPlaceholder and the bucket must be supplied at deploy time.
+//
+// Pre-existing IAM role names are not verified.  Their ARNs are resolved by
+// CloudFormation relative to the account that the stack is deployed to.  The
+// template declares the options.Parameters keys, whose values are supplied by
+// Deploy().
+func Package(serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	s3Bucket string,
+	outputDir string,
+	options *ProvisionOptions,
+	logger *logrus.Logger) error {
+
+	if len(lambdaAWSInfos) <= 0 {
+		return errors.New("No lambda functions provided to Sparta.Package()")
+	}
+	if "" == outputDir {
+		return errors.New("Package output directory must not be empty")
+	}
+	if "" == s3Bucket {
+		s3Bucket = PackageS3BucketPlaceholder
+	}
+	err := os.MkdirAll(outputDir, 0755)
+	if nil != err {
+		return fmt.Errorf("Failed to create package output directory %s: %s", outputDir, err.Error())
+	}
+
+	ctx := &workflowContext{
+		serviceName:             serviceName,
+		stackName:               serviceName,
+		serviceDescription:      serviceDescription,
+		lambdaAWSInfos:          lambdaAWSInfos,
+		api:                     api,
+		cloudformationResources: make(ArbitraryJSONObject, 0),
+		cloudformationOutputs:   make(ArbitraryJSONObject, 0),
+		s3Bucket:                s3Bucket,
+		packageOutputDir:        outputDir,
+		options:                 options,
+		awsSession:              awsSession(logger),
+		logger:                  logger,
+	}
+	return runWorkflow(ctx)
+}
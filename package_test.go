@@ -0,0 +1,81 @@
+package sparta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackage(t *testing.T) {
+	logger, err := NewLogger("info")
+	outputDir, err := ioutil.TempDir("", "SampleProvision")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(outputDir)
+
+	err = Package("SampleProvision", "", testLambdaData(), nil, "", outputDir, nil, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	manifest, err := readPackageManifest(outputDir)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if manifest.S3Bucket != PackageS3BucketPlaceholder {
+		t.Errorf("Expected placeholder S3 bucket, got: %s", manifest.S3Bucket)
+	}
+	_, err = os.Stat(filepath.Join(outputDir, manifest.CodeArchive))
+	if nil != err {
+		t.Errorf("Failed to find ZIP archive: %s", err.Error())
+	}
+	template, err := ioutil.ReadFile(filepath.Join(outputDir, manifest.Template))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(string(template), PackageS3BucketPlaceholder) {
+		t.Errorf("Template does not reference S3 bucket placeholder")
+	}
+}
+
+func TestPackageSplitPackages(t *testing.T) {
+	logger, err := NewLogger("info")
+	outputDir, err := ioutil.TempDir("", "SampleProvision")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(outputDir)
+
+	lambdaAWSInfos := testLambdaData()
+	options := &ProvisionOptions{
+		Build: &BuildOptions{
+			SplitPackages: true,
+		},
+	}
+	err = Package("SampleProvision", "", lambdaAWSInfos, nil, "", outputDir, options, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	manifest, err := readPackageManifest(outputDir)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(manifest.CodeArchives) != len(lambdaAWSInfos) {
+		t.Fatalf("Expected %d code archives, got: %v", len(lambdaAWSInfos), manifest.CodeArchives)
+	}
+	template, err := ioutil.ReadFile(filepath.Join(outputDir, manifest.Template))
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	for _, eachArchive := range manifest.CodeArchives {
+		_, err = os.Stat(filepath.Join(outputDir, eachArchive))
+		if nil != err {
+			t.Errorf("Failed to find ZIP archive: %s", err.Error())
+		}
+		if !strings.Contains(string(template), eachArchive) {
+			t.Errorf("Template does not reference ZIP archive: %s", eachArchive)
+		}
+	}
+}
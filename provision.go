@@ -10,6 +10,9 @@
 //go:generate zip -vr ./resources/provision/node_modules.zip ./node_modules/
 //go:generate rm -rf ./node_modules
 
+// Refresh the bundled CloudFormation resource specification
+//go:generate sh -c "curl -sSL https://d1uauaxba7bl26.cloudfront.net/latest/gzip/CloudFormationResourceSpecification.json | gunzip > ./resources/cloudformation/spec.json"
+
 // Embed the custom service handlers
 // TODO: Move these into golang
 //go:generate go run ./vendor/github.com/mweagle/esc/main.go -o ./CONSTANTS.go -private -pkg sparta ./resources
@@ -29,14 +32,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 	"github.com/aws/aws-sdk-go/service/iam"
@@ -64,7 +71,9 @@ var customResourceScripts = []string{"cfn-response.js",
 
 type workflowContext struct {
 	noop                    bool
+	validateOnly            bool
 	serviceName             string
+	stackName               string
 	serviceDescription      string
 	lambdaAWSInfos          []*LambdaAWSInfo
 	api                     *API
@@ -72,7 +81,15 @@ type workflowContext struct {
 	cloudformationOutputs   ArbitraryJSONObject
 	lambdaIAMRoleNameMap    map[string]interface{}
 	s3Bucket                string
-	s3LambdaZipKey          string
+	s3LambdaZipKeys         []string
+	lambdaS3Keys            map[string]string
+	codeArchiveKeys         []string
+	packageOutputDir        string
+	prebuiltPackages        []*lambdaPackage
+	templateBody            []byte
+	nestedTemplates         map[string][]byte
+	stackCreated            bool
+	options                 *ProvisionOptions
 	awsSession              *session.Session
 	templateWriter          io.Writer
 	logger                  *logrus.Logger
@@ -103,7 +120,12 @@ func verifyIAMRoles(ctx *workflowContext) (workflowStep, error) {
 		// Get the IAM role name
 		if "" != eachLambda.RoleName {
 			_, exists := ctx.lambdaIAMRoleNameMap[eachLambda.RoleName]
-			if !exists {
+			if !exists && ("" != ctx.packageOutputDir || ctx.validateOnly || ctx.noop) {
+				// Offline packaging, validation and noop provisioning don't
+				// call GetRole, so defer the ARN resolution to CloudFormation
+				ctx.logger.Debug("Bypassing IAM RoleName verification for offline operation: ", eachLambda.RoleName)
+				ctx.lambdaIAMRoleNameMap[eachLambda.RoleName] = iamRoleArn(eachLambda.RoleName)
+			} else if !exists {
 				// Check the role
 				params := &iam.GetRoleInput{
 					RoleName: aws.String(eachLambda.RoleName),
@@ -133,6 +155,9 @@ func verifyIAMRoles(ctx *workflowContext) (workflowStep, error) {
 		}
 	}
 	ctx.logger.Info("IAM roles verified. Count: ", len(ctx.lambdaIAMRoleNameMap))
+	if ctx.validateOnly {
+		return ensureCloudFormationStack(validateS3KeyPlaceholder), nil
+	}
 	return createPackageStep(), nil
 }
 
@@ -174,167 +199,770 @@ func stackEvents(stackID string, cfService *cloudformation.CloudFormation) ([]*c
 	return events, nil
 }
 
-// Build and package the application
-func createPackageStep() workflowStep {
+// Fixed modification time for all ZIP entries so that identical inputs produce
+// byte-identical archives.  ZIP timestamps can't predate 1980.
+var archiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
 
-	return func(ctx *workflowContext) (workflowStep, error) {
-		// Compile the source to linux...
-		sanitizedServiceName := sanitizedName(ctx.serviceName)
-		executableOutput := fmt.Sprintf("%s.lambda.amd64", sanitizedServiceName)
-		cmd := exec.Command("go", "build", "-o", executableOutput, "-tags", "lambdabinary", ".")
-		ctx.logger.Debug("Building application binary: ", cmd.Args)
-		cmd.Env = os.Environ()
-		cmd.Env = append(cmd.Env, "GOOS=linux", "GOARCH=amd64", "GO15VENDOREXPERIMENT=1")
-		ctx.logger.Info("Compiling binary: ", executableOutput)
-
-		outputWriter := ctx.logger.Writer()
-		defer outputWriter.Close()
-		cmd.Stdout = outputWriter
-		cmd.Stderr = outputWriter
-
-		err := cmd.Run()
-		if err != nil {
-			return nil, err
-		}
-		defer os.Remove(executableOutput)
+// archiveEntry is a single file to include in the Lambda ZIP archive
+type archiveEntry struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
 
-		// Binary size
-		stat, err := os.Stat(executableOutput)
-		if err != nil {
-			return nil, errors.New("Failed to stat build output")
-		}
-		// Minimum hello world size is 2.3M
-		// Minimum HTTP hello world is 6.3M
-		ctx.logger.Info("Executable binary size (MB): ", stat.Size()/(1024*1024))
+func stringArchiveEntry(name string, content string) archiveEntry {
+	return archiveEntry{
+		name: name,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		},
+	}
+}
 
-		workingDir, err := os.Getwd()
-		if err != nil {
-			return nil, errors.New("Failed to retrieve working directory")
+// Write a deterministic ZIP archive of the entries, sorted by name
+func writeArchive(writer io.Writer, entries []archiveEntry) error {
+	sortedEntries := make([]archiveEntry, len(entries))
+	copy(sortedEntries, entries)
+	sort.Sort(archiveEntriesByName(sortedEntries))
+
+	archive := zip.NewWriter(writer)
+	for _, eachEntry := range sortedEntries {
+		header := &zip.FileHeader{
+			Name:   eachEntry.name,
+			Method: zip.Deflate,
 		}
-		tmpFile, err := ioutil.TempFile(workingDir, sanitizedServiceName)
-		if err != nil {
-			return nil, errors.New("Failed to create temporary file")
+		header.SetModTime(archiveModTime)
+		entryWriter, err := archive.CreateHeader(header)
+		if nil != err {
+			return fmt.Errorf("Failed to create ZIP entry: %s", eachEntry.name)
+		}
+		reader, err := eachEntry.open()
+		if nil != err {
+			return fmt.Errorf("Failed to open ZIP entry source: %s", eachEntry.name)
 		}
+		_, err = io.Copy(entryWriter, reader)
+		reader.Close()
+		if nil != err {
+			return err
+		}
+	}
+	return archive.Close()
+}
 
-		defer func() {
-			tmpFile.Close()
-		}()
+type archiveEntriesByName []archiveEntry
 
-		ctx.logger.Info("Creating ZIP archive for upload: ", tmpFile.Name())
-		lambdaArchive := zip.NewWriter(tmpFile)
-		defer lambdaArchive.Close()
+func (entries archiveEntriesByName) Len() int           { return len(entries) }
+func (entries archiveEntriesByName) Swap(i, j int)      { entries[i], entries[j] = entries[j], entries[i] }
+func (entries archiveEntriesByName) Less(i, j int) bool { return entries[i].name < entries[j].name }
 
-		// File info for the binary executable
-		binaryWriter, err := lambdaArchive.Create(filepath.Base(executableOutput))
-		if err != nil {
-			return nil, fmt.Errorf("Failed to create ZIP entry: %s", filepath.Base(executableOutput))
-		}
-		reader, err := os.Open(executableOutput)
-		if err != nil {
-			return nil, fmt.Errorf("Failed to open file: %s", executableOutput)
+// AWS Lambda limit on the uncompressed size of the code (bytes)
+const lambdaUnzippedSizeLimit = 250 * 1024 * 1024
+
+// Fraction of lambdaUnzippedSizeLimit that triggers a size warning
+const lambdaSizeWarningRatio = 0.8
+
+// Returns the archive path of a local asset file
+func assetArchiveName(prefix string, rootPath string, rootName string, path string) (string, error) {
+	relPath, err := filepath.Rel(rootPath, path)
+	if nil != err {
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join(prefix, rootName, relPath)), nil
+}
+
+// Returns the archive entries for the asset files
+func assetArchiveEntries(options *AssetOptions) ([]archiveEntry, error) {
+	if nil == options {
+		return nil, nil
+	}
+	prefix := strings.Trim(filepath.ToSlash(filepath.Clean(options.Prefix)), "/")
+	if "" == options.Prefix || "." == prefix {
+		prefix = DefaultAssetPrefix
+	}
+	if strings.HasPrefix(prefix, "..") {
+		return nil, fmt.Errorf("Invalid asset prefix: %s", options.Prefix)
+	}
+	var entries []archiveEntry
+	names := make(map[string]bool, 0)
+	addEntry := func(name string, open func() (io.ReadCloser, error)) error {
+		if names[name] {
+			return fmt.Errorf("Duplicate asset: %s", name)
 		}
-		defer reader.Close()
-		io.Copy(binaryWriter, reader)
+		names[name] = true
+		entries = append(entries, archiveEntry{
+			name: name,
+			open: open,
+		})
+		return nil
+	}
 
-		// Add the string literal adapter, which requires us to add exported
-		// functions to the end of index.js
-		nodeJSWriter, err := lambdaArchive.Create("index.js")
-		if err != nil {
-			return nil, errors.New("Failed to create ZIP entry: index.js")
-		}
-		nodeJSSource := escFSMustString(false, "/resources/index.js")
-		nodeJSSource += "\n// DO NOT EDIT - CONTENT UNTIL EOF IS AUTOMATICALLY GENERATED\n"
-		for _, eachLambda := range ctx.lambdaAWSInfos {
-			nodeJSSource += createNewNodeJSProxyEntry(eachLambda, ctx.logger)
-		}
-		// Finally, replace
-		// 	SPARTA_BINARY_NAME = 'Sparta.lambda.amd64';
-		// with the service binary name
-		nodeJSSource += fmt.Sprintf("SPARTA_BINARY_NAME='%s';\n", executableOutput)
-		ctx.logger.Debug("Dynamically generated NodeJS adapter:\n", nodeJSSource)
-		stringReader := strings.NewReader(nodeJSSource)
-		io.Copy(nodeJSWriter, stringReader)
-
-		// Also embed the custom resource creation scripts
-		for _, eachName := range customResourceScripts {
-			resourceName := fmt.Sprintf("/resources/provision/%s", eachName)
-			resourceContent := escFSMustString(false, resourceName)
-			stringReader := strings.NewReader(resourceContent)
-			embedWriter, err := lambdaArchive.Create(eachName)
+	for _, eachPattern := range options.Paths {
+		matches, err := filepath.Glob(eachPattern)
+		if nil != err {
+			return nil, fmt.Errorf("Invalid asset pattern %s: %s", eachPattern, err.Error())
+		}
+		if len(matches) <= 0 {
+			return nil, fmt.Errorf("No assets match: %s", eachPattern)
+		}
+		for _, eachMatch := range matches {
+			// Relative paths are preserved unless they're outside the
+			// working directory
+			rootPath := filepath.Clean(eachMatch)
+			rootName := rootPath
+			if filepath.IsAbs(rootPath) || strings.HasPrefix(rootPath, "..") {
+				rootName = filepath.Base(rootPath)
+			}
+			err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+				if nil != err {
+					return err
+				}
+				if info.IsDir() {
+					return nil
+				}
+				name, err := assetArchiveName(prefix, rootPath, rootName, path)
+				if nil != err {
+					return err
+				}
+				return addEntry(name, func() (io.ReadCloser, error) {
+					return os.Open(path)
+				})
+			})
 			if nil != err {
 				return nil, err
 			}
-			ctx.logger.Info("Embedding CustomResource script: ", eachName)
-			io.Copy(embedWriter, stringReader)
 		}
+	}
 
-		// And finally, if there is a node_modules.zip file, then include it.
-		nodeModuleBytes, err := escFSByte(false, "/resources/provision/node_modules.zip")
-		if nil == err {
-			nodeModuleReader, err := zip.NewReader(bytes.NewReader(nodeModuleBytes), int64(len(nodeModuleBytes)))
-			if err != nil {
-				return nil, err
+	if nil != options.FileSystem {
+		var walkFileSystem func(dirPath string) error
+		walkFileSystem = func(dirPath string) error {
+			dir, err := options.FileSystem.Open(dirPath)
+			if nil != err {
+				return err
 			}
-			for _, zipFile := range nodeModuleReader.File {
-				embedWriter, err := lambdaArchive.Create(zipFile.Name)
-				if nil != err {
-					return nil, err
+			fileInfos, err := dir.Readdir(-1)
+			dir.Close()
+			if nil != err {
+				return err
+			}
+			for _, eachInfo := range fileInfos {
+				filePath := dirPath + eachInfo.Name()
+				if eachInfo.IsDir() {
+					err = walkFileSystem(filePath + "/")
+				} else {
+					err = addEntry(prefix+filePath, func() (io.ReadCloser, error) {
+						return options.FileSystem.Open(filePath)
+					})
 				}
-				ctx.logger.Debug("Copying node_module file: ", zipFile.Name)
-				sourceReader, err := zipFile.Open()
-				if err != nil {
-					return nil, err
+				if nil != err {
+					return err
 				}
-				io.Copy(embedWriter, sourceReader)
 			}
-		} else {
-			ctx.logger.Warn("Failed to load /resources/provision/node_modules.zip for embedding", err)
+			return nil
+		}
+		err := walkFileSystem("/")
+		if nil != err {
+			return nil, fmt.Errorf("Failed to read asset filesystem: %s", err.Error())
 		}
-		return createUploadStep(tmpFile.Name()), nil
 	}
+	return entries, nil
 }
 
-// Upload the ZIP archive to S3
-func createUploadStep(packagePath string) workflowStep {
-	return func(ctx *workflowContext) (workflowStep, error) {
-		reader, err := os.Open(packagePath)
+// Log the archive size and warn if the uncompressed size approaches the
+// AWS Lambda limit
+func logArchiveSize(archivePath string, logger *logrus.Logger) error {
+	reader, err := zip.OpenReader(archivePath)
+	if nil != err {
+		return err
+	}
+	defer reader.Close()
+	var uncompressedSize uint64
+	for _, eachFile := range reader.File {
+		uncompressedSize += eachFile.UncompressedSize64
+	}
+	stat, err := os.Stat(archivePath)
+	if nil != err {
+		return err
+	}
+	fields := logrus.Fields{
+		"CompressedSizeMB":   stat.Size() / (1024 * 1024),
+		"UncompressedSizeMB": uncompressedSize / (1024 * 1024),
+		"LimitMB":            lambdaUnzippedSizeLimit / (1024 * 1024),
+	}
+	if float64(uncompressedSize) >= lambdaSizeWarningRatio*lambdaUnzippedSizeLimit {
+		logger.WithFields(fields).Warn("ZIP archive size is approaching the AWS Lambda code size limit")
+	} else {
+		logger.WithFields(fields).Info("ZIP archive size")
+	}
+	return nil
+}
+
+// Returns the go build command for the options
+func buildCommand(executableOutput string, options *BuildOptions) *exec.Cmd {
+	if nil == options {
+		options = &BuildOptions{}
+	}
+	tags := append([]string{"lambdabinary"}, options.Tags...)
+	args := []string{"build", "-o", executableOutput, "-tags", strings.Join(tags, " ")}
+	if "" != options.LDFlags {
+		args = append(args, "-ldflags", options.LDFlags)
+	}
+	args = append(args, options.Flags...)
+	packagePath := options.PackagePath
+	if "" == packagePath {
+		packagePath = "."
+	}
+	args = append(args, packagePath)
+
+	cmd := exec.Command("go", args...)
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, "GOOS=linux", "GOARCH=amd64", "GO15VENDOREXPERIMENT=1")
+	cmd.Env = append(cmd.Env, options.Env...)
+	return cmd
+}
+
+// Compile the Lambda binary.  The build output is logged at debug level and
+// included in the error if the build fails.
+func buildExecutable(executableOutput string, options *BuildOptions, logger *logrus.Logger) error {
+	cmd := buildCommand(executableOutput, options)
+	logger.Debug("Building application binary: ", cmd.Args)
+	logger.Info("Compiling binary: ", executableOutput)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if 0 != output.Len() {
+		logger.Debug("Build output:\n", output.String())
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to compile binary: %s\n%s", err.Error(), strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// lambdaPackage is a ZIP archive that contains the binary for one or more
+// Lambda functions
+type lambdaPackage struct {
+	// Package group name.  Empty unless BuildOptions.SplitPackages is true.
+	group   string
+	lambdas []*LambdaAWSInfo
+	// Local path, content hash and S3 keyname of the archive, defined once
+	// it's created
+	archivePath string
+	archiveHash string
+	s3Key       string
+}
+
+// Returns the S3 keyname of a code archive.  Artifact keys are prefixed by
+// the stack name s.t. the stacks of different deployment targets can share
+// a bucket.
+func codeArchiveKey(stackName string, archiveHash string) string {
+	return fmt.Sprintf("%s-code-%s.zip", sanitizedName(stackName), archiveHash)
+}
+
+// Returns copies of the packages whose S3 keynames are scoped to the stack.
+// The copies share the archive files of the source packages.
+func stackPackages(packages []*lambdaPackage, stackName string) []*lambdaPackage {
+	copies := make([]*lambdaPackage, len(packages))
+	for index, eachPackage := range packages {
+		stackPackage := *eachPackage
+		stackPackage.s3Key = codeArchiveKey(stackName, eachPackage.archiveHash)
+		copies[index] = &stackPackage
+	}
+	return copies
+}
+
+// Characters that aren't valid in a build tag or binary name
+var rePackageGroupName = regexp.MustCompile("[^A-Za-z0-9_]+")
+
+// Returns the group name in the form used in build tags and binary names
+func packageGroupName(group string) string {
+	return rePackageGroupName.ReplaceAllString(group, "_")
+}
+
+// Returns the build tag that identifies the group's binary
+func packageGroupBuildTag(group string) string {
+	return fmt.Sprintf("sparta_group_%s", packageGroupName(group))
+}
+
+// Returns the build options for the package's binary.  Split packages add
+// the group build tag s.t. each binary only includes the code its functions
+// require.
+func (pkg *lambdaPackage) buildOptions(options *BuildOptions) *BuildOptions {
+	if "" == pkg.group {
+		return options
+	}
+	groupOptions := BuildOptions{}
+	if nil != options {
+		groupOptions = *options
+	}
+	groupOptions.Tags = append(append([]string{}, groupOptions.Tags...), packageGroupBuildTag(pkg.group))
+	return &groupOptions
+}
+
+// Returns the packages for the functions.  All functions share a single
+// package unless BuildOptions.SplitPackages is true, in which case functions
+// are grouped by LambdaAWSInfo.PackageGroup.  Functions without a group are
+// packaged individually.
+func lambdaPackages(lambdaAWSInfos []*LambdaAWSInfo, options *BuildOptions) ([]*lambdaPackage, error) {
+	if nil != options && options.CompressBinary {
+		return nil, errors.New("BuildOptions.CompressBinary is not supported. The ZIP archive already deflates the binary, and the NodeJS runtime has no xz or zstd decoder that would reduce the archive size further")
+	}
+	if nil == options || !options.SplitPackages {
+		return []*lambdaPackage{
+			{
+				lambdas: lambdaAWSInfos,
+			},
+		}, nil
+	}
+	if "" != options.PrebuiltBinary {
+		return nil, errors.New("BuildOptions.PrebuiltBinary is not supported with BuildOptions.SplitPackages")
+	}
+	var packages []*lambdaPackage
+	packagesByName := make(map[string]*lambdaPackage, 0)
+	for _, eachLambda := range lambdaAWSInfos {
+		group := eachLambda.packageGroup()
+		// Groups must have distinct build tags and binary names
+		groupName := packageGroupName(group)
+		existing, exists := packagesByName[groupName]
+		if exists && existing.group != group {
+			return nil, fmt.Errorf("Package groups %s and %s have the same sanitized name", existing.group, group)
+		}
+		if !exists {
+			existing = &lambdaPackage{
+				group: group,
+			}
+			packagesByName[groupName] = existing
+			packages = append(packages, existing)
+		}
+		existing.lambdas = append(existing.lambdas, eachLambda)
+	}
+	return packages, nil
+}
+
+// Returns the NodeJS proxy source for the package.  Only the package's
+// functions are exported.
+func nodeJSProxySource(ctx *workflowContext,
+	pkg *lambdaPackage,
+	executableOutput string,
+	transport string) (string, error) {
+
+	// Add the string literal adapter, which requires us to add exported
+	// functions to the end of index.js
+	nodeJSSource := escFSMustString(false, "/resources/index.js")
+	nodeJSSource += "\n// DO NOT EDIT - CONTENT UNTIL EOF IS AUTOMATICALLY GENERATED\n"
+	for _, eachLambda := range pkg.lambdas {
+		nodeJSSource += createNewNodeJSProxyEntry(eachLambda, ctx.logger)
+	}
+	// Finally, replace
+	// 	SPARTA_BINARY_NAME = 'Sparta.lambda.amd64';
+	// with the service binary name
+	nodeJSSource += fmt.Sprintf("SPARTA_BINARY_NAME='%s';\n", executableOutput)
+	// and the transport used to forward events
+	nodeJSSource += fmt.Sprintf("SPARTA_TRANSPORT='%s';\n", transport)
+	// and whether the metrics are published
+	nodeJSSource += fmt.Sprintf("SPARTA_METRICS=%t;\n", ctx.provisionOptions().Metrics)
+	nodeJSSource += fmt.Sprintf("SPARTA_CONTAINER_METRICS=%t;\n", ctx.provisionOptions().ContainerMetrics)
+	// and the package group whose functions the binary dispatches to
+	packageGroup, err := json.Marshal(pkg.group)
+	if nil != err {
+		return "", err
+	}
+	nodeJSSource += fmt.Sprintf("SPARTA_PACKAGE_GROUP=%s;\n", packageGroup)
+	ctx.logger.Debug("Dynamically generated NodeJS adapter:\n", nodeJSSource)
+	return nodeJSSource, nil
+}
+
+// Build the binary and ZIP archive for the package.  The archive is written
+// to a temporary file in the working directory.
+func createPackage(ctx *workflowContext, pkg *lambdaPackage, transport string) error {
+	// Compile the source to linux...
+	sanitizedServiceName := sanitizedName(ctx.serviceName)
+	executableOutput := fmt.Sprintf("%s.lambda.amd64", sanitizedServiceName)
+	if "" != pkg.group {
+		executableOutput = fmt.Sprintf("%s-%s.lambda.amd64", sanitizedServiceName, packageGroupName(pkg.group))
+	}
+	binaryPath := executableOutput
+	buildOptions := ctx.provisionOptions().Build
+	if nil != buildOptions && "" != buildOptions.PrebuiltBinary {
+		binaryPath = buildOptions.PrebuiltBinary
+		ctx.logger.Info("Using prebuilt binary: ", binaryPath)
+	} else {
+		err := buildExecutable(executableOutput, pkg.buildOptions(buildOptions), ctx.logger)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to open local archive for S3 upload: %s", err.Error())
+			return err
 		}
-		defer func() {
-			reader.Close()
-			os.Remove(packagePath)
-		}()
+		defer os.Remove(executableOutput)
+	}
+
+	// Binary size
+	stat, err := os.Stat(binaryPath)
+	if err != nil {
+		return fmt.Errorf("Failed to stat binary: %s", binaryPath)
+	}
+	// Minimum hello world size is 2.3M
+	// Minimum HTTP hello world is 6.3M
+	ctx.logger.Info("Executable binary size (MB): ", stat.Size()/(1024*1024))
+
+	// Collect the archive entries
+	var entries []archiveEntry
+	entries = append(entries, archiveEntry{
+		name: filepath.Base(executableOutput),
+		open: func() (io.ReadCloser, error) {
+			return os.Open(binaryPath)
+		},
+	})
 
-		body, err := os.Open(packagePath)
+	nodeJSSource, err := nodeJSProxySource(ctx, pkg, executableOutput, transport)
+	if nil != err {
+		return err
+	}
+	entries = append(entries, stringArchiveEntry("index.js", nodeJSSource))
+
+	// User assets
+	assetEntries, err := assetArchiveEntries(ctx.provisionOptions().Assets)
+	if nil != err {
+		return err
+	}
+	if len(assetEntries) > 0 {
+		ctx.logger.Info("Embedding asset files: ", len(assetEntries))
+		entries = append(entries, assetEntries...)
+	}
+
+	// Also embed the custom resource creation scripts
+	for _, eachName := range customResourceScripts {
+		resourceName := fmt.Sprintf("/resources/provision/%s", eachName)
+		ctx.logger.Info("Embedding CustomResource script: ", eachName)
+		entries = append(entries, stringArchiveEntry(eachName, escFSMustString(false, resourceName)))
+	}
+
+	// And finally, if there is a node_modules.zip file, then include it.
+	nodeModuleBytes, err := escFSByte(false, "/resources/provision/node_modules.zip")
+	if nil == err {
+		nodeModuleReader, err := zip.NewReader(bytes.NewReader(nodeModuleBytes), int64(len(nodeModuleBytes)))
+		if err != nil {
+			return err
+		}
+		for _, zipFile := range nodeModuleReader.File {
+			ctx.logger.Debug("Copying node_module file: ", zipFile.Name)
+			entries = append(entries, archiveEntry{
+				name: zipFile.Name,
+				open: zipFile.Open,
+			})
+		}
+	} else {
+		ctx.logger.Warn("Failed to load /resources/provision/node_modules.zip for embedding", err)
+	}
+
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return errors.New("Failed to retrieve working directory")
+	}
+	tmpFile, err := ioutil.TempFile(workingDir, sanitizedServiceName)
+	if err != nil {
+		return errors.New("Failed to create temporary file")
+	}
+	ctx.logger.Info("Creating ZIP archive for upload: ", tmpFile.Name())
+
+	// Hash the archive as it's written s.t. the S3 key is content-addressable
+	hash := sha1.New()
+	err = writeArchive(io.MultiWriter(tmpFile, hash), entries)
+	closeErr := tmpFile.Close()
+	if nil == err {
+		err = closeErr
+	}
+	if nil != err {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("Failed to create ZIP archive: %s", err.Error())
+	}
+	err = logArchiveSize(tmpFile.Name(), ctx.logger)
+	if nil != err {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("Failed to read ZIP archive: %s", err.Error())
+	}
+	pkg.archivePath = tmpFile.Name()
+	pkg.archiveHash = hex.EncodeToString(hash.Sum(nil))
+	pkg.s3Key = codeArchiveKey(ctx.stackName, pkg.archiveHash)
+	return nil
+}
+
+// Build the binaries and ZIP archives for all of the application's packages.
+// The caller is responsible for removing the archives.
+func buildPackages(ctx *workflowContext) ([]*lambdaPackage, error) {
+	transport := ctx.provisionOptions().Transport
+	err := validateTransport(transport)
+	if nil != err {
+		return nil, err
+	}
+	if "" == transport {
+		transport = TransportHTTP
+	}
+	packages, err := lambdaPackages(ctx.lambdaAWSInfos, ctx.provisionOptions().Build)
+	if nil != err {
+		return nil, err
+	}
+	for index, eachPackage := range packages {
+		if "" != eachPackage.group {
+			ctx.logger.WithFields(logrus.Fields{
+				"Group":     eachPackage.group,
+				"Functions": len(eachPackage.lambdas),
+			}).Info("Packaging Lambda group")
+		}
+		err = createPackage(ctx, eachPackage, transport)
 		if nil != err {
+			// Remove the archives that were already created
+			removePackageArchives(packages[0:index])
 			return nil, err
 		}
-		keyName := filepath.Base(packagePath)
-		// Cache it in case there was an error & we need to cleanup
-		ctx.s3LambdaZipKey = keyName
-		uploadInput := &s3manager.UploadInput{
-			Bucket:      &ctx.s3Bucket,
-			Key:         &keyName,
-			ContentType: aws.String("application/zip"),
-			Body:        body,
+	}
+	return packages, nil
+}
+
+// Remove the local ZIP archives of the packages
+func removePackageArchives(packages []*lambdaPackage) {
+	for _, eachPackage := range packages {
+		os.Remove(eachPackage.archivePath)
+	}
+}
+
+// Build and package the application.  Packages that were built for several
+// deployment targets are only uploaded.
+func createPackageStep() workflowStep {
+
+	return func(ctx *workflowContext) (workflowStep, error) {
+		if len(ctx.prebuiltPackages) != 0 {
+			return createUploadStep(stackPackages(ctx.prebuiltPackages, ctx.stackName), false), nil
+		}
+		packages, err := buildPackages(ctx)
+		if nil != err {
+			return nil, err
 		}
+		return createUploadStep(packages, true), nil
+	}
+}
 
-		if ctx.noop {
-			ctx.logger.WithFields(logrus.Fields{
-				"Bucket": ctx.s3Bucket,
-				"Key":    keyName,
-			}).Info("Bypassing S3 ZIP upload due to -n/-noop command line argument")
-		} else {
-			ctx.logger.Info("Uploading ZIP archive to S3")
-			uploader := s3manager.NewUploader(session.New())
-			result, err := uploader.Upload(uploadInput)
+// Upload the ZIP archives to S3.  The local archives are removed afterwards
+// if removeArchives is true.
+func createUploadStep(packages []*lambdaPackage, removeArchives bool) workflowStep {
+	return func(ctx *workflowContext) (workflowStep, error) {
+		if removeArchives {
+			defer removePackageArchives(packages)
+		}
+		ctx.lambdaS3Keys = make(map[string]string, 0)
+		for _, eachPackage := range packages {
+			ctx.codeArchiveKeys = append(ctx.codeArchiveKeys, eachPackage.s3Key)
+			for _, eachLambda := range eachPackage.lambdas {
+				ctx.lambdaS3Keys[eachLambda.lambdaFnName] = eachPackage.s3Key
+			}
+		}
+		// The first archive also provides the CustomResource scripts
+		primaryKey := packages[0].s3Key
+
+		for _, eachPackage := range packages {
+			err := uploadPackage(ctx, eachPackage.archivePath, eachPackage.s3Key)
 			if nil != err {
 				return nil, err
 			}
-			ctx.logger.Info("ZIP archive uploaded: ", result.Location)
 		}
-		return ensureCloudFormationStack(keyName), nil
+		return ensureCloudFormationStack(primaryKey), nil
+	}
+}
+
+// Upload a single ZIP archive to S3, or write it to the package output
+// directory
+func uploadPackage(ctx *workflowContext, packagePath string, keyName string) error {
+	if !ctx.noop {
+		hookContext := ctx.hookContext(keyName)
+		hookContext.ArchivePath = packagePath
+		err := runWorkflowHooks(ctx, hookPhasePostBuild, hookContext)
+		if nil != err {
+			return err
+		}
+	}
+
+	// Offline packages keep the archive on disk
+	if "" != ctx.packageOutputDir {
+		outputPath := filepath.Join(ctx.packageOutputDir, keyName)
+		err := copyFile(packagePath, outputPath)
+		if nil != err {
+			return fmt.Errorf("Failed to write ZIP archive to %s: %s", outputPath, err.Error())
+		}
+		ctx.logger.Info("ZIP archive written: ", outputPath)
+		return nil
+	}
+
+	if ctx.noop {
+		ctx.logger.WithFields(logrus.Fields{
+			"Bucket": ctx.s3Bucket,
+			"Key":    keyName,
+		}).Info("Bypassing S3 ZIP upload due to -n/-noop command line argument")
+		return nil
+	}
+
+	// The key is content-addressable, so an existing object means
+	// this code has already been uploaded
+	exists, err := s3ObjectExists(ctx.s3Bucket, keyName, ctx.awsSession)
+	if nil != err {
+		return err
+	}
+	if exists {
+		ctx.logger.WithFields(logrus.Fields{
+			"Bucket": ctx.s3Bucket,
+			"Key":    keyName,
+		}).Info("Bypassing S3 ZIP upload for unchanged archive")
+		return nil
+	}
+
+	body, err := os.Open(packagePath)
+	if nil != err {
+		return fmt.Errorf("Failed to open local archive for S3 upload: %s", err.Error())
+	}
+	defer body.Close()
+
+	uploadInput := &s3manager.UploadInput{
+		Bucket:      &ctx.s3Bucket,
+		Key:         &keyName,
+		ContentType: aws.String("application/zip"),
+		Body:        body,
+	}
+	ctx.logger.Info("Uploading ZIP archive to S3")
+	uploader := s3manager.NewUploader(ctx.awsSession)
+	result, err := uploader.Upload(uploadInput)
+	if nil != err {
+		return err
+	}
+	// Cache it in case there was an error & we need to cleanup.  Archives
+	// that were already in the bucket may be in use and are left alone.
+	ctx.s3LambdaZipKeys = append(ctx.s3LambdaZipKeys, keyName)
+	ctx.logger.Info("ZIP archive uploaded: ", result.Location)
+	return nil
+}
+
+// Does a given S3 object exist?
+func s3ObjectExists(bucket string, key string, awsSession *session.Session) (bool, error) {
+	headObjectInput := &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	_, err := s3.New(awsSession).HeadObject(headObjectInput)
+	if nil != err {
+		if awsErr, ok := err.(awserr.RequestFailure); ok && awsErr.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Return the failed resource StackEvents for the given StackName/StackID that
+// occurred after the since time, oldest first
+func stackFailureEvents(stackID string, since time.Time, cfService *cloudformation.CloudFormation) ([]*cloudformation.StackEvent, error) {
+	events, err := stackEvents(stackID, cfService)
+	if nil != err {
+		return nil, err
+	}
+	var failedEvents []*cloudformation.StackEvent
+	for index := len(events) - 1; index >= 0; index-- {
+		eachEvent := events[index]
+		if eachEvent.Timestamp.Before(since) {
+			continue
+		}
+		switch *eachEvent.ResourceStatus {
+		case cloudformation.ResourceStatusCreateFailed,
+			cloudformation.ResourceStatusDeleteFailed,
+			cloudformation.ResourceStatusUpdateFailed:
+			failedEvents = append(failedEvents, eachEvent)
+		default:
+			// NOP
+		}
+	}
+	return failedEvents, nil
+}
+
+// Log the StackEvents for the given StackID that occurred after the since time
+// and haven't already been logged, oldest first
+func logNewStackEvents(stackID string,
+	since time.Time,
+	loggedEvents map[string]bool,
+	cfService *cloudformation.CloudFormation,
+	logger *logrus.Logger) error {
+
+	// Events are returned newest first, so stop paging once we're past
+	// the start of the operation
+	var newEvents []*cloudformation.StackEvent
+	params := &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stackID),
+	}
+	err := cfService.DescribeStackEventsPages(params, func(page *cloudformation.DescribeStackEventsOutput, lastPage bool) bool {
+		for _, eachEvent := range page.StackEvents {
+			if eachEvent.Timestamp.Before(since) {
+				return false
+			}
+			if !loggedEvents[*eachEvent.EventId] {
+				newEvents = append(newEvents, eachEvent)
+			}
+		}
+		return true
+	})
+	if nil != err {
+		return err
+	}
+	for index := len(newEvents) - 1; index >= 0; index-- {
+		eachEvent := newEvents[index]
+		loggedEvents[*eachEvent.EventId] = true
+		fields := logrus.Fields{
+			"Resource": aws.StringValue(eachEvent.LogicalResourceId),
+			"Type":     aws.StringValue(eachEvent.ResourceType),
+			"Status":   aws.StringValue(eachEvent.ResourceStatus),
+		}
+		if nil != eachEvent.ResourceStatusReason {
+			fields["Reason"] = *eachEvent.ResourceStatusReason
+		}
+		logger.WithFields(fields).Info("Stack event")
+	}
+	return nil
+}
+
+// Poll the stack until the current operation reaches a terminal state,
+// logging the stack events as they occur.  The since time should
+// predate the API call that started the operation.
+func waitForStackOperationComplete(stackID string,
+	since time.Time,
+	cfService *cloudformation.CloudFormation,
+	logger *logrus.Logger) (*cloudformation.Stack, error) {
+
+	describeStacksInput := &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackID),
+	}
+	loggedEvents := make(map[string]bool, 0)
+	logger.Info("Waiting for stack to complete")
+	for {
+		time.Sleep(10 * time.Second)
+		describeStacksOutput, err := cfService.DescribeStacks(describeStacksInput)
+		if nil != err {
+			return nil, err
+		}
+		if len(describeStacksOutput.Stacks) != 1 {
+			return nil, fmt.Errorf("More than one stack returned for: %s", stackID)
+		}
+		err = logNewStackEvents(stackID, since, loggedEvents, cfService, logger)
+		if nil != err {
+			logger.Warn("Failed to describe stack events: ", err.Error())
+		}
+		stackInfo := describeStacksOutput.Stacks[0]
+		logger.Info("Current state: ", *stackInfo.StackStatus)
+		switch *stackInfo.StackStatus {
+		case cloudformation.StackStatusCreateInProgress,
+			cloudformation.StackStatusDeleteInProgress,
+			cloudformation.StackStatusUpdateInProgress,
+			cloudformation.StackStatusRollbackInProgress,
+			cloudformation.StackStatusUpdateCompleteCleanupInProgress,
+			cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress,
+			cloudformation.StackStatusUpdateRollbackInProgress:
+			// NOP
+		default:
+			return stackInfo, nil
+		}
 	}
 }
 
@@ -360,38 +988,173 @@ func stackExists(stackNameOrID string, cf *cloudformation.CloudFormation, logger
 	return exists, nil
 }
 
+// Returns the stack parameter values, sorted by key
+func stackParameters(options *ProvisionOptions) []*cloudformation.Parameter {
+	var keys []string
+	for eachKey := range options.Parameters {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	var parameters []*cloudformation.Parameter
+	for _, eachKey := range keys {
+		parameters = append(parameters, &cloudformation.Parameter{
+			ParameterKey:   aws.String(eachKey),
+			ParameterValue: aws.String(options.Parameters[eachKey]),
+		})
+	}
+	return parameters
+}
+
+// Returns the stack tags, sorted by key
+func stackTags(options *ProvisionOptions) []*cloudformation.Tag {
+	var keys []string
+	for eachKey := range options.Tags {
+		keys = append(keys, eachKey)
+	}
+	sort.Strings(keys)
+	var tags []*cloudformation.Tag
+	for _, eachKey := range keys {
+		tags = append(tags, &cloudformation.Tag{
+			Key:   aws.String(eachKey),
+			Value: aws.String(options.Tags[eachKey]),
+		})
+	}
+	return tags
+}
+
+// Returns the stack notification ARNs.  The value is nil if there are no ARNs
+// s.t. UpdateStack preserves the stack's existing notification topics, which
+// an empty list would remove.
+func stackNotificationARNs(options *ProvisionOptions) []*string {
+	if len(options.NotificationARNs) <= 0 {
+		return nil
+	}
+	return aws.StringSlice(options.NotificationARNs)
+}
+
+// Returns the template Parameters section for the provision options
+func templateParameters(options *ProvisionOptions) ArbitraryJSONObject {
+	parameters := make(ArbitraryJSONObject, 0)
+	for eachKey := range options.Parameters {
+		parameters[eachKey] = ArbitraryJSONObject{
+			"Type": "String",
+		}
+	}
+	return parameters
+}
+
+// Returns the non-nil provision options for this workflow
+func (ctx *workflowContext) provisionOptions() *ProvisionOptions {
+	if nil == ctx.options {
+		return &ProvisionOptions{}
+	}
+	return ctx.options
+}
+
 // TODO: Replace this with the implementation
 // provided by vendor/github.com/aws/aws-sdk-go/service/cloudformation/waiters.go
+// Subset of the CloudFormation API used to update a stack
+type stackUpdater interface {
+	UpdateStack(*cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error)
+	DescribeStacks(*cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error)
+}
+
+// Returns true if the error is the ValidationError that UpdateStack returns
+// when the template and parameters are unchanged
+func isNoUpdatesError(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok &&
+		"ValidationError" == awsErr.Code() &&
+		strings.Contains(awsErr.Message(), "No updates are to be performed")
+}
+
+// Issue the UpdateStack request and return the stack ID of the update.  If
+// there is nothing to update, the stack ID is empty and the current stack is
+// returned instead, so that re-provisioning an unchanged service succeeds.
+func updateStack(cf stackUpdater,
+	updateStackInput *cloudformation.UpdateStackInput,
+	logger *logrus.Logger) (string, *cloudformation.Stack, error) {
+
+	updateStackResponse, err := cf.UpdateStack(updateStackInput)
+	if nil == err {
+		logger.Info("Issued update request: ", *updateStackResponse.StackId)
+		return *updateStackResponse.StackId, nil, nil
+	}
+	if !isNoUpdatesError(err) {
+		return "", nil, err
+	}
+	logger.Info("Stack is up to date: ", *updateStackInput.StackName)
+	describeStacksOutput, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: updateStackInput.StackName,
+	})
+	if nil != err {
+		return "", nil, err
+	}
+	if len(describeStacksOutput.Stacks) <= 0 {
+		return "", nil, fmt.Errorf("Stack does not exist: %s", *updateStackInput.StackName)
+	}
+	return "", describeStacksOutput.Stacks[0], nil
+}
+
 func convergeStackState(cfTemplateURL string, ctx *workflowContext) (*cloudformation.Stack, error) {
 	awsCloudFormation := cloudformation.New(ctx.awsSession)
+	options := ctx.provisionOptions()
 
 	// Does it exist?
-	exists, err := stackExists(ctx.serviceName, awsCloudFormation, ctx.logger)
+	exists, err := stackExists(ctx.stackName, awsCloudFormation, ctx.logger)
 	if nil != err {
 		return nil, err
 	}
+	startTime := time.Now()
 	stackID := ""
+	// Defined if the stack is already up to date
+	var stackInfo *cloudformation.Stack
 	if exists {
+		// Termination protection isn't part of UpdateStack.  Only change it
+		// if the caller defined a value s.t. protection applied outside
+		// Sparta is preserved.
+		if nil != options.EnableTerminationProtection {
+			_, err := awsCloudFormation.UpdateTerminationProtection(&cloudformation.UpdateTerminationProtectionInput{
+				StackName:                   aws.String(ctx.stackName),
+				EnableTerminationProtection: options.EnableTerminationProtection,
+			})
+			if nil != err {
+				return nil, err
+			}
+		}
 		// Update stack
 		updateStackInput := &cloudformation.UpdateStackInput{
-			StackName:    aws.String(ctx.serviceName),
-			TemplateURL:  aws.String(cfTemplateURL),
-			Capabilities: []*string{aws.String("CAPABILITY_IAM")},
+			StackName:        aws.String(ctx.stackName),
+			TemplateURL:      aws.String(cfTemplateURL),
+			Capabilities:     []*string{aws.String("CAPABILITY_IAM")},
+			Parameters:       stackParameters(options),
+			Tags:             stackTags(options),
+			NotificationARNs: stackNotificationARNs(options),
 		}
-		updateStackResponse, err := awsCloudFormation.UpdateStack(updateStackInput)
+		stackID, stackInfo, err = updateStack(awsCloudFormation, updateStackInput, ctx.logger)
 		if nil != err {
 			return nil, err
 		}
-		ctx.logger.Info("Issued update request: ", *updateStackResponse.StackId)
-		stackID = *updateStackResponse.StackId
 	} else {
+		timeoutInMinutes := options.TimeoutInMinutes
+		if timeoutInMinutes <= 0 {
+			timeoutInMinutes = DefaultStackTimeoutInMinutes
+		}
+		onFailure := options.OnFailure
+		if "" == onFailure {
+			onFailure = cloudformation.OnFailureDelete
+		}
 		// Create stack
 		createStackInput := &cloudformation.CreateStackInput{
-			StackName:        aws.String(ctx.serviceName),
-			TemplateURL:      aws.String(cfTemplateURL),
-			TimeoutInMinutes: aws.Int64(5),
-			OnFailure:        aws.String(cloudformation.OnFailureDelete),
-			Capabilities:     []*string{aws.String("CAPABILITY_IAM")},
+			StackName:                   aws.String(ctx.stackName),
+			TemplateURL:                 aws.String(cfTemplateURL),
+			TimeoutInMinutes:            aws.Int64(timeoutInMinutes),
+			OnFailure:                   aws.String(onFailure),
+			Capabilities:                []*string{aws.String("CAPABILITY_IAM")},
+			Parameters:                  stackParameters(options),
+			Tags:                        stackTags(options),
+			NotificationARNs:            stackNotificationARNs(options),
+			EnableTerminationProtection: options.EnableTerminationProtection,
 		}
 		createStackResponse, err := awsCloudFormation.CreateStack(createStackInput)
 		if nil != err {
@@ -399,41 +1162,14 @@ func convergeStackState(cfTemplateURL string, ctx *workflowContext) (*cloudforma
 		}
 		ctx.logger.Info("Creating stack: ", *createStackResponse.StackId)
 		stackID = *createStackResponse.StackId
+		ctx.stackCreated = true
 	}
 
-	// Poll for the current stackID state
-	describeStacksInput := &cloudformation.DescribeStacksInput{
-		StackName: aws.String(stackID),
-	}
-
-	var stackInfo *cloudformation.Stack
-	stackOperationComplete := false
-	ctx.logger.Info("Waiting for stack to complete")
-	for !stackOperationComplete {
-		time.Sleep(10 * time.Second)
-		describeStacksOutput, err := awsCloudFormation.DescribeStacks(describeStacksInput)
+	if nil == stackInfo {
+		stackInfo, err = waitForStackOperationComplete(stackID, startTime, awsCloudFormation, ctx.logger)
 		if nil != err {
 			return nil, err
 		}
-		if len(describeStacksOutput.Stacks) > 0 {
-			stackInfo = describeStacksOutput.Stacks[0]
-			ctx.logger.Info("Current state: ", *stackInfo.StackStatus)
-			switch *stackInfo.StackStatus {
-			case cloudformation.StackStatusCreateInProgress,
-				cloudformation.StackStatusDeleteInProgress,
-				cloudformation.StackStatusUpdateInProgress,
-				cloudformation.StackStatusRollbackInProgress,
-				cloudformation.StackStatusUpdateCompleteCleanupInProgress,
-				cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress,
-				cloudformation.StackStatusUpdateRollbackInProgress:
-				time.Sleep(20 * time.Second)
-			default:
-				stackOperationComplete = true
-				break
-			}
-		} else {
-			return nil, fmt.Errorf("More than one stack returned for: %s", stackID)
-		}
 	}
 	// What happened?
 	succeed := true
@@ -448,39 +1184,53 @@ func convergeStackState(cfTemplateURL string, ctx *workflowContext) (*cloudforma
 	// If it didn't work, then output some failure information
 	if !succeed {
 		// Get the stack events and find the ones that failed.
-		events, err := stackEvents(stackID, awsCloudFormation)
+		events, err := stackFailureEvents(stackID, startTime, awsCloudFormation)
 		if nil != err {
 			return nil, err
 		}
 		ctx.logger.Error("Stack provisioning failed.")
 		for _, eachEvent := range events {
-			switch *eachEvent.ResourceStatus {
-			case cloudformation.ResourceStatusCreateFailed,
-				cloudformation.ResourceStatusDeleteFailed,
-				cloudformation.ResourceStatusUpdateFailed:
-				errMsg := fmt.Sprintf("\tError ensuring %s (%s): %s",
-					*eachEvent.ResourceType,
-					*eachEvent.LogicalResourceId,
-					*eachEvent.ResourceStatusReason)
-				ctx.logger.Error(errMsg)
-			default:
-				// NOP
-			}
+			errMsg := fmt.Sprintf("\tError ensuring %s (%s): %s",
+				*eachEvent.ResourceType,
+				*eachEvent.LogicalResourceId,
+				aws.StringValue(eachEvent.ResourceStatusReason))
+			ctx.logger.Error(errMsg)
 		}
-		return nil, fmt.Errorf("Failed to provision: %s", ctx.serviceName)
+		return nil, fmt.Errorf("Failed to provision: %s", ctx.stackName)
 	} else if nil != stackInfo.Outputs {
 		ctx.logger.Info("Stack Outputs:")
 		for _, eachOutput := range stackInfo.Outputs {
 			ctx.logger.WithFields(logrus.Fields{
 				"Key":         *eachOutput.OutputKey,
 				"Value":       *eachOutput.OutputValue,
-				"Description": *eachOutput.Description,
+				"Description": aws.StringValue(eachOutput.Description),
 			}).Info("\tOutput")
 		}
 	}
 	return stackInfo, nil
 }
 
+// Apply the artifact retention policy after a successful provision.  Failing
+// to prune doesn't fail the provision.
+func pruneServiceArtifacts(ctx *workflowContext) {
+	err := pruneArtifacts(ctx.stackName,
+		ctx.s3Bucket,
+		DefaultArtifactRetentionCount,
+		false,
+		ctx.awsSession,
+		ctx.logger)
+	if nil != err {
+		ctx.logger.Warn("Failed to prune service artifacts: ", err.Error())
+	}
+}
+
+// Returns the content-addressable S3 keyname for the stack's template body
+func templateS3Key(stackName string, templateBody []byte) string {
+	hash := sha1.New()
+	hash.Write(templateBody)
+	return fmt.Sprintf("%s-%s-cf.json", sanitizedName(stackName), hex.EncodeToString(hash.Sum(nil)))
+}
+
 func ensureCloudFormationStack(s3Key string) workflowStep {
 	return func(ctx *workflowContext) (workflowStep, error) {
 		// We're going to create a template that represents the new state of the
@@ -489,17 +1239,53 @@ func ensureCloudFormationStack(s3Key string) workflowStep {
 			"AWSTemplateFormatVersion": "2010-09-09",
 			"Description":              ctx.serviceDescription,
 		}
+		// Track the resources that each lambda adds s.t. they can be
+		// moved into a nested stack if the template is too large
+		var lambdaResourceGroups [][]string
 		for _, eachEntry := range ctx.lambdaAWSInfos {
-			err := eachEntry.export(ctx.s3Bucket, s3Key, ctx.lambdaIAMRoleNameMap, ctx.cloudformationResources, ctx.cloudformationOutputs, ctx.logger)
+			existingResources := make(map[string]bool, len(ctx.cloudformationResources))
+			for eachKey := range ctx.cloudformationResources {
+				existingResources[eachKey] = true
+			}
+			// Split packages use a different archive for each function
+			lambdaS3Key := s3Key
+			if groupS3Key, exists := ctx.lambdaS3Keys[eachEntry.lambdaFnName]; exists {
+				lambdaS3Key = groupS3Key
+			}
+			err := eachEntry.export(ctx.s3Bucket, lambdaS3Key, ctx.lambdaIAMRoleNameMap, ctx.provisionOptions().Config, ctx.cloudformationResources, ctx.cloudformationOutputs, ctx.logger)
 			if nil != err {
 				return nil, err
 			}
+			var lambdaResources []string
+			for eachKey := range ctx.cloudformationResources {
+				if !existingResources[eachKey] && eachKey != eachEntry.logicalName() {
+					lambdaResources = append(lambdaResources, eachKey)
+				}
+			}
+			sort.Strings(lambdaResources)
+			lambdaResourceGroups = append(lambdaResourceGroups,
+				append([]string{eachEntry.logicalName()}, lambdaResources...))
 		}
 		// If there's an API gateway definition, provision custom resources
 		// and IAM role to
 		if nil != ctx.api {
 			ctx.api.export(ctx.s3Bucket, s3Key, ctx.lambdaIAMRoleNameMap, ctx.cloudformationResources, ctx.cloudformationOutputs, ctx.logger)
 		}
+		// Optional service dashboard
+		if ctx.provisionOptions().Dashboard && len(ctx.lambdaAWSInfos) > 0 {
+			dashboard, err := dashboardResource(ctx.lambdaAWSInfos)
+			if nil != err {
+				return nil, err
+			}
+			dashboardName := dashboardLogicalName(ctx.serviceName)
+			ctx.cloudformationResources[dashboardName] = dashboard
+			ctx.cloudformationOutputs[dashboardName] = ArbitraryJSONObject{
+				"Description": "CloudWatch Dashboard",
+				"Value": ArbitraryJSONObject{
+					"Ref": dashboardName,
+				},
+			}
+		}
 		// Add Sparta outputs
 		ctx.cloudformationOutputs[OutputSpartaVersionKey] = ArbitraryJSONObject{
 			"Description": "Sparta Version",
@@ -509,22 +1295,39 @@ func ensureCloudFormationStack(s3Key string) workflowStep {
 			"Description": "Sparta Home",
 			"Value":       "https://github.com/mweagle/Sparta",
 		}
+		// Route versioned aliases to their previous versions s.t. traffic
+		// can be shifted once the stack is updated
+		var trafficShifts []*trafficShift
+		if !ctx.noop && !ctx.validateOnly && "" == ctx.packageOutputDir {
+			shifts, err := prepareTrafficShifts(ctx)
+			if nil != err {
+				return nil, err
+			}
+			trafficShifts = shifts
+		}
+		if parameters := templateParameters(ctx.provisionOptions()); len(parameters) > 0 {
+			cloudFormationTemplate["Parameters"] = parameters
+		}
 		cloudFormationTemplate["Resources"] = ctx.cloudformationResources
 		cloudFormationTemplate["Outputs"] = ctx.cloudformationOutputs
 
+		err := validateCloudFormationTemplate(ctx, cloudFormationTemplate)
+		if nil != err {
+			return nil, err
+		}
+		if ctx.validateOnly {
+			return nil, nil
+		}
 		// Generate a complete CloudFormation template
-		cfTemplate, err := json.Marshal(cloudFormationTemplate)
-		if err != nil {
-			ctx.logger.Error("Failed to Marshal CloudFormation template: ", err.Error())
+		cfTemplate, err := serviceTemplateBody(ctx, cloudFormationTemplate, lambdaResourceGroups)
+		if nil != err {
 			return nil, err
 		}
+		ctx.templateBody = cfTemplate
 
 		// Upload the template to S3
 		contentBody := string(cfTemplate)
-		sanitizedServiceName := sanitizedName(ctx.serviceName)
-		hash := sha1.New()
-		hash.Write([]byte(contentBody))
-		s3keyName := fmt.Sprintf("%s-%s-cf.json", sanitizedServiceName, hex.EncodeToString(hash.Sum(nil)))
+		s3keyName := templateS3Key(ctx.stackName, cfTemplate)
 
 		uploadInput := &s3manager.UploadInput{
 			Bucket:      &ctx.s3Bucket,
@@ -541,29 +1344,112 @@ func ensureCloudFormationStack(s3Key string) workflowStep {
 			io.WriteString(ctx.templateWriter, string(formatted))
 		}
 
-		if ctx.noop {
+		if "" != ctx.packageOutputDir {
+			err = writePackage(ctx, ctx.codeArchiveKeys, s3keyName, cfTemplate)
+			if nil != err {
+				return nil, err
+			}
+		} else if ctx.noop {
 			ctx.logger.WithFields(logrus.Fields{
 				"Bucket": ctx.s3Bucket,
 				"Key":    s3keyName,
 			}).Info("Bypassing template upload & creation due to -n/-noop command line argument")
 		} else {
-			ctx.logger.Info("Uploading CloudFormation template")
-			uploader := s3manager.NewUploader(ctx.awsSession)
-			templateUploadResult, err := uploader.Upload(uploadInput)
+			hookContext := ctx.hookContext(s3Key)
+			hookContext.Template = cfTemplate
+			err = runWorkflowHooks(ctx, hookPhasePreTemplateUpload, hookContext)
 			if nil != err {
 				return nil, err
 			}
-			ctx.logger.Info("CloudFormation template uploaded: ", templateUploadResult.Location)
-			stack, err := convergeStackState(templateUploadResult.Location, ctx)
+			templateURL, stack, err := uploadAndConvergeTemplate(ctx, uploadInput)
+			if nil != err {
+				return nil, err
+			}
+			// The stack references the archive, so it must not be deleted
+			// if a subsequent step fails
+			ctx.s3LambdaZipKeys = nil
+			if len(trafficShifts) > 0 {
+				err = shiftAliasesTraffic(ctx, trafficShifts)
+				if nil != err {
+					return nil, err
+				}
+				// Converge the stack to the template that routes the aliases
+				// to their new versions. This is the template that is recorded
+				// in the deployment history.
+				completeTrafficShifts(trafficShifts)
+				cfTemplate, err = serviceTemplateBody(ctx, cloudFormationTemplate, lambdaResourceGroups)
+				if nil != err {
+					return nil, err
+				}
+				ctx.templateBody = cfTemplate
+				s3keyName = templateS3Key(ctx.stackName, cfTemplate)
+				uploadInput.Key = aws.String(s3keyName)
+				uploadInput.Body = bytes.NewReader(cfTemplate)
+				templateURL, stack, err = uploadAndConvergeTemplate(ctx, uploadInput)
+				if nil != err {
+					return nil, err
+				}
+			}
+			recordServiceDeployment(ctx, s3keyName, templateURL, ctx.codeArchiveKeys)
+			hookContext.Outputs = stackOutputs(stack)
+			err = runWorkflowHooks(ctx, hookPhasePostConverge, hookContext)
 			if nil != err {
 				return nil, err
 			}
-			ctx.logger.Info("Stack provisioned: ", stack)
+			pruneServiceArtifacts(ctx)
 		}
 		return nil, nil
 	}
 }
 
+// Marshal the service template, moving the Lambda resources into nested
+// stacks if the template exceeds the CloudFormation limits
+func serviceTemplateBody(ctx *workflowContext,
+	cloudFormationTemplate ArbitraryJSONObject,
+	lambdaResourceGroups [][]string) ([]byte, error) {
+
+	cfTemplate, err := json.Marshal(cloudFormationTemplate)
+	if err != nil {
+		ctx.logger.Error("Failed to Marshal CloudFormation template: ", err.Error())
+		return nil, err
+	}
+	if nestedStacksRequired(len(ctx.cloudformationResources), len(cfTemplate)) {
+		ctx.logger.WithFields(logrus.Fields{
+			"ResourceCount": len(ctx.cloudformationResources),
+			"TemplateSize":  len(cfTemplate),
+		}).Info("Moving Lambda resources into nested stacks")
+		cfTemplate, err = ensureNestedStacks(ctx, cloudFormationTemplate, lambdaResourceGroups)
+		if nil != err {
+			return nil, err
+		}
+	}
+	return cfTemplate, nil
+}
+
+// Upload the nested stack templates and the service template, then
+// converge the stack to the uploaded template
+func uploadAndConvergeTemplate(ctx *workflowContext,
+	uploadInput *s3manager.UploadInput) (string, *cloudformation.Stack, error) {
+
+	err := uploadNestedTemplates(ctx)
+	if nil != err {
+		return "", nil, err
+	}
+	ctx.logger.Info("Uploading CloudFormation template")
+	uploader := s3manager.NewUploader(ctx.awsSession)
+	templateUploadResult, err := uploader.Upload(uploadInput)
+	if nil != err {
+		return "", nil, err
+	}
+	ctx.logger.Info("CloudFormation template uploaded: ", templateUploadResult.Location)
+	stack, err := convergeStackState(templateUploadResult.Location, ctx)
+	if nil != err {
+		return "", nil, err
+	}
+	ctx.logger.Info("Stack provisioned: ", stack)
+	return templateUploadResult.Location, stack, nil
+}
+
 // Provision compiles, packages, and provisions (either via create or update) a Sparta application.
 // The serviceName is the service's logical
 // identify and is used to determine create vs update operations.  The compilation options/flags are:
@@ -571,6 +1457,9 @@ func ensureCloudFormationStack(s3Key string) workflowStep {
 // 	TAGS:         -tags lambdabinary
 // 	ENVIRONMENT:  GOOS=linux GOARCH=amd64 GO15VENDOREXPERIMENT=1
 //
+// Additional tags, linker flags, build flags and environment variables, the main
+// package path, or a prebuilt binary can be supplied via ProvisionOptions.Build.
+//
 // The compiled binary is packaged with a NodeJS proxy shim to manage AWS Lambda setup & invocation per
 // http://docs.aws.amazon.com/lambda/latest/dg/authoring-function-in-nodejs.html
 //
@@ -598,16 +1487,33 @@ func Provision(noop bool,
 	s3Bucket string,
 	templateWriter io.Writer,
 	logger *logrus.Logger) error {
+	return ProvisionEx(noop, serviceName, serviceDescription, lambdaAWSInfos, api, s3Bucket, nil, templateWriter, logger)
+}
+
+// ProvisionEx provisions the service like Provision().  The optional options
+// value defines additional stack tags, parameters, build options and
+// create/update behavior.
+func ProvisionEx(noop bool,
+	serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	s3Bucket string,
+	options *ProvisionOptions,
+	templateWriter io.Writer,
+	logger *logrus.Logger) error {
 
 	ctx := &workflowContext{
 		noop:               noop,
 		serviceName:        serviceName,
+		stackName:          serviceName,
 		serviceDescription: serviceDescription,
 		lambdaAWSInfos:     lambdaAWSInfos,
 		api:                api,
 		cloudformationResources: make(ArbitraryJSONObject, 0),
 		cloudformationOutputs:   make(ArbitraryJSONObject, 0),
 		s3Bucket:                s3Bucket,
+		options:                 options,
 		awsSession:              awsSession(logger),
 		templateWriter:          templateWriter,
 		logger:                  logger,
@@ -616,17 +1522,82 @@ func Provision(noop bool,
 	if len(lambdaAWSInfos) <= 0 {
 		return errors.New("No lambda functions provided to Sparta.Provision()")
 	}
+	return runWorkflow(ctx)
+}
+
+// ProvisionTargets provisions (either via create or update) a Sparta application
+// to each of the deployment targets.  Each target uses its own AWS session,
+// S3 bucket and stack name.  All targets are provisioned even if a target fails.
+func ProvisionTargets(noop bool,
+	serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	targets []*DeploymentTarget,
+	options *ProvisionOptions,
+	logger *logrus.Logger) error {
+
+	if len(lambdaAWSInfos) <= 0 {
+		return errors.New("No lambda functions provided to Sparta.ProvisionTargets()")
+	}
+	// The archives don't depend on the target, so build them once and
+	// upload them to every target's bucket
+	buildCtx := &workflowContext{
+		noop:           noop,
+		serviceName:    serviceName,
+		stackName:      serviceName,
+		lambdaAWSInfos: lambdaAWSInfos,
+		options:        options,
+		logger:         logger,
+	}
+	packages, err := buildPackages(buildCtx)
+	if nil != err {
+		return err
+	}
+	defer removePackageArchives(packages)
+
+	return forEachTarget(serviceName, targets, "provision", logger, func(target *DeploymentTarget, targetSession *session.Session) error {
+		if "" == target.S3Bucket {
+			return errors.New("Deployment target does not define an S3 bucket")
+		}
+		if !noop {
+			err := verifyTargetBucketRegion(target, targetSession)
+			if nil != err {
+				return err
+			}
+		}
+		ctx := &workflowContext{
+			noop:               noop,
+			serviceName:        serviceName,
+			stackName:          target.stackName(serviceName),
+			serviceDescription: serviceDescription,
+			lambdaAWSInfos:     lambdaAWSInfos,
+			api:                api,
+			cloudformationResources: make(ArbitraryJSONObject, 0),
+			cloudformationOutputs:   make(ArbitraryJSONObject, 0),
+			s3Bucket:                target.S3Bucket,
+			options:                 options,
+			prebuiltPackages:        packages,
+			awsSession:              targetSession,
+			logger:                  logger,
+		}
+		return runWorkflow(ctx)
+	})
+}
 
+// Run the provisioning workflow steps to completion, deleting the uploaded
+// ZIP archives if any step fails
+func runWorkflow(ctx *workflowContext) error {
 	for step := verifyIAMRoles; step != nil; {
 		next, err := step(ctx)
 		if err != nil {
 			ctx.logger.Error(err.Error())
-			if "" != ctx.s3LambdaZipKey {
-				ctx.logger.Info("Attempting to cleanup ZIP archive: ", ctx.s3LambdaZipKey)
+			for _, eachKey := range ctx.s3LambdaZipKeys {
+				ctx.logger.Info("Attempting to cleanup ZIP archive: ", eachKey)
 				s3Client := s3.New(ctx.awsSession)
 				params := &s3.DeleteObjectInput{
 					Bucket: aws.String(ctx.s3Bucket),
-					Key:    aws.String(ctx.s3LambdaZipKey),
+					Key:    aws.String(eachKey),
 				}
 				_, err := s3Client.DeleteObject(params)
 				if nil != err {
@@ -2,9 +2,17 @@ package sparta
 
 import (
 	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
 )
 
 func TestProvision(t *testing.T) {
@@ -53,3 +61,197 @@ func TestDecorateProvision(t *testing.T) {
 		t.Fatal(err.Error())
 	}
 }
+
+func TestDeterministicArchive(t *testing.T) {
+	entries := []archiveEntry{
+		stringArchiveEntry("index.js", "exports.main = null;"),
+		stringArchiveEntry("cfn-response.js", "module.exports = {};"),
+		stringArchiveEntry("golang-constants.json", "{}"),
+	}
+	var first bytes.Buffer
+	err := writeArchive(&first, entries)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	// Entry order must not affect the archive
+	reversed := []archiveEntry{entries[2], entries[1], entries[0]}
+	var second bytes.Buffer
+	err = writeArchive(&second, reversed)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("Archives with identical entries are not byte-identical")
+	}
+}
+
+func TestProvisionOptions(t *testing.T) {
+	options := &ProvisionOptions{
+		Tags: map[string]string{
+			"Owner":      "platform",
+			"CostCenter": "1234",
+		},
+		Parameters: map[string]string{
+			"Stage": "test",
+		},
+	}
+	tags := stackTags(options)
+	if len(tags) != 2 || *tags[0].Key != "CostCenter" || *tags[1].Key != "Owner" {
+		t.Errorf("Expected stack tags sorted by key, got: %v", tags)
+	}
+	parameters := stackParameters(options)
+	if len(parameters) != 1 || *parameters[0].ParameterValue != "test" {
+		t.Errorf("Unexpected stack parameters: %v", parameters)
+	}
+	if _, exists := templateParameters(options)["Stage"]; !exists {
+		t.Errorf("Template parameter not declared: Stage")
+	}
+	if nil != stackTags(&ProvisionOptions{}) {
+		t.Errorf("Expected no stack tags for empty options")
+	}
+	if nil != stackNotificationARNs(&ProvisionOptions{}) {
+		t.Errorf("Expected nil notification ARNs for empty options")
+	}
+	notificationARNs := stackNotificationARNs(&ProvisionOptions{
+		NotificationARNs: []string{"arn:aws:sns:us-east-1:123412341234:StackEvents"},
+	})
+	if len(notificationARNs) != 1 {
+		t.Errorf("Unexpected notification ARNs: %v", notificationARNs)
+	}
+}
+
+// Returns the testLambdaData functions with IAMRoleDefinition roles
+func testIAMRoleDefinitionLambdaData() []*LambdaAWSInfo {
+	lambdas := testLambdaData()
+	for _, eachLambda := range lambdas {
+		eachLambda.RoleName = ""
+		eachLambda.RoleDefinition = &IAMRoleDefinition{
+			Privileges: []IAMRolePrivilege{
+				{
+					Actions:  []string{"s3:GetObject"},
+					Resource: "arn:aws:s3:::SampleBucket/*",
+				},
+			},
+		}
+	}
+	return lambdas
+}
+
+// Rewrite the testdata golden files with: go test -run TestDeterministicProvision -update
+var updateGolden = flag.Bool("update", false, "Update the testdata golden files")
+
+// Code archive keys include the hash of the binary, which depends on the
+// toolchain that built it
+var reCodeArchiveHash = regexp.MustCompile("-code-[0-9a-f]{40}\\.zip")
+
+// Returns the indented template JSON, with the code archive hashes replaced
+// s.t. it can be compared to a golden file
+func goldenTemplate(t *testing.T, templateOutput string) []byte {
+	// The template is written as a JSON string
+	var templateBody string
+	err := json.Unmarshal([]byte(templateOutput), &templateBody)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	var template interface{}
+	err = json.Unmarshal([]byte(templateBody), &template)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	indented, err := json.MarshalIndent(template, "", "  ")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	indented = append(indented, '\n')
+	return reCodeArchiveHash.ReplaceAll(indented, []byte("-code-HASH.zip"))
+}
+
+func TestDeterministicProvision(t *testing.T) {
+	logger, err := NewLogger("info")
+	var templates []string
+	for index := 0; index < 2; index++ {
+		var templateWriter bytes.Buffer
+		err = Provision(true, "SampleProvision", "", testIAMRoleDefinitionLambdaData(), nil, "S3Bucket", &templateWriter, logger)
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+		templates = append(templates, templateWriter.String())
+	}
+	if templates[0] != templates[1] {
+		t.Errorf("Provision(noop) templates differ:\n%s\n%s", templates[0], templates[1])
+	}
+
+	goldenPath := filepath.Join("testdata", "TestDeterministicProvision.golden.json")
+	actual := goldenTemplate(t, templates[0])
+	if *updateGolden {
+		err = ioutil.WriteFile(goldenPath, actual, 0644)
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+	}
+	expected, err := ioutil.ReadFile(goldenPath)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Provision(noop) template differs from %s. Run `go test -run TestDeterministicProvision -update` to accept the changes:\n%s", goldenPath, actual)
+	}
+}
+
+// Returns a canned UpdateStack error and stack
+type fakeStackUpdater struct {
+	updateErr error
+	stack     *cloudformation.Stack
+}
+
+func (fake *fakeStackUpdater) UpdateStack(params *cloudformation.UpdateStackInput) (*cloudformation.UpdateStackOutput, error) {
+	if nil != fake.updateErr {
+		return nil, fake.updateErr
+	}
+	return &cloudformation.UpdateStackOutput{StackId: fake.stack.StackId}, nil
+}
+
+func (fake *fakeStackUpdater) DescribeStacks(params *cloudformation.DescribeStacksInput) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []*cloudformation.Stack{fake.stack},
+	}, nil
+}
+
+func TestUpdateStackNoUpdates(t *testing.T) {
+	logger, _ := NewLogger("info")
+	stack := &cloudformation.Stack{
+		StackId:     aws.String("arn:aws:cloudformation:us-west-2:123412341234:stack/SampleProvision/1"),
+		StackName:   aws.String("SampleProvision"),
+		StackStatus: aws.String(cloudformation.StackStatusUpdateComplete),
+	}
+	input := &cloudformation.UpdateStackInput{
+		StackName: aws.String("SampleProvision"),
+	}
+
+	// Unchanged templates are not an error
+	fake := &fakeStackUpdater{
+		updateErr: awserr.New("ValidationError", "No updates are to be performed.", nil),
+		stack:     stack,
+	}
+	stackID, current, err := updateStack(fake, input, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if "" != stackID || current != stack {
+		t.Errorf("Expected the current stack for a no-op update: %s, %#v", stackID, current)
+	}
+
+	// Other validation errors fail the update
+	fake.updateErr = awserr.New("ValidationError", "Template format error", nil)
+	_, _, err = updateStack(fake, input, logger)
+	if nil == err {
+		t.Error("Expected template validation error")
+	}
+
+	// Updates return the stack ID to wait for
+	fake.updateErr = nil
+	stackID, current, err = updateStack(fake, input, logger)
+	if nil != err || stackID != *stack.StackId || nil != current {
+		t.Errorf("Unexpected update result: %s, %#v, %v", stackID, current, err)
+	}
+}
@@ -11,6 +11,16 @@ import (
 
 const salt = "213EA743-A98F-499D-8FEF-B87015FE13E7"
 
+// DefaultArtifactRetentionCount is the number of recorded deployments per
+// service whose code archives and templates are kept in the S3 bucket after
+// a successful provision.  Artifacts referenced by the current stack are
+// always kept.
+const DefaultArtifactRetentionCount = 5
+
+// DefaultStackTimeoutInMinutes is the stack creation timeout used when
+// ProvisionOptions.TimeoutInMinutes is not defined.
+const DefaultStackTimeoutInMinutes = 5
+
 // PushSourceConfigurationActions map stores common IAM Policy Actions for Lambda
 // push-source configuration management.
 // The configuration is handled by CustomResources inserted into the generated
@@ -31,6 +41,21 @@ var PushSourceConfigurationActions = map[string][]string{
 		"lambda:GetPolicy"},
 }
 
+// Returns a copy of the CommonIAMStatements entries for the given service
+// s.t. callers can specialize the statements without modifying the shared
+// definitions
+func commonIAMStatements(serviceName string) []ArbitraryJSONObject {
+	var statements []ArbitraryJSONObject
+	for _, eachStatement := range CommonIAMStatements[serviceName] {
+		statement := make(ArbitraryJSONObject, len(eachStatement))
+		for eachKey, eachValue := range eachStatement {
+			statement[eachKey] = eachValue
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
 func awsPrincipalToService(awsPrincipalName string) string {
 	return strings.ToUpper(strings.SplitN(awsPrincipalName, ".", 2)[0])
 }
@@ -136,7 +161,7 @@ func ensureIAMRoleResource(awsPrincipalName string, sourceArn string, resources
 	}).Debug("Inserting IAM Role")
 
 	// Provision a new one and add it...
-	statements := CommonIAMStatements["core"]
+	statements := commonIAMStatements("core")
 	statements = append(statements, ArbitraryJSONObject{
 		"Effect":   "Allow",
 		"Action":   principalActions,
@@ -148,7 +173,7 @@ func ensureIAMRoleResource(awsPrincipalName string, sourceArn string, resources
 			"AssumeRolePolicyDocument": AssumePolicyDocument,
 			"Policies": []ArbitraryJSONObject{
 				{
-					"PolicyName": fmt.Sprintf("Configurator%s", CloudFormationResourceName(awsPrincipalName)),
+					"PolicyName": CloudFormationResourceName("ConfiguratorPolicy", awsPrincipalName),
 					"PolicyDocument": ArbitraryJSONObject{
 						"Version":   "2012-10-17",
 						"Statement": statements,
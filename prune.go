@@ -0,0 +1,270 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Maximum number of keys per DeleteObjects request
+const maxDeleteObjectsCount = 1000
+
+// Returns the RE that matches the S3 keynames of the code archives and
+// templates that Sparta uploads on behalf of the given stack.  Keys are
+// prefixed by the stack name s.t. deployment targets that share a bucket
+// don't match each other's artifacts.
+func serviceArtifactRegexp(stackName string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf("^%s-(code-[0-9a-f]{40}\\.zip|[0-9a-f]{40}-cf\\.json)$",
+		regexp.QuoteMeta(sanitizedName(stackName))))
+}
+
+type s3ObjectsByAge []*s3.Object
+
+func (objects s3ObjectsByAge) Len() int      { return len(objects) }
+func (objects s3ObjectsByAge) Swap(i, j int) { objects[i], objects[j] = objects[j], objects[i] }
+func (objects s3ObjectsByAge) Less(i, j int) bool {
+	return objects[i].LastModified.After(*objects[j].LastModified)
+}
+
+// Returns the stack's artifacts in the bucket, grouped into code archives
+// and templates, newest first
+func serviceArtifacts(stackName string, s3Bucket string, s3Client *s3.S3) (s3ObjectsByAge, s3ObjectsByAge, error) {
+	reArtifact := serviceArtifactRegexp(stackName)
+	var codeArchives s3ObjectsByAge
+	var templates s3ObjectsByAge
+
+	params := &s3.ListObjectsInput{
+		Bucket: aws.String(s3Bucket),
+		Prefix: aws.String(fmt.Sprintf("%s-", sanitizedName(stackName))),
+	}
+	err := s3Client.ListObjectsPages(params, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, eachObject := range page.Contents {
+			if !reArtifact.MatchString(*eachObject.Key) {
+				continue
+			}
+			if strings.HasSuffix(*eachObject.Key, ".zip") {
+				codeArchives = append(codeArchives, eachObject)
+			} else {
+				templates = append(templates, eachObject)
+			}
+		}
+		return true
+	})
+	if nil != err {
+		return nil, nil, err
+	}
+	sort.Sort(codeArchives)
+	sort.Sort(templates)
+	return codeArchives, templates, nil
+}
+
+// Returns the body of the template currently applied to the stack, or an empty
+// string if the stack doesn't exist
+func currentStackTemplate(stackName string, awsSession *session.Session, logger *logrus.Logger) (string, error) {
+	awsCloudFormation := cloudformation.New(awsSession)
+	exists, err := stackExists(stackName, awsCloudFormation, logger)
+	if nil != err || !exists {
+		return "", err
+	}
+	getTemplateOutput, err := awsCloudFormation.GetTemplate(&cloudformation.GetTemplateInput{
+		StackName: aws.String(stackName),
+	})
+	if nil != err {
+		return "", err
+	}
+	return *getTemplateOutput.TemplateBody, nil
+}
+
+// Returns the body of the S3 object, or nil if it doesn't exist
+func s3ObjectBody(s3Bucket string, key string, s3Client *s3.S3) ([]byte, error) {
+	getObjectOutput, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s3Bucket),
+		Key:    aws.String(key),
+	})
+	if nil != err {
+		if awsErr, ok := err.(awserr.RequestFailure); ok && awsErr.StatusCode() == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer getObjectOutput.Body.Close()
+	return ioutil.ReadAll(getObjectOutput.Body)
+}
+
+// Returns the keys of the artifacts referenced by the stack's current
+// template: the template itself, its nested stack templates and the code
+// archives that any of them reference
+func stackArtifactKeys(stackName string,
+	s3Bucket string,
+	artifacts s3ObjectsByAge,
+	s3Client *s3.S3,
+	awsSession *session.Session,
+	logger *logrus.Logger) (map[string]bool, error) {
+
+	stackKeys := make(map[string]bool, 0)
+	stackTemplate, err := currentStackTemplate(stackName, awsSession, logger)
+	if nil != err || "" == stackTemplate {
+		return stackKeys, err
+	}
+	// The current template is stored under its content hash
+	stackKeys[templateS3Key(stackName, []byte(stackTemplate))] = true
+	templateBodies := []string{stackTemplate}
+	var template map[string]interface{}
+	err = json.Unmarshal([]byte(stackTemplate), &template)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse stack template: %s", err.Error())
+	}
+	for _, eachKey := range nestedTemplateKeys(template) {
+		stackKeys[eachKey] = true
+		nestedBody, err := s3ObjectBody(s3Bucket, eachKey, s3Client)
+		if nil != err {
+			return nil, err
+		}
+		templateBodies = append(templateBodies, string(nestedBody))
+	}
+	// Code archive keys are literal template values
+	for _, eachObject := range artifacts {
+		for _, eachBody := range templateBodies {
+			if strings.Contains(eachBody, *eachObject.Key) {
+				stackKeys[*eachObject.Key] = true
+			}
+		}
+	}
+	return stackKeys, nil
+}
+
+// Returns the keys of the artifacts that aren't retained, sorted.  The
+// artifacts of the newest keepCount deployments in the history are retained,
+// as are the retainedKeys.  If keepCount is positive, artifacts uploaded
+// after the newest deployment (eg, by a provision in progress) are also
+// retained, so nothing is expired if there is no deployment history.
+func expiredArtifactKeys(artifacts s3ObjectsByAge,
+	history []*deploymentRecord,
+	keepCount int,
+	retainedKeys map[string]bool) []string {
+
+	deploymentKeys := make(map[string]bool, 0)
+	for index := len(history) - 1; index >= 0 && index >= len(history)-keepCount; index-- {
+		for _, eachKey := range history[index].artifactKeys() {
+			deploymentKeys[eachKey] = true
+		}
+	}
+	var newestDeployment time.Time
+	if len(history) > 0 {
+		newestDeployment = history[len(history)-1].Timestamp
+	}
+	var expiredKeys []string
+	for _, eachObject := range artifacts {
+		key := *eachObject.Key
+		if deploymentKeys[key] || retainedKeys[key] {
+			continue
+		}
+		if keepCount > 0 && aws.TimeValue(eachObject.LastModified).After(newestDeployment) {
+			continue
+		}
+		expiredKeys = append(expiredKeys, key)
+	}
+	sort.Strings(expiredKeys)
+	return expiredKeys
+}
+
+// Delete the code archives and templates for the stack that don't belong to
+// one of the newest keepCount deployments.  Artifacts referenced by the
+// current stackName template or its nested stack templates are never
+// deleted unless ignoreStack is true.
+func pruneArtifacts(stackName string,
+	s3Bucket string,
+	keepCount int,
+	ignoreStack bool,
+	awsSession *session.Session,
+	logger *logrus.Logger) error {
+
+	if keepCount < 0 {
+		return fmt.Errorf("Invalid artifact retention count: %d", keepCount)
+	}
+	s3Client := s3.New(awsSession)
+	codeArchives, templates, err := serviceArtifacts(stackName, s3Bucket, s3Client)
+	if nil != err {
+		return err
+	}
+	artifacts := append(append(s3ObjectsByAge{}, codeArchives...), templates...)
+	history, err := readDeploymentHistory(stackName, s3Bucket, awsSession)
+	if nil != err {
+		return err
+	}
+	if keepCount > 0 && len(history) <= 0 {
+		logger.Warn("No deployment history found. Service artifacts are retained.")
+	}
+	stackKeys := make(map[string]bool, 0)
+	if !ignoreStack {
+		stackKeys, err = stackArtifactKeys(stackName, s3Bucket, artifacts, s3Client, awsSession, logger)
+		if nil != err {
+			return err
+		}
+	}
+	expiredKeys := expiredArtifactKeys(artifacts, history, keepCount, stackKeys)
+
+	logger.WithFields(logrus.Fields{
+		"Bucket":        s3Bucket,
+		"CodeArchives":  len(codeArchives),
+		"Templates":     len(templates),
+		"Deployments":   len(history),
+		"ExpiredCount":  len(expiredKeys),
+		"RetentionSize": keepCount,
+	}).Info("Pruning service artifacts")
+
+	for len(expiredKeys) > 0 {
+		batchSize := len(expiredKeys)
+		if batchSize > maxDeleteObjectsCount {
+			batchSize = maxDeleteObjectsCount
+		}
+		var identifiers []*s3.ObjectIdentifier
+		for _, eachKey := range expiredKeys[0:batchSize] {
+			logger.Debug("Deleting artifact: ", eachKey)
+			identifiers = append(identifiers, &s3.ObjectIdentifier{
+				Key: aws.String(eachKey),
+			})
+		}
+		deleteResult, err := s3Client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(s3Bucket),
+			Delete: &s3.Delete{
+				Objects: identifiers,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if nil != err {
+			return err
+		}
+		for _, eachError := range deleteResult.Errors {
+			logger.WithFields(logrus.Fields{
+				"Key":   *eachError.Key,
+				"Error": *eachError.Message,
+			}).Warn("Failed to delete artifact")
+		}
+		expiredKeys = expiredKeys[batchSize:]
+	}
+	return nil
+}
+
+// Prune deletes the code archives and CloudFormation templates that were
+// uploaded to the S3 bucket on behalf of serviceName, except those of the
+// newest keepCount recorded deployments.  Artifacts referenced by the
+// currently provisioned stack, including its nested stacks, are always
+// retained.
+func Prune(serviceName string, s3Bucket string, keepCount int, logger *logrus.Logger) error {
+	return pruneArtifacts(serviceName, s3Bucket, keepCount, false, awsSession(logger), logger)
+}
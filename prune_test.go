@@ -0,0 +1,93 @@
+package sparta
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestServiceArtifactRegexp(t *testing.T) {
+	reArtifact := serviceArtifactRegexp("Sample-Service")
+	matches := []string{
+		"Sample_Service-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip",
+		"Sample_Service-e08b554042b716cbf25e50c5d109d89c0617cf61-cf.json",
+	}
+	for _, eachKey := range matches {
+		if !reArtifact.MatchString(eachKey) {
+			t.Errorf("Expected service artifact match: %s", eachKey)
+		}
+	}
+	nonMatches := []string{
+		"Sample_Service_Other-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip",
+		// Deployment target stack that shares the bucket
+		"Sample_Service_east-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip",
+		"Sample_Service-code-e08b554042b716cbf25e50c5d109d89c0617cf61.zip.bak",
+		"Sample_Service-notes.json",
+	}
+	for _, eachKey := range nonMatches {
+		if reArtifact.MatchString(eachKey) {
+			t.Errorf("Unexpected service artifact match: %s", eachKey)
+		}
+	}
+	targetKey := templateS3Key("Sample-Service-east", []byte("{}"))
+	if !serviceArtifactRegexp("Sample-Service-east").MatchString(targetKey) {
+		t.Errorf("Expected deployment target artifact match: %s", targetKey)
+	}
+}
+
+func TestExpiredArtifactKeys(t *testing.T) {
+	deployedAt := func(minute int) time.Time {
+		return time.Date(2016, time.January, 1, 0, minute, 0, 0, time.UTC)
+	}
+	artifact := func(key string, minute int) *s3.Object {
+		return &s3.Object{
+			Key:          aws.String(key),
+			LastModified: aws.Time(deployedAt(minute)),
+		}
+	}
+	// Split package archives that haven't changed since the first deployment
+	// are shared by every deployment
+	var history []*deploymentRecord
+	var artifacts s3ObjectsByAge
+	for index := 0; index < 3; index++ {
+		templateKey := fmt.Sprintf("Sample-template-%d.json", index)
+		nestedKey := fmt.Sprintf("Sample-nested-%d.json", index)
+		codeKey := fmt.Sprintf("Sample-code-%d.zip", index)
+		history = append(history, &deploymentRecord{
+			Timestamp:          deployedAt(10 * index),
+			TemplateKey:        templateKey,
+			NestedTemplateKeys: []string{nestedKey},
+			CodeArchiveKey:     codeKey,
+			CodeArchiveKeys:    []string{codeKey, "Sample-code-shared-a.zip", "Sample-code-shared-b.zip"},
+		})
+		artifacts = append(artifacts,
+			artifact(templateKey, 10*index),
+			artifact(nestedKey, 10*index),
+			artifact(codeKey, 10*index))
+	}
+	artifacts = append(artifacts,
+		artifact("Sample-code-shared-a.zip", 0),
+		artifact("Sample-code-shared-b.zip", 0),
+		// Uploaded by a provision in progress
+		artifact("Sample-code-pending.zip", 25),
+		// Referenced by the current stack
+		artifact("Sample-code-stack.zip", 1))
+
+	expired := expiredArtifactKeys(artifacts, history, 2, map[string]bool{"Sample-code-stack.zip": true})
+	expected := []string{"Sample-code-0.zip", "Sample-nested-0.json", "Sample-template-0.json"}
+	if !reflect.DeepEqual(expired, expected) {
+		t.Errorf("Unexpected expired artifacts: %v", expired)
+	}
+	// Without a deployment history, nothing is expired
+	if expired := expiredArtifactKeys(artifacts, nil, 2, nil); len(expired) != 0 {
+		t.Errorf("Unexpected expired artifacts without history: %v", expired)
+	}
+	// Deleting the service expires everything
+	if expired := expiredArtifactKeys(artifacts, history, 0, nil); len(expired) != len(artifacts) {
+		t.Errorf("Expected all artifacts to expire: %v", expired)
+	}
+}
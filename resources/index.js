@@ -3,6 +3,7 @@ var fs = require('fs');
 var http = require('http');
 var path = require('path');
 var child_process = require('child_process');
+var net = require('net');
 var GOLANG_CONSTANTS = require('./golang-constants.json');
 
 //TODO: See if https://forums.aws.amazon.com/message.jspa?messageID=633802
@@ -14,15 +15,73 @@ var SPARTA_BINARY_NAME = 'Sparta.lambda.amd64';
 var SPARTA_BINARY_PATH = path.join('/tmp', SPARTA_BINARY_NAME);
 var MAXIMUM_RESPAWN_COUNT = 5;
 
+// Transport used to forward events to the golang process. One of
+// 'http', 'stdio' or 'unix'.  Overwritten by the generated content below.
+var SPARTA_TRANSPORT = 'http';
+var SPARTA_SOCKET_PATH = path.join('/tmp', util.format('Sparta-%d.sock', process.pid));
+
+// True if the golang process should publish metrics to CloudWatch and the
+// container metrics.  Overwritten by the generated content below.
+var SPARTA_METRICS = false;
+var SPARTA_CONTAINER_METRICS = false;
+
+// Package group of the golang binary.  Empty unless the service uses
+// BuildOptions.SplitPackages.  Overwritten by the generated content below.
+var SPARTA_PACKAGE_GROUP = '';
+
 var PROXIED_MODULES = ['s3', 'sns', 'apigateway'];
 
 var golangProcess = null;
 var failCount = 0;
 
-function makeRequest(path, event, context) {
+// Container instrumentation, which is reported to the golang process
+// with each request
+var invocationCount = 0;
+var unreportedRespawns = 0;
+// Durations of the phases that started the golang process.  Reported
+// with the process' first request.
+var processPhases = null;
+var unreportedPhases = null;
+
+// Framed transport state: the stream that requests are written to and
+// the pending contexts, keyed by request ID
+var frameWriter = null;
+var pendingRequests = {};
+var nextRequestID = 0;
+// ID of the frame that the golang process writes once it's ready.  Request
+// IDs start at 1.
+var HANDSHAKE_FRAME_ID = 0;
+
+function completeRequest(context, statusCode, headers, body) {
+  // TODO: Bridge the NodeJS and golang worlds by including the golang
+  // HTTP status text in the error response if appropriate.  This enables
+  // the API Gateway integration response to use standard golang StatusText regexp
+  // matches to manage HTTP status codes.
+  var responseData = {};
+  responseData.code = statusCode;
+  responseData.status = GOLANG_CONSTANTS.HTTP_STATUS_TEXT[statusCode.toString()];
+  responseData.headers = headers;
+  responseData.error = (statusCode >= 400) ? body : undefined;
+  responseData.results = responseData.error ? undefined : body;
+  try {
+    // TODO: Check content-type before parse attempt
+    if (responseData.results)
+    {
+      responseData.results = JSON.parse(responseData.results);
+    }
+  } catch (e) {
+    // NOP
+  }
+  var err = responseData.error ? new Error(JSON.stringify(responseData)) : null;
+  var resp = err ? null : responseData;
+  context.done(err, resp);
+}
+
+function makeHTTPRequest(path, event, context, container) {
   var requestBody = {
     event: event,
-    context: context
+    context: context,
+    container: container
   };
 
   var stringified = JSON.stringify(requestBody);
@@ -46,28 +105,7 @@ function makeRequest(path, event, context) {
       body += chunk;
     });
     res.on('end', function() {
-      // TODO: Bridge the NodeJS and golang worlds by including the golang
-      // HTTP status text in the error response if appropriate.  This enables
-      // the API Gateway integration response to use standard golang StatusText regexp
-      // matches to manage HTTP status codes.
-      var responseData = {};
-      responseData.code = res.statusCode;
-      responseData.status = GOLANG_CONSTANTS.HTTP_STATUS_TEXT[res.statusCode.toString()];
-      responseData.headers = res.headers;
-      responseData.error = (res.statusCode >= 400) ? body : undefined;
-      responseData.results = responseData.error ? undefined : body;
-      try {
-        // TODO: Check content-type before parse attempt
-        if (responseData.results)
-        {
-          responseData.results = JSON.parse(responseData.results);
-        }
-      } catch (e) {
-        // NOP
-      }
-      var err = responseData.error ? new Error(JSON.stringify(responseData)) : null;
-      var resp = err ? null : responseData;
-      context.done(err, resp);
+      completeRequest(context, res.statusCode, res.headers, body);
     });
   });
   req.on('error', function(e) {
@@ -77,6 +115,124 @@ function makeRequest(path, event, context) {
   req.end();
 }
 
+// Frames are a 4 byte big-endian length followed by the JSON body
+function writeFrame(stream, value) {
+  var body = new Buffer(JSON.stringify(value), 'utf-8');
+  var header = new Buffer(4);
+  header.writeUInt32BE(body.length, 0);
+  stream.write(Buffer.concat([header, body]));
+}
+
+// Returns a 'data' listener that parses response frames and completes
+// the pending request for each one.  The golang process writes a handshake
+// frame (id 0) once it's serving frames, which calls onHandshake.
+function createFrameReader(onHandshake) {
+  var buffered = new Buffer(0);
+  return function(chunk) {
+    buffered = Buffer.concat([buffered, chunk]);
+    while (buffered.length >= 4) {
+      var frameLength = buffered.readUInt32BE(0);
+      if (buffered.length < 4 + frameLength) {
+        break;
+      }
+      var frameBody = buffered.slice(4, 4 + frameLength).toString('utf-8');
+      buffered = buffered.slice(4 + frameLength);
+      try {
+        var response = JSON.parse(frameBody);
+        if (response.id === HANDSHAKE_FRAME_ID) {
+          onHandshake();
+          continue;
+        }
+        var context = pendingRequests[response.id];
+        delete pendingRequests[response.id];
+        if (context) {
+          completeRequest(context, response.code, response.headers, response.body);
+        }
+      } catch (e) {
+        log('Failed to parse response frame: ' + e.toString());
+      }
+    }
+  };
+}
+
+// Fail the pending requests if the golang process exits
+function failPendingRequests(err) {
+  var pending = pendingRequests;
+  pendingRequests = {};
+  Object.keys(pending).forEach(function(eachKey) {
+    pending[eachKey].done(err, null);
+  });
+}
+
+function makeFrameRequest(path, event, context, container) {
+  if (!frameWriter) {
+    context.done(new Error('Sparta transport unavailable'), null);
+    return;
+  }
+  nextRequestID += 1;
+  pendingRequests[nextRequestID] = context;
+  writeFrame(frameWriter, {
+    id: nextRequestID,
+    path: path,
+    event: event,
+    context: context,
+    container: container
+  });
+}
+
+// Returns the container state for the next request
+function nextContainerStatus(context) {
+  invocationCount += 1;
+  var status = {
+    coldStart: (invocationCount === 1),
+    invocationCount: invocationCount,
+    respawns: unreportedRespawns,
+    metrics: SPARTA_CONTAINER_METRICS
+  };
+  unreportedRespawns = 0;
+  if (unreportedPhases) {
+    status.phases = unreportedPhases;
+    unreportedPhases = null;
+    instrumentFirstRequest(context, status);
+  }
+  return status;
+}
+
+// Log the cold start phases once the golang process' first request
+// completes
+function instrumentFirstRequest(context, status) {
+  var startTime = Date.now();
+  var phases = processPhases;
+  var done = context.done;
+  context.done = function(err, resp) {
+    var record = {
+      msg: 'Sparta cold start',
+      coldStart: status.coldStart,
+      invocationCount: status.invocationCount,
+      respawnCount: failCount,
+      firstRequestMs: Date.now() - startTime
+    };
+    var totalMs = record.firstRequestMs;
+    Object.keys(phases).forEach(function (eachPhase) {
+      record[eachPhase + 'Ms'] = phases[eachPhase];
+      totalMs += phases[eachPhase];
+    });
+    record.totalMs = totalMs;
+    log(record);
+    context.done = done;
+    done.call(context, err, resp);
+  };
+}
+
+function makeRequest(path, event, context) {
+  var container = nextContainerStatus(context);
+  if (SPARTA_TRANSPORT === 'http') {
+    makeHTTPRequest(path, event, context, container);
+  } else {
+    makeFrameRequest(path, event, context, container);
+  }
+}
+
 var log = function(obj_or_string)
 {
   if (typeof(obj_or_string) !== 'object')
@@ -97,6 +253,42 @@ var log = function(obj_or_string)
   }
 };
 
+// Returns a 'data' listener that logs each complete line of golang process
+// output.  JSON object lines (eg, logrus JSONFormatter entries) are passed
+// through verbatim so that their fields are queryable in CloudWatch Logs.
+// Other lines are wrapped by log().
+var createLineLogger = function()
+{
+  var partialLine = '';
+  return function(buf) {
+    var lines = (partialLine + buf.toString('utf-8')).split('\n');
+    partialLine = lines.pop();
+    lines.forEach(function (eachLine) {
+      if (eachLine.length <= 0)
+      {
+        return;
+      }
+      var isJSONObject = false;
+      if (eachLine.charAt(0) === '{')
+      {
+        try {
+          isJSONObject = (typeof(JSON.parse(eachLine)) === 'object');
+        } catch (e) {
+          // NOP
+        }
+      }
+      if (isJSONObject)
+      {
+        console.log(eachLine);
+      }
+      else
+      {
+        log(eachLine);
+      }
+    });
+  };
+};
+
 // Move the file to /tmp to temporarily work around
 // https://forums.aws.amazon.com/message.jspa?messageID=583910
 var ensureGoLangBinary = function(callback)
@@ -133,24 +325,66 @@ var createForwarder = function(path) {
   var forwardToGolangProcess = function(event, context)
   {
     if (!golangProcess) {
+      var binaryStartTime = Date.now();
       ensureGoLangBinary(function() {
-        golangProcess = child_process.spawn(SPARTA_BINARY_PATH, ['execute', '--signal', process.pid], {});
+        var spawnStartTime = Date.now();
+        var args = ['execute', '--signal', process.pid, '--transport', SPARTA_TRANSPORT];
+        // Forward the Lambda Environment Variables, including the
+        // SPARTA_CONFIG values, to the golang process
+        var spawnOptions = {
+          env: process.env
+        };
+        if (SPARTA_METRICS) {
+          args.push('--metrics');
+        }
+        if (SPARTA_PACKAGE_GROUP) {
+          args.push('--packageGroup', SPARTA_PACKAGE_GROUP);
+        }
+        if (SPARTA_TRANSPORT === 'unix') {
+          args.push('--socket', SPARTA_SOCKET_PATH);
+        } else if (SPARTA_TRANSPORT === 'stdio') {
+          // Requests are written to stdin and responses are read from fd 3
+          spawnOptions.stdio = ['pipe', 'pipe', 'pipe', 'pipe'];
+        }
+        golangProcess = child_process.spawn(SPARTA_BINARY_PATH, args, spawnOptions);
+        var readyStartTime = Date.now();
+        // Record the phase durations once the golang process is ready
+        var processReady = function() {
+          processPhases = {
+            binary: spawnStartTime - binaryStartTime,
+            spawn: readyStartTime - spawnStartTime,
+            ready: Date.now() - readyStartTime
+          };
+          unreportedPhases = processPhases;
+          log({
+            msg: 'Sparta golang process ready',
+            binaryMs: processPhases.binary,
+            spawnMs: processPhases.spawn,
+            readyMs: processPhases.ready,
+            respawnCount: failCount
+          });
+        };
 
-        golangProcess.stdout.on('data', function(buf) {
-          log(buf.toString('utf-8'));
-        });
-        golangProcess.stderr.on('data', function(buf) {
-          log(buf.toString('utf-8'));
-        });
+        golangProcess.stdout.on('data', createLineLogger());
+        golangProcess.stderr.on('data', createLineLogger());
 
         var terminationHandler = function(eventName) {
           return function(value) {
             console.error(util.format('Sparta %s: %s\n', eventName.toUpperCase(), JSON.stringify(value)));
             failCount += 1;
+            unreportedRespawns += 1;
+            log({
+              msg: 'Sparta golang process respawn',
+              reason: eventName,
+              respawnCount: failCount,
+              maximumRespawnCount: MAXIMUM_RESPAWN_COUNT
+            });
             if (failCount > MAXIMUM_RESPAWN_COUNT) {
               process.exit(1);
             }
             golangProcess = null;
+            frameWriter = null;
+            failPendingRequests(new Error(util.format('Sparta %s', eventName)));
             forwardToGolangProcess(null, null);
           };
         };
@@ -161,9 +395,34 @@ var createForwarder = function(path) {
             golangProcess.kill();
           }
         });
+        if (SPARTA_TRANSPORT === 'stdio') {
+          // Pipes buffer the frames, so there's no need to wait for the
+          // golang process to signal that it's ready.  The process is ready
+          // once its handshake frame arrives.
+          frameWriter = golangProcess.stdin;
+          golangProcess.stdio[3].on('data', createFrameReader(processReady));
+          forwardToGolangProcess(event, context);
+          return;
+        }
         var golangProcessReadyHandler = function() {
           process.removeListener('SIGUSR2', golangProcessReadyHandler);
-          forwardToGolangProcess(event, context);
+          if (SPARTA_TRANSPORT === 'unix') {
+            // The process is ready once the connection's handshake frame
+            // arrives
+            var socket = net.connect({path: SPARTA_SOCKET_PATH}, function() {
+              frameWriter = socket;
+              forwardToGolangProcess(event, context);
+            });
+            socket.on('data', createFrameReader(processReady));
+            socket.on('error', function(e) {
+              log('Socket error: ' + e.toString());
+              frameWriter = null;
+              failPendingRequests(e);
+            });
+          } else {
+            processReady();
+            forwardToGolangProcess(event, context);
+          }
         };
         process.on('SIGUSR2', golangProcessReadyHandler);
       });
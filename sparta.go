@@ -113,7 +113,8 @@ var CommonIAMStatements = map[string][]ArbitraryJSONObject{
 		},
 		ArbitraryJSONObject{
 			"Effect": "Allow",
-			"Action": []string{"cloudformation:DescribeStacks"},
+			"Action": []string{"cloudformation:DescribeStacks",
+				"cloudformation:ListStackResources"},
 			"Resource": ArbitraryJSONObject{
 				"Fn::Join": []interface{}{"", cfArn},
 			},
@@ -155,6 +156,15 @@ type LambdaContext struct {
 	MemoryLimitInMB    string `json:"memoryLimitInMB"`
 	FunctionVersion    string `json:"functionVersion"`
 	InvokedFunctionARN string `json:"invokedFunctionArn"`
+	// Configuration values defined by LambdaAWSInfo.Config and
+	// ProvisionOptions.Config
+	Config LambdaConfig `json:"-"`
+	// Metrics recorder for this invocation.  Values are published when the
+	// handler returns if ProvisionOptions.Metrics is enabled or a MetricsSink
+	// was set.  Otherwise they're discarded.
+	Metrics *Metrics `json:"-"`
+	// True if this is the first invocation handled by the Lambda container
+	ColdStart bool `json:"-"`
 }
 
 // Package private type to deserialize NodeJS proxied
@@ -162,6 +172,25 @@ type LambdaContext struct {
 type lambdaRequest struct {
 	Event   json.RawMessage `json:"event"`
 	Context LambdaContext   `json:"context"`
+	// Container state reported by the NodeJS proxy
+	Container *containerStatus `json:"container,omitempty"`
+}
+
+// Package private type to deserialize the NodeJS proxy's container
+// instrumentation
+type containerStatus struct {
+	// True for the first invocation handled by the container
+	ColdStart bool `json:"coldStart"`
+	// Number of invocations handled by the container, including this one
+	InvocationCount int64 `json:"invocationCount"`
+	// Number of golang process respawns since the previous invocation
+	Respawns int64 `json:"respawns"`
+	// Durations (milliseconds) of the binary, spawn and ready phases that
+	// started the golang process.  Only defined for the process' first
+	// invocation.
+	Phases map[string]float64 `json:"phases,omitempty"`
+	// True if ProvisionOptions.ContainerMetrics is enabled
+	Metrics bool `json:"metrics"`
 }
 
 // LambdaFunction is the golang function signature required to support AWS Lambda execution.
@@ -173,7 +202,9 @@ type lambdaRequest struct {
 // 	<200 || >= 300  : Failure
 //
 // Content written to the ResponseWriter will be used as the
-// response/Error value provided to AWS Lambda.
+// response/Error value provided to AWS Lambda.  Entries written to the
+// logger include the request's AWSRequestID, FunctionName and
+// FunctionVersion fields.
 type LambdaFunction func(*json.RawMessage, *LambdaContext, http.ResponseWriter, *logrus.Logger)
 
 // LambdaFunctionOptions defines additional AWS Lambda execution params.  See the
@@ -189,6 +220,193 @@ type LambdaFunctionOptions struct {
 	Timeout int64
 }
 
+// ProvisionOptions defines additional CloudFormation stack params applied
+// when a service is provisioned.  See the AWS CloudFormation CreateStack
+// (http://docs.aws.amazon.com/AWSCloudFormation/latest/APIReference/API_CreateStack.html)
+// docs for more information.  A nil *ProvisionOptions value is equivalent
+// to the zero value.
+type ProvisionOptions struct {
+	// Stack tags.  CloudFormation propagates stack tags to all supported
+	// resources in the stack, which makes them suitable for cost allocation.
+	Tags map[string]string
+	// Template parameters.  Each key is declared as a String parameter in
+	// the template and can be referenced by TemplateDecorators via
+	// {"Ref": "<key>"}.  Values are supplied at create/update time.
+	Parameters map[string]string
+	// Stack creation timeout (minutes).  Defaults to DefaultStackTimeoutInMinutes.
+	TimeoutInMinutes int64
+	// Action to take if stack creation fails.  One of
+	// cloudformation.OnFailure{Delete,Rollback,DoNothing}.  Defaults to
+	// DELETE.
+	OnFailure string
+	// SNS topic ARNs that receive stack events.  If empty, the notification
+	// topics of an existing stack are unchanged.
+	NotificationARNs []string
+	// Stack termination protection (see aws.Bool).  If defined, the value is
+	// applied to existing stacks on update.  If nil, new stacks are
+	// unprotected and the protection of existing stacks is unchanged.
+	EnableTerminationProtection *bool
+	// Service-wide configuration delivered to every Lambda function.  See
+	// LambdaAWSInfo.Config.
+	Config map[string]interface{}
+	// Transport used by the NodeJS proxy to forward events to the golang
+	// process.  One of TransportHTTP, TransportStdio or TransportUnixSocket.
+	// Defaults to TransportHTTP.
+	Transport string
+	// Provision a CloudWatch dashboard that displays the invocation, error,
+	// throttle and duration metrics of each Lambda function
+	Dashboard bool
+	// Optional user functions called during provisioning
+	Hooks *WorkflowHooks
+	// Optional go build options
+	Build *BuildOptions
+	// Optional files to include in the Lambda ZIP archive
+	Assets *AssetOptions
+	// Publish the LambdaContext.Metrics values and the automatic Invocations,
+	// Errors, Duration and ColdStarts metrics to CloudWatch.  Each invocation
+	// makes a PutMetricData request before it completes.
+	Metrics bool
+	// Publish the NodeJS proxy's cold start, respawn and container invocation
+	// metrics.  Requires Metrics.  The values are always logged.
+	ContainerMetrics bool
+}
+
+// BuildOptions customizes how the Lambda binary is compiled.  The binary is
+// always built with the lambdabinary tag and GOOS=linux GOARCH=amd64.
+type BuildOptions struct {
+	// Additional build tags
+	Tags []string
+	// Linker flags (eg, "-s -w -X main.version=1.0.0")
+	LDFlags string
+	// Additional go build flags (eg, "-a", "-trimpath")
+	Flags []string
+	// Path of the main package.  Defaults to "." (the working directory).
+	PackagePath string
+	// Additional build environment variables in KEY=VALUE form
+	Env []string
+	// Path to a binary previously built with the lambdabinary tag for
+	// linux/amd64.  If defined, the binary isn't compiled and the other
+	// build options are ignored.
+	PrebuiltBinary string
+	// Not supported.  Provision fails if CompressBinary is true.  The ZIP
+	// archive already deflates the binary, so storing it gzip compressed
+	// doesn't reduce the upload size.  Codecs that compress Go binaries
+	// further (xz, zstd) have no decoder in the NodeJS runtime.
+	CompressBinary bool
+	// Build a separate binary and ZIP archive for each
+	// LambdaAWSInfo.PackageGroup.  Functions without a PackageGroup are
+	// packaged individually.  Each binary is compiled with the additional
+	// `sparta_group_<name>` build tag, where <name> is the group (or the
+	// function's handler name) with invalid characters replaced by
+	// underscores.  Use the tag to exclude code that other groups require.
+	// Not supported with PrebuiltBinary.
+	SplitPackages bool
+}
+
+// WorkflowHookContext is the provisioning state passed to a WorkflowHook
+type WorkflowHookContext struct {
+	ServiceName string
+	StackName   string
+	S3Bucket    string
+	// S3 keyname of the code archive.  If BuildOptions.SplitPackages is
+	// true, PostBuild hooks are called for each archive and the other hooks
+	// receive the keyname of the first archive.
+	CodeArchiveKey string
+	// Local path of the code archive.  Only defined for PostBuild hooks.
+	ArchivePath string
+	// CloudFormation template.  Not defined for PostBuild hooks.
+	Template []byte
+	// Stack outputs.  Only defined for PostConverge hooks.
+	Outputs    map[string]string
+	AWSSession *session.Session
+	Logger     *logrus.Logger
+}
+
+// WorkflowHook is a user function called during provisioning.  Returning an
+// error aborts the provision.
+type WorkflowHook func(context *WorkflowHookContext) error
+
+// WorkflowHooks defines the user functions called during provisioning.  Hooks
+// are called in order.  If a hook fails before the stack is updated, the
+// uploaded code archive is deleted.  Hooks are not called for noop provisions
+// or by Validate().
+type WorkflowHooks struct {
+	// Called after the code archive is built, before it's uploaded (eg, to
+	// scan the archive).  Also called by Package().
+	PostBuild []WorkflowHook
+	// Called before the CloudFormation template is uploaded and applied (eg,
+	// to run database migrations).  Also called by Deploy().
+	PreTemplateUpload []WorkflowHook
+	// Called after the stack converges (eg, to warm caches, smoke test the
+	// API or notify chat).  Also called by Deploy().
+	PostConverge []WorkflowHook
+}
+
+// DefaultLogsSince is the default age of the oldest log event returned by Logs
+const DefaultLogsSince = 10 * time.Minute
+
+// LogsOptions defines the log events returned by Logs
+type LogsOptions struct {
+	// Age of the oldest event.  Defaults to DefaultLogsSince.
+	Since time.Duration
+	// Optional CloudWatch Logs filter pattern
+	// (http://docs.aws.amazon.com/AmazonCloudWatch/latest/DeveloperGuide/FilterAndPatternSyntax.html)
+	Filter string
+	// Optional function name.  Only functions whose physical or logical name
+	// contains this value are included.
+	Function string
+	// Continue polling for new events until the process is terminated
+	Follow bool
+}
+
+// DefaultLambdaAliasName is the alias name used when
+// LambdaVersionOptions.AliasName is not defined.
+const DefaultLambdaAliasName = "live"
+
+// TrafficShiftStep defines the percentage of alias traffic routed to a newly
+// published Lambda version and how long that weight is held before the next
+// step is applied.
+type TrafficShiftStep struct {
+	// Percentage of traffic (0 < Percentage < 100) routed to the new version
+	Percentage float64
+	// Duration to hold the weight while monitoring the rollback alarms
+	Interval time.Duration
+}
+
+// LambdaVersionOptions enables publishing an AWS::Lambda::Version each time the
+// Lambda code or options change, and routing a named AWS::Lambda::Alias to the
+// new version.  Permissions, event source mappings and API Gateway integrations
+// target the alias rather than the function.
+type LambdaVersionOptions struct {
+	// Alias name.  Defaults to DefaultLambdaAliasName.
+	AliasName string
+	// Optional traffic shifting schedule.  If defined, Provision() initially
+	// routes the first step's percentage of the alias traffic to a newly
+	// published version and applies each subsequent step after the previous
+	// step's Interval.  The alias is routed entirely to the new version once
+	// the final step's Interval has elapsed.
+	TrafficShift []TrafficShiftStep
+	// CloudWatch alarm names monitored during the traffic shift.  If any alarm
+	// is in the ALARM state, every alias shifted by the provision is rolled
+	// back to its previous version and the provision fails.
+	RollbackAlarms []string
+}
+
+// Validate the traffic shifting schedule
+func (options *LambdaVersionOptions) validate(lambdaFnName string) error {
+	for _, eachStep := range options.TrafficShift {
+		if eachStep.Percentage <= 0 || eachStep.Percentage >= 100 {
+			return fmt.Errorf("Invalid TrafficShift percentage for %s: %f. Value must be in the range (0, 100)",
+				lambdaFnName,
+				eachStep.Percentage)
+		}
+		if eachStep.Interval < 0 {
+			return fmt.Errorf("Invalid TrafficShift interval for %s: %s", lambdaFnName, eachStep.Interval)
+		}
+	}
+	return nil
+}
+
 // TemplateDecorator if defined, allows Lambda functions to annotate the CloudFormation
 // template definition.  Both the resources and the outputs params
 // are initialized to an empty ArbitraryJSONObject and should
@@ -476,7 +694,10 @@ func (perm SNSPermission) export(targetLambdaFuncRef interface{},
 		"DependsOn": []string{subscriberResourceName},
 	}
 	// Save it
-	unsubscriberResourceName := CloudFormationResourceName(fmt.Sprintf("UnsubscriberSNS%s", targetLambdaResourceName))
+	unsubscriberResourceName := CloudFormationResourceName("UnsubscriberSNS",
+		targetLambdaResourceName,
+		perm.BasePermission.SourceAccount,
+		perm.BasePermission.SourceArn)
 	resources[unsubscriberResourceName] = customResourceUnsubscriber
 
 	return "", nil
@@ -519,7 +740,7 @@ type IAMRoleDefinition struct {
 
 // Returns an IAM::Role policy entry for this definition
 func (roleDefinition *IAMRoleDefinition) rolePolicy(eventSourceMappings []*lambda.CreateEventSourceMappingInput, logger *logrus.Logger) ArbitraryJSONObject {
-	statements := CommonIAMStatements["core"]
+	statements := commonIAMStatements("core")
 	for _, eachPrivilege := range roleDefinition.Privileges {
 		statements = append(statements, ArbitraryJSONObject{
 			"Effect":   "Allow",
@@ -535,8 +756,8 @@ func (roleDefinition *IAMRoleDefinition) rolePolicy(eventSourceMappings []*lambd
 		if len(arnParts) >= 2 {
 			awsService := arnParts[2]
 			logger.Debug("Looking up common IAM privileges for EventSource: ", awsService)
-			serviceStatements, exists := CommonIAMStatements[awsService]
-			if exists {
+			serviceStatements := commonIAMStatements(awsService)
+			if len(serviceStatements) > 0 {
 				statements = append(statements, serviceStatements...)
 				statements[len(statements)-1]["Resource"] = *eachEventSourceMapping.EventSourceArn
 			}
@@ -547,7 +768,7 @@ func (roleDefinition *IAMRoleDefinition) rolePolicy(eventSourceMappings []*lambd
 			"AssumeRolePolicyDocument": AssumePolicyDocument,
 			"Policies": []ArbitraryJSONObject{
 				{
-					"PolicyName": CloudFormationResourceName("LambdaPolicy"),
+					"PolicyName": CloudFormationResourceName("LambdaPolicy", roleDefinition.logicalName()),
 					"PolicyDocument": ArbitraryJSONObject{
 						"Version":   "2012-10-17",
 						"Statement": statements,
@@ -601,6 +822,56 @@ type LambdaAWSInfo struct {
 	// Template decorator. If defined, the decorator will be called to insert additional
 	// resources on behalf of this lambda function
 	Decorator TemplateDecorator
+	// Optional versioning options.  If defined, a Lambda version and alias are
+	// provisioned for this function.
+	Versioning *LambdaVersionOptions
+	// Optional CloudWatch alarms for this function
+	Alarms *LambdaAlarmOptions
+	// Optional configuration delivered to the function and available at
+	// runtime via LambdaContext.Config.  Values override the service-wide
+	// ProvisionOptions.Config values and may be:
+	//
+	//	string, bool, int, int64, float64: literal values
+	//	ArbitraryJSONObject: CloudFormation expressions ({"Ref": ...}, {"Fn::GetAtt": [...]})
+	//	SSMParameterValue(name): resolved from SSM Parameter Store at provision time
+	//	KMSEncryptedValue: decrypted by the function when it starts
+	//
+	// Values are delivered via Lambda Environment Variables, which are limited
+	// to 4KB in total.
+	Config map[string]interface{}
+	// Optional package group name.  If BuildOptions.SplitPackages is true,
+	// functions with the same group share a binary and ZIP archive, and each
+	// binary only dispatches to the functions in its group.  The binary is
+	// built from the same main package as every other group, so it links the
+	// code of every function that the main package references.  To exclude
+	// the other groups' code, define each group's functions in files with the
+	// `// +build !lambdabinary sparta_group_<name>` constraint, where <name>
+	// is the group name with invalid characters replaced by underscores.
+	PackageGroup string
+}
+
+// Returns the package group name.  Functions without a PackageGroup are in
+// their own group.
+func (info *LambdaAWSInfo) packageGroup() string {
+	if "" != info.PackageGroup {
+		return info.PackageGroup
+	}
+	return info.jsHandlerName()
+}
+
+// Returns the functions in the package group.  All functions are returned if
+// the group is empty.
+func packageGroupLambdas(lambdaAWSInfos []*LambdaAWSInfo, group string) []*LambdaAWSInfo {
+	if "" == group {
+		return lambdaAWSInfos
+	}
+	var groupLambdas []*LambdaAWSInfo
+	for _, eachLambda := range lambdaAWSInfos {
+		if eachLambda.packageGroup() == group {
+			groupLambdas = append(groupLambdas, eachLambda)
+		}
+	}
+	return groupLambdas
 }
 
 // Returns a JavaScript compatible function name for the golang function name.  This
@@ -614,6 +885,7 @@ func (info *LambdaAWSInfo) jsHandlerName() string {
 func (info *LambdaAWSInfo) export(S3Bucket string,
 	S3Key string,
 	roleNameMap map[string]interface{},
+	serviceConfig map[string]interface{},
 	resources ArbitraryJSONObject,
 	outputs ArbitraryJSONObject,
 	logger *logrus.Logger) error {
@@ -656,11 +928,71 @@ func (info *LambdaAWSInfo) export(S3Bucket string,
 	resourceName := info.logicalName()
 	resources[resourceName] = primaryResource
 
-	// Create the lambda Ref in case we need a permission or event mapping
-	functionAttr := ArbitraryJSONObject{
-		"Fn::GetAtt": []string{resourceName, "Arn"},
+	// Deliver the config and stack ID via environment variables
+	configVariables, kmsKeyArns, err := configEnvironment(mergedConfig(serviceConfig, info.Config))
+	if nil != err {
+		return fmt.Errorf("Invalid config for %s: %s", info.lambdaFnName, err.Error())
+	}
+	if nil == configVariables {
+		configVariables = make(ArbitraryJSONObject, 0)
+	}
+	configVariables[stackIDEnvVarName] = ArbitraryJSONObject{
+		"Ref": "AWS::StackId",
+	}
+	primaryResource["Properties"].(ArbitraryJSONObject)["Environment"] = ArbitraryJSONObject{
+		"Variables": configVariables,
+	}
+	if len(kmsKeyArns) > 0 {
+		var roleName interface{} = info.RoleName
+		if "" == info.RoleName {
+			roleName = ArbitraryJSONObject{
+				"Ref": info.RoleDefinition.logicalName(),
+			}
+		}
+		kmsPolicyName := CloudFormationResourceName("ConfigKMSPolicy", info.lambdaFnName)
+		resources[kmsPolicyName] = configKMSPolicy(info.lambdaFnName, roleName, kmsKeyArns)
+		// Ensure the function can decrypt the values once it's invocable
+		primaryResource["DependsOn"] = append(dependsOn, kmsPolicyName)
+	}
+
+	// Publish a version for this code & configuration and route the alias to it
+	if nil != info.Versioning {
+		err := info.Versioning.validate(info.lambdaFnName)
+		if nil != err {
+			return err
+		}
+		versionResourceName, err := info.versionLogicalName(primaryResource["Properties"])
+		if nil != err {
+			return err
+		}
+		resources[versionResourceName] = ArbitraryJSONObject{
+			"Type": "AWS::Lambda::Version",
+			// Retain previous versions s.t. the alias can be rolled back
+			"DeletionPolicy": "Retain",
+			"Properties": ArbitraryJSONObject{
+				"FunctionName": ArbitraryJSONObject{
+					"Ref": resourceName,
+				},
+				"Description": fmt.Sprintf("%s (%s)", info.lambdaFnName, S3Key),
+			},
+		}
+		resources[info.aliasLogicalName()] = ArbitraryJSONObject{
+			"Type": "AWS::Lambda::Alias",
+			"Properties": ArbitraryJSONObject{
+				"FunctionName": ArbitraryJSONObject{
+					"Ref": resourceName,
+				},
+				"FunctionVersion": ArbitraryJSONObject{
+					"Fn::GetAtt": []string{versionResourceName, "Version"},
+				},
+				"Name": info.aliasName(),
+			},
+		}
 	}
 
+	// Create the lambda Ref in case we need a permission or event mapping
+	functionAttr := info.functionARN()
+
 	// Permissions
 	for _, eachPermission := range info.Permissions {
 		_, err := eachPermission.export(functionAttr, resources, S3Bucket, S3Key, logger)
@@ -693,6 +1025,14 @@ func (info *LambdaAWSInfo) export(S3Bucket string,
 		resources[resourceName] = primaryEventSourceMapping
 	}
 
+	// Alarms
+	if nil != info.Alarms {
+		err := info.exportAlarms(resources, outputs)
+		if nil != err {
+			return err
+		}
+	}
+
 	// Decorator
 	if nil != info.Decorator {
 		logger.Debug("Decorator found for Lambda: ", info.lambdaFnName)
@@ -734,6 +1074,44 @@ func (info *LambdaAWSInfo) logicalName() string {
 	return CloudFormationResourceName("Lambda", info.lambdaFnName)
 }
 
+// Returns the alias name for a versioned lambda
+func (info *LambdaAWSInfo) aliasName() string {
+	if nil != info.Versioning && "" != info.Versioning.AliasName {
+		return info.Versioning.AliasName
+	}
+	return DefaultLambdaAliasName
+}
+
+// Returns the stable logical name of the lambda alias
+func (info *LambdaAWSInfo) aliasLogicalName() string {
+	return CloudFormationResourceName("LambdaAlias", info.lambdaFnName, info.aliasName())
+}
+
+// Returns the logical name of the lambda version for the given function
+// properties.  The code archive key is content-addressable, so the name
+// changes whenever the code or configuration changes, which publishes a
+// new version.
+func (info *LambdaAWSInfo) versionLogicalName(functionProperties interface{}) (string, error) {
+	propertiesJSON, err := json.Marshal(functionProperties)
+	if nil != err {
+		return "", err
+	}
+	return CloudFormationResourceName("LambdaVersion", info.lambdaFnName, string(propertiesJSON)), nil
+}
+
+// Returns the CloudFormation expression for the ARN that invokers should
+// target.  Versioned lambdas are invoked through their alias.
+func (info *LambdaAWSInfo) functionARN() ArbitraryJSONObject {
+	if nil != info.Versioning {
+		return ArbitraryJSONObject{
+			"Ref": info.aliasLogicalName(),
+		}
+	}
+	return ArbitraryJSONObject{
+		"Fn::GetAtt": []string{info.logicalName(), "Arn"},
+	}
+}
+
 //
 // END - LambdaAWSInfo
 ////////////////////////////////////////////////////////////////////////////////
@@ -746,11 +1124,32 @@ func sanitizedName(input string) string {
 	return reSanitize.ReplaceAllString(input, "_")
 }
 
+// Returns a CloudFormation expression that resolves to the ARN of the
+// pre-existing IAM role name in the stack's account
+func iamRoleArn(roleName string) ArbitraryJSONObject {
+	return ArbitraryJSONObject{
+		"Fn::Join": []interface{}{"",
+			[]interface{}{"arn:aws:iam::",
+				ArbitraryJSONObject{
+					"Ref": "AWS::AccountId",
+				},
+				":role/",
+				roleName,
+			},
+		},
+	}
+}
+
 // Returns an AWS Session (https://github.com/aws/aws-sdk-go/wiki/Getting-Started-Configuration)
 // object that attaches a debug level handler to all AWS requests from services
 // sharing the session value.
 func awsSession(logger *logrus.Logger) *session.Session {
-	sess := session.New()
+	return logAWSRequests(session.New(), logger)
+}
+
+// Attaches a debug level handler to all AWS requests from services sharing
+// the session value
+func logAWSRequests(sess *session.Session, logger *logrus.Logger) *session.Session {
 	sess.Handlers.Send.PushFront(func(r *request.Request) {
 		logger.WithFields(logrus.Fields{
 			"Service":   r.ClientInfo.ServiceName,
@@ -766,8 +1165,10 @@ func awsSession(logger *logrus.Logger) *session.Session {
 // CloudFormationResourceName returns a name suitable as a logical
 // CloudFormation resource value.  See http://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/resources-section-structure.html
 // for more information.  The `prefix` value should provide a hint as to the
-// resource type (eg, `SNSConfigurator`, `ImageTranscoder`).  Note that the returned
-// name is not content-addressable.
+// resource type (eg, `SNSConfigurator`, `ImageTranscoder`).  The returned name is
+// a stable function of the prefix and parts, so that the same service definition
+// always produces the same template.  If no parts are provided, the name includes
+// a random value and is different for every call.
 func CloudFormationResourceName(prefix string, parts ...string) string {
 	hash := sha1.New()
 	hash.Write([]byte(prefix))
@@ -782,6 +1183,16 @@ func CloudFormationResourceName(prefix string, parts ...string) string {
 	return fmt.Sprintf("%s%s", prefix, hex.EncodeToString(hash.Sum(nil)))
 }
 
+// Returns values with value appended, unless values already contains value
+func appendUnique(values []string, value string) []string {
+	for _, eachValue := range values {
+		if eachValue == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Public
 ////////////////////////////////////////////////////////////////////////////////
@@ -841,6 +1252,16 @@ func NewLogger(level string) (*logrus.Logger, error) {
 // See http://docs.aws.amazon.com/sdk-for-go/api/aws/defaults.html#DefaultChainCredentials-constant
 // for more information.
 func Main(serviceName string, serviceDescription string, lambdaAWSInfos []*LambdaAWSInfo, api *API) error {
+	return MainEx(serviceName, serviceDescription, lambdaAWSInfos, api, nil)
+}
+
+// MainEx is Main with additional ProvisionOptions that are applied by the
+// provision and deploy commands.
+func MainEx(serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	provisionOptions *ProvisionOptions) error {
 
 	// We need to be able to provision an IAM role that has capabilities to
 	// manage the other sources.  That'll give us the role arn to use in the custom
@@ -852,22 +1273,64 @@ func Main(serviceName string, serviceDescription string, lambdaAWSInfos []*Lambd
 
 		Verb      goptions.Verbs
 		Provision struct {
-			S3Bucket string `goptions:"-b,--s3Bucket, description='S3 Bucket to use for Lambda source', obligatory"`
+			S3Bucket string `goptions:"-b,--s3Bucket, description='S3 Bucket to use for Lambda source'"`
+			Targets  string `goptions:"-t,--targets, description='JSON file of deployment targets (overrides --s3Bucket)'"`
 		} `goptions:"provision"`
+		Package struct {
+			OutputDir string `goptions:"-o,--out, description='Output directory for the ZIP archive and CloudFormation template', obligatory"`
+			S3Bucket  string `goptions:"-b,--s3Bucket, description='S3 Bucket to use for Lambda source (default: supplied at deploy time)'"`
+		} `goptions:"package"`
+		Deploy struct {
+			InputDir string `goptions:"-f,--from, description='Directory produced by the package command', obligatory"`
+			S3Bucket string `goptions:"-b,--s3Bucket, description='S3 Bucket to use for Lambda source (default: package S3 Bucket)'"`
+		} `goptions:"deploy"`
 		Delete struct {
+			S3Bucket string   `goptions:"-b,--s3Bucket, description='S3 Bucket whose service artifacts should also be deleted'"`
+			Retain   []string `goptions:"-r,--retain, description='Logical ID of a resource to retain if it blocks stack deletion (repeatable)'"`
+			Targets  string   `goptions:"-t,--targets, description='JSON file of deployment targets (overrides --s3Bucket)'"`
 		} `goptions:"delete"`
+		Diff struct {
+			S3Bucket string `goptions:"-b,--s3Bucket, description='S3 Bucket to use for Lambda source'"`
+			Targets  string `goptions:"-t,--targets, description='JSON file of deployment targets (overrides --s3Bucket)'"`
+		} `goptions:"diff"`
+		Prune struct {
+			S3Bucket  string `goptions:"-b,--s3Bucket, description='S3 Bucket used for Lambda source', obligatory"`
+			KeepCount int    `goptions:"-k,--keep, description='Number of deployments whose code archives and templates are kept (default=5)'"`
+		} `goptions:"prune"`
+		Validate struct {
+		} `goptions:"validate"`
 		Execute struct {
-			Port            int `goptions:"-p,--port, description='Alternative port for HTTP binding (default=9999)'"`
-			SignalParentPID int `goptions:"-s,--signal, description='Process ID to signal with SIGUSR2 once ready'"`
+			Port            int    `goptions:"-p,--port, description='Alternative port for HTTP binding (default=9999)'"`
+			SignalParentPID int    `goptions:"-s,--signal, description='Process ID to signal with SIGUSR2 once ready'"`
+			Transport       string `goptions:"-x,--transport, description='Request transport [http, stdio, unix] (default=http)'"`
+			SocketPath      string `goptions:"--socket, description='Unix domain socket path for the unix transport'"`
+			Metrics         bool   `goptions:"--metrics, description='Publish metrics to CloudWatch'"`
+			PackageGroup    string `goptions:"--packageGroup, description='Only dispatch to the functions in the package group'"`
 		} `goptions:"execute"`
 		Describe struct {
 			OutputFile string `goptions:"-o,--out, description='Output file for HTML description', obligatory"`
 		} `goptions:"describe"`
 		Explore struct {
 		} `goptions:"explore"`
+		Logs struct {
+			Since    string `goptions:"-s,--since, description='Age of the oldest log event (eg: 30m, 2h) (default=10m)'"`
+			Filter   string `goptions:"--filter, description='CloudWatch Logs filter pattern'"`
+			Function string `goptions:"--function, description='Only include functions whose name contains this value'"`
+			Follow   bool   `goptions:"-f,--follow, description='Poll for new log events until interrupted'"`
+		} `goptions:"logs"`
+		Rollback struct {
+			S3Bucket string `goptions:"-b,--s3Bucket, description='S3 Bucket used for Lambda source'"`
+			To       int    `goptions:"--to, description='Number of deployments to roll back (default=1)'"`
+			Targets  string `goptions:"-t,--targets, description='JSON file of deployment targets (overrides --s3Bucket)'"`
+		} `goptions:"rollback"`
+		Status struct {
+			Output string `goptions:"-o,--output, description='Output format [text, json] (default=text)'"`
+		} `goptions:"status"`
 	}{ // Default values goes here
 		LogLevel: "info",
 	}
+	options.Prune.KeepCount = DefaultArtifactRetentionCount
+	options.Rollback.To = 1
 	goptions.ParseAndFail(&options)
 	logger, err := NewLogger(options.LogLevel)
 	if err != nil {
@@ -882,16 +1345,98 @@ func Main(serviceName string, serviceDescription string, lambdaAWSInfos []*Lambd
 	switch options.Verb {
 	case "provision":
 		logger.Formatter = new(logrus.TextFormatter)
-		err = Provision(options.Noop, serviceName, serviceDescription, lambdaAWSInfos, api, options.Provision.S3Bucket, nil, logger)
+		if "" != options.Provision.Targets {
+			var targets []*DeploymentTarget
+			targets, err = LoadDeploymentTargets(options.Provision.Targets)
+			if nil == err {
+				err = ProvisionTargets(options.Noop, serviceName, serviceDescription, lambdaAWSInfos, api, targets, provisionOptions, logger)
+			}
+		} else if "" == options.Provision.S3Bucket {
+			err = errors.New("provision requires either -b/--s3Bucket or -t/--targets")
+		} else {
+			err = ProvisionEx(options.Noop, serviceName, serviceDescription, lambdaAWSInfos, api, options.Provision.S3Bucket, provisionOptions, nil, logger)
+		}
+	case "package":
+		logger.Formatter = new(logrus.TextFormatter)
+		err = Package(serviceName, serviceDescription, lambdaAWSInfos, api, options.Package.S3Bucket, options.Package.OutputDir, provisionOptions, logger)
+	case "deploy":
+		logger.Formatter = new(logrus.TextFormatter)
+		err = Deploy(serviceName, options.Deploy.InputDir, options.Deploy.S3Bucket, provisionOptions, logger)
+	case "validate":
+		logger.Formatter = new(logrus.TextFormatter)
+		err = Validate(serviceName, serviceDescription, lambdaAWSInfos, api, provisionOptions, logger)
 	case "execute":
 		logger.Formatter = new(logrus.JSONFormatter)
-		err = Execute(lambdaAWSInfos, options.Execute.Port, options.Execute.SignalParentPID, logger)
+		if options.Execute.Metrics {
+			SetMetricsSink(NewCloudWatchMetricsSink())
+		}
+		groupLambdas := packageGroupLambdas(lambdaAWSInfos, options.Execute.PackageGroup)
+		if len(groupLambdas) <= 0 {
+			return fmt.Errorf("No lambda functions in package group: %s", options.Execute.PackageGroup)
+		}
+		err = ExecuteEx(groupLambdas,
+			options.Execute.Transport,
+			options.Execute.Port,
+			options.Execute.SocketPath,
+			options.Execute.SignalParentPID,
+			logger)
 	case "delete":
 		logger.Formatter = new(logrus.TextFormatter)
-		err = Delete(serviceName, logger)
+		if "" != options.Delete.Targets {
+			var targets []*DeploymentTarget
+			targets, err = LoadDeploymentTargets(options.Delete.Targets)
+			if nil == err {
+				err = DeleteTargets(serviceName, targets, options.Delete.Retain, logger)
+			}
+		} else {
+			err = DeleteEx(serviceName, options.Delete.S3Bucket, options.Delete.Retain, logger)
+		}
+	case "diff":
+		logger.Formatter = new(logrus.TextFormatter)
+		if "" != options.Diff.Targets {
+			var targets []*DeploymentTarget
+			targets, err = LoadDeploymentTargets(options.Diff.Targets)
+			if nil == err {
+				err = DiffTargets(serviceName, serviceDescription, lambdaAWSInfos, api, targets, provisionOptions, os.Stdout, logger)
+			}
+		} else {
+			err = Diff(serviceName, serviceDescription, lambdaAWSInfos, api, options.Diff.S3Bucket, provisionOptions, os.Stdout, logger)
+		}
+	case "prune":
+		logger.Formatter = new(logrus.TextFormatter)
+		err = Prune(serviceName, options.Prune.S3Bucket, options.Prune.KeepCount, logger)
 	case "explore":
 		logger.Formatter = new(logrus.TextFormatter)
 		err = Explore(serviceName, logger)
+	case "logs":
+		logger.Formatter = new(logrus.TextFormatter)
+		logsOptions := &LogsOptions{
+			Filter:   options.Logs.Filter,
+			Function: options.Logs.Function,
+			Follow:   options.Logs.Follow,
+		}
+		if "" != options.Logs.Since {
+			logsOptions.Since, err = time.ParseDuration(options.Logs.Since)
+		}
+		if nil == err {
+			err = Logs(serviceName, logsOptions, os.Stdout, logger)
+		}
+	case "rollback":
+		logger.Formatter = new(logrus.TextFormatter)
+		if "" != options.Rollback.Targets {
+			var targets []*DeploymentTarget
+			targets, err = LoadDeploymentTargets(options.Rollback.Targets)
+			if nil == err {
+				err = RollbackTargets(options.Noop, serviceName, targets, options.Rollback.To, provisionOptions, logger)
+			}
+		} else if "" == options.Rollback.S3Bucket {
+			err = errors.New("rollback requires either -b/--s3Bucket or -t/--targets")
+		} else {
+			err = Rollback(options.Noop, serviceName, options.Rollback.S3Bucket, options.Rollback.To, provisionOptions, logger)
+		}
+	case "status":
+		logger.Formatter = new(logrus.TextFormatter)
+		err = Status(serviceName, options.Status.Output, os.Stdout, logger)
 	case "describe":
 		logger.Formatter = new(logrus.TextFormatter)
 		fileWriter, err := os.Create(options.Describe.OutputFile)
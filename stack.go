@@ -0,0 +1,149 @@
+package sparta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// Environment variable that stores the ID of the stack that
+// provisioned the function
+const stackIDEnvVarName = "SPARTA_STACK_ID"
+
+// Physical resource IDs and outputs of a provisioned stack
+type stackInfo struct {
+	resources map[string]string
+	outputs   map[string]string
+}
+
+// Per-container stackInfo cache, keyed by stack ID
+var stackInfoCache = make(map[string]*stackInfo, 0)
+var stackInfoCacheMutex sync.Mutex
+
+// Returns the ID of the stack that provisioned this function
+func lambdaStackID() (string, error) {
+	stackID := os.Getenv(stackIDEnvVarName)
+	if "" == stackID {
+		return "", fmt.Errorf("%s is not defined. Stack information is only available to provisioned Lambda functions", stackIDEnvVarName)
+	}
+	return stackID, nil
+}
+
+// Returns the resources (including the resources of nested stacks)
+// and outputs of the root stack of stackID
+func describeStackInfo(stackID string) (*stackInfo, error) {
+	awsCloudFormation := cloudformation.New(session.New())
+	describeStacksOutput, err := awsCloudFormation.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackID),
+	})
+	if nil != err {
+		return nil, err
+	}
+	if len(describeStacksOutput.Stacks) <= 0 {
+		return nil, fmt.Errorf("Failed to describe stack: %s", stackID)
+	}
+	stack := describeStacksOutput.Stacks[0]
+	// Functions in nested stacks resolve values from the root stack
+	if "" != aws.StringValue(stack.RootId) {
+		describeStacksOutput, err = awsCloudFormation.DescribeStacks(&cloudformation.DescribeStacksInput{
+			StackName: stack.RootId,
+		})
+		if nil != err {
+			return nil, err
+		}
+		if len(describeStacksOutput.Stacks) <= 0 {
+			return nil, fmt.Errorf("Failed to describe root stack: %s", aws.StringValue(stack.RootId))
+		}
+		stack = describeStacksOutput.Stacks[0]
+	}
+	info := &stackInfo{
+		resources: make(map[string]string, 0),
+		outputs:   make(map[string]string, 0),
+	}
+	for _, eachOutput := range stack.Outputs {
+		info.outputs[aws.StringValue(eachOutput.OutputKey)] = aws.StringValue(eachOutput.OutputValue)
+	}
+	stackIDs := []*string{stack.StackId}
+	for len(stackIDs) > 0 {
+		params := &cloudformation.ListStackResourcesInput{
+			StackName: stackIDs[0],
+		}
+		stackIDs = stackIDs[1:]
+		err = awsCloudFormation.ListStackResourcesPages(params, func(page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+			for _, eachSummary := range page.StackResourceSummaries {
+				info.resources[aws.StringValue(eachSummary.LogicalResourceId)] = aws.StringValue(eachSummary.PhysicalResourceId)
+				if aws.StringValue(eachSummary.ResourceType) == "AWS::CloudFormation::Stack" &&
+					"" != aws.StringValue(eachSummary.PhysicalResourceId) {
+					stackIDs = append(stackIDs, eachSummary.PhysicalResourceId)
+				}
+			}
+			return true
+		})
+		if nil != err {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// Returns a value from the function's stack information.  The stack
+// information is cached and refreshed once if the value isn't found, in
+// case the stack was updated after the container started.
+func stackInfoValue(value func(*stackInfo) (string, bool)) (string, error) {
+	stackID, err := lambdaStackID()
+	if nil != err {
+		return "", err
+	}
+	stackInfoCacheMutex.Lock()
+	defer stackInfoCacheMutex.Unlock()
+
+	info, cached := stackInfoCache[stackID]
+	if cached {
+		if result, exists := value(info); exists {
+			return result, nil
+		}
+	}
+	info, err = describeStackInfo(stackID)
+	if nil != err {
+		return "", err
+	}
+	stackInfoCache[stackID] = info
+	if result, exists := value(info); exists {
+		return result, nil
+	}
+	return "", errors.New("Not found")
+}
+
+// StackResource returns the physical ID (eg, bucket name, table name, ARN) of
+// the resource with the given logical name in the stack that provisioned the
+// calling Lambda function.  Resources added by TemplateDecorators are
+// included.  Values are cached for the lifetime of the container.
+func StackResource(logicalName string) (string, error) {
+	physicalID, err := stackInfoValue(func(info *stackInfo) (string, bool) {
+		physicalID, exists := info.resources[logicalName]
+		return physicalID, exists
+	})
+	if nil != err {
+		return "", fmt.Errorf("Failed to resolve stack resource %s: %s", logicalName, err.Error())
+	}
+	return physicalID, nil
+}
+
+// StackOutput returns the value of the named output of the stack that
+// provisioned the calling Lambda function.  Values are cached for the
+// lifetime of the container.
+func StackOutput(key string) (string, error) {
+	outputValue, err := stackInfoValue(func(info *stackInfo) (string, bool) {
+		outputValue, exists := info.outputs[key]
+		return outputValue, exists
+	})
+	if nil != err {
+		return "", fmt.Errorf("Failed to resolve stack output %s: %s", key, err.Error())
+	}
+	return outputValue, nil
+}
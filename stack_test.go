@@ -0,0 +1,40 @@
+package sparta
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStackResource(t *testing.T) {
+	_, err := StackResource("SampleBucket")
+	if nil == err {
+		t.Fatal("Expected StackResource to fail outside of a provisioned function")
+	}
+	stackID := "arn:aws:cloudformation:us-west-2:123412341234:stack/SampleProvision/00000000-0000-0000-0000-000000000000"
+	os.Setenv(stackIDEnvVarName, stackID)
+	defer os.Unsetenv(stackIDEnvVarName)
+	stackInfoCache[stackID] = &stackInfo{
+		resources: map[string]string{
+			"SampleBucket": "sampleprovision-samplebucket-1a2b3c4d",
+		},
+		outputs: map[string]string{
+			"SampleTableName": "SampleTable",
+		},
+	}
+	defer delete(stackInfoCache, stackID)
+
+	bucketName, err := StackResource("SampleBucket")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if bucketName != "sampleprovision-samplebucket-1a2b3c4d" {
+		t.Errorf("Unexpected StackResource value: %s", bucketName)
+	}
+	tableName, err := StackOutput("SampleTableName")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if tableName != "SampleTable" {
+		t.Errorf("Unexpected StackOutput value: %s", tableName)
+	}
+}
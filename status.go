@@ -0,0 +1,344 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+const (
+	// StatusOutputText is the human readable Status output format
+	StatusOutputText = "text"
+	// StatusOutputJSON is the JSON Status output format
+	StatusOutputJSON = "json"
+)
+
+// Maximum number of failed stack events included in the status
+const statusFailedEventCount = 10
+
+// FunctionStatus is the deployed configuration of a Lambda function
+type FunctionStatus struct {
+	LogicalResourceID string
+	FunctionName      string
+	FunctionARN       string
+	MemorySize        int64
+	Timeout           int64
+	LastModified      string
+}
+
+// StackEventStatus is a failed stack event
+type StackEventStatus struct {
+	Timestamp time.Time
+	// Name of the stack that produced the event.  Differs from
+	// ServiceStatus.StackName for nested stack failures.
+	StackName         string
+	LogicalResourceID string
+	ResourceType      string
+	ResourceStatus    string
+	Reason            string
+}
+
+// ServiceStatus is the deployed state of a service
+type ServiceStatus struct {
+	StackName         string
+	StackID           string
+	StackStatus       string
+	StackStatusReason string `json:",omitempty"`
+	LastUpdated       time.Time
+	// SpartaVersion output of the deployed stack
+	DeployedVersion string
+	// SpartaVersion of this binary
+	LocalVersion string
+	// API Gateway URL, if the service defines an API
+	APIURL       string `json:",omitempty"`
+	Outputs      map[string]string
+	Functions    []*FunctionStatus
+	FailedEvents []*StackEventStatus
+}
+
+// Returns the deployed state of the stack
+func serviceStatus(serviceName string,
+	cf *cloudformation.CloudFormation,
+	lambdaSvc *lambda.Lambda,
+	logger *logrus.Logger) (*ServiceStatus, error) {
+
+	describeStacksOutput, err := cf.DescribeStacks(&cloudformation.DescribeStacksInput{
+		StackName: aws.String(serviceName),
+	})
+	if nil != err {
+		return nil, err
+	}
+	if len(describeStacksOutput.Stacks) <= 0 {
+		return nil, fmt.Errorf("Stack does not exist: %s", serviceName)
+	}
+	stack := describeStacksOutput.Stacks[0]
+	status := &ServiceStatus{
+		StackName:         aws.StringValue(stack.StackName),
+		StackID:           aws.StringValue(stack.StackId),
+		StackStatus:       aws.StringValue(stack.StackStatus),
+		StackStatusReason: aws.StringValue(stack.StackStatusReason),
+		LastUpdated:       aws.TimeValue(stack.CreationTime),
+		LocalVersion:      SpartaVersion,
+		Outputs:           make(map[string]string, 0),
+	}
+	if nil != stack.LastUpdatedTime {
+		status.LastUpdated = *stack.LastUpdatedTime
+	}
+	for _, eachOutput := range stack.Outputs {
+		status.Outputs[aws.StringValue(eachOutput.OutputKey)] = aws.StringValue(eachOutput.OutputValue)
+	}
+	status.DeployedVersion = status.Outputs[OutputSpartaVersionKey]
+	status.APIURL = status.Outputs["URL"]
+
+	// Lambda functions
+	resources, err := stackLambdaResources(status.StackID, cf, logger)
+	if nil != err {
+		return nil, err
+	}
+	for _, eachResource := range resources {
+		functionStatus := &FunctionStatus{
+			LogicalResourceID: aws.StringValue(eachResource.LogicalResourceId),
+			FunctionName:      aws.StringValue(eachResource.PhysicalResourceId),
+		}
+		if "" != functionStatus.FunctionName {
+			config, err := lambdaSvc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+				FunctionName: aws.String(functionStatus.FunctionName),
+			})
+			if nil != err {
+				return nil, err
+			}
+			functionStatus.FunctionARN = aws.StringValue(config.FunctionArn)
+			functionStatus.MemorySize = aws.Int64Value(config.MemorySize)
+			functionStatus.Timeout = aws.Int64Value(config.Timeout)
+			functionStatus.LastModified = aws.StringValue(config.LastModified)
+		}
+		status.Functions = append(status.Functions, functionStatus)
+	}
+	sort.Sort(functionStatusByName(status.Functions))
+
+	// Most recent failures, oldest first
+	failedEvents, err := lastOperationFailureEvents(status.StackID, cf)
+	if nil != err {
+		return nil, err
+	}
+	if len(failedEvents) > statusFailedEventCount {
+		failedEvents = failedEvents[len(failedEvents)-statusFailedEventCount:]
+	}
+	for _, eachEvent := range failedEvents {
+		status.FailedEvents = append(status.FailedEvents, &StackEventStatus{
+			Timestamp:         aws.TimeValue(eachEvent.Timestamp),
+			StackName:         aws.StringValue(eachEvent.StackName),
+			LogicalResourceID: aws.StringValue(eachEvent.LogicalResourceId),
+			ResourceType:      aws.StringValue(eachEvent.ResourceType),
+			ResourceStatus:    aws.StringValue(eachEvent.ResourceStatus),
+			Reason:            aws.StringValue(eachEvent.ResourceStatusReason),
+		})
+	}
+	return status, nil
+}
+
+// Subset of the CloudFormation API used to read stack events
+type stackEventsDescriber interface {
+	DescribeStackEventsPages(*cloudformation.DescribeStackEventsInput, func(*cloudformation.DescribeStackEventsOutput, bool) bool) error
+}
+
+// Returns true if the event is the start of an operation on the stack that
+// produced it, rather than on one of its resources
+func isStackOperationStart(event *cloudformation.StackEvent) bool {
+	if aws.StringValue(event.PhysicalResourceId) != aws.StringValue(event.StackId) {
+		return false
+	}
+	switch aws.StringValue(event.ResourceStatus) {
+	case cloudformation.ResourceStatusCreateInProgress,
+		cloudformation.ResourceStatusUpdateInProgress,
+		cloudformation.ResourceStatusDeleteInProgress:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the failed events of the stack's most recent operation, oldest
+// first.  Events are only read back to the start of the operation.  The
+// failures of nested stacks that failed during the operation are included.
+func lastOperationFailureEvents(stackID string, cf stackEventsDescriber) ([]*cloudformation.StackEvent, error) {
+	var failedEvents []*cloudformation.StackEvent
+	visitedStacks := make(map[string]bool, 0)
+
+	var appendFailureEvents func(stackID string) error
+	appendFailureEvents = func(stackID string) error {
+		if visitedStacks[stackID] {
+			return nil
+		}
+		visitedStacks[stackID] = true
+		// Events are returned newest first
+		var operationEvents []*cloudformation.StackEvent
+		params := &cloudformation.DescribeStackEventsInput{
+			StackName: aws.String(stackID),
+		}
+		err := cf.DescribeStackEventsPages(params, func(page *cloudformation.DescribeStackEventsOutput, lastPage bool) bool {
+			for _, eachEvent := range page.StackEvents {
+				operationEvents = append(operationEvents, eachEvent)
+				if isStackOperationStart(eachEvent) {
+					return false
+				}
+			}
+			return true
+		})
+		if nil != err {
+			return err
+		}
+		for _, eachEvent := range operationEvents {
+			switch aws.StringValue(eachEvent.ResourceStatus) {
+			case cloudformation.ResourceStatusCreateFailed,
+				cloudformation.ResourceStatusDeleteFailed,
+				cloudformation.ResourceStatusUpdateFailed:
+				failedEvents = append(failedEvents, eachEvent)
+			default:
+				continue
+			}
+			nestedStackID := aws.StringValue(eachEvent.PhysicalResourceId)
+			if "AWS::CloudFormation::Stack" == aws.StringValue(eachEvent.ResourceType) &&
+				"" != nestedStackID &&
+				nestedStackID != aws.StringValue(eachEvent.StackId) {
+				err = appendFailureEvents(nestedStackID)
+				if nil != err {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	err := appendFailureEvents(stackID)
+	if nil != err {
+		return nil, err
+	}
+	sort.Stable(stackEventsByTime(failedEvents))
+	return failedEvents, nil
+}
+
+type stackEventsByTime []*cloudformation.StackEvent
+
+func (events stackEventsByTime) Len() int {
+	return len(events)
+}
+func (events stackEventsByTime) Swap(i, j int) {
+	events[i], events[j] = events[j], events[i]
+}
+func (events stackEventsByTime) Less(i, j int) bool {
+	return aws.TimeValue(events[i].Timestamp).Before(aws.TimeValue(events[j].Timestamp))
+}
+
+type functionStatusByName []*FunctionStatus
+
+func (functions functionStatusByName) Len() int { return len(functions) }
+func (functions functionStatusByName) Swap(i, j int) {
+	functions[i], functions[j] = functions[j], functions[i]
+}
+func (functions functionStatusByName) Less(i, j int) bool {
+	return functions[i].FunctionName < functions[j].FunctionName
+}
+
+// Write the status in the given output format
+func writeServiceStatus(status *ServiceStatus, outputFormat string, writer io.Writer) error {
+	switch outputFormat {
+	case StatusOutputJSON:
+		statusJSON, err := json.MarshalIndent(status, "", "  ")
+		if nil != err {
+			return err
+		}
+		_, err = fmt.Fprintln(writer, string(statusJSON))
+		return err
+	case "", StatusOutputText:
+		// Handled below
+	default:
+		return fmt.Errorf("Unsupported output format: %s", outputFormat)
+	}
+
+	tw := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Stack:\t%s\n", status.StackName)
+	fmt.Fprintf(tw, "Status:\t%s\n", status.StackStatus)
+	if "" != status.StackStatusReason {
+		fmt.Fprintf(tw, "Reason:\t%s\n", status.StackStatusReason)
+	}
+	fmt.Fprintf(tw, "Last Updated:\t%s\n", status.LastUpdated.Format(time.RFC3339))
+	versionNote := ""
+	if status.DeployedVersion != status.LocalVersion {
+		versionNote = fmt.Sprintf(" (local: %s)", status.LocalVersion)
+	}
+	fmt.Fprintf(tw, "Sparta Version:\t%s%s\n", status.DeployedVersion, versionNote)
+	if "" != status.APIURL {
+		fmt.Fprintf(tw, "API URL:\t%s\n", status.APIURL)
+	}
+
+	fmt.Fprintf(tw, "\nFunctions:\n")
+	fmt.Fprintf(tw, "  NAME\tMEMORY\tTIMEOUT\tLAST MODIFIED\tARN\n")
+	for _, eachFunction := range status.Functions {
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\t%s\n",
+			eachFunction.FunctionName,
+			eachFunction.MemorySize,
+			eachFunction.Timeout,
+			eachFunction.LastModified,
+			eachFunction.FunctionARN)
+	}
+
+	if len(status.Outputs) > 0 {
+		var outputKeys []string
+		for eachKey := range status.Outputs {
+			outputKeys = append(outputKeys, eachKey)
+		}
+		sort.Strings(outputKeys)
+		fmt.Fprintf(tw, "\nOutputs:\n")
+		for _, eachKey := range outputKeys {
+			fmt.Fprintf(tw, "  %s\t%s\n", eachKey, status.Outputs[eachKey])
+		}
+	}
+
+	if len(status.FailedEvents) > 0 {
+		fmt.Fprintf(tw, "\nRecent Failures:\n")
+		for _, eachEvent := range status.FailedEvents {
+			// Qualify the resources of nested stacks
+			resourceID := eachEvent.LogicalResourceID
+			if "" != eachEvent.StackName && eachEvent.StackName != status.StackName {
+				resourceID = fmt.Sprintf("%s/%s", eachEvent.StackName, resourceID)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n",
+				eachEvent.Timestamp.Format(time.RFC3339),
+				resourceID,
+				eachEvent.ResourceType,
+				eachEvent.ResourceStatus,
+				eachEvent.Reason)
+		}
+	}
+	return tw.Flush()
+}
+
+// Status writes the deployed state of the previously provisioned service to
+// writer.  The outputFormat is one of StatusOutputText or StatusOutputJSON
+// and defaults to StatusOutputText.
+func Status(serviceName string, outputFormat string, writer io.Writer, logger *logrus.Logger) error {
+	session := awsSession(logger)
+	awsCloudFormation := cloudformation.New(session)
+
+	exists, err := stackExists(serviceName, awsCloudFormation, logger)
+	if nil != err {
+		return err
+	} else if !exists {
+		return fmt.Errorf("Stack does not exist: %s", serviceName)
+	}
+	status, err := serviceStatus(serviceName, awsCloudFormation, lambda.New(session), logger)
+	if nil != err {
+		return err
+	}
+	return writeServiceStatus(status, outputFormat, writer)
+}
@@ -0,0 +1,168 @@
+package sparta
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func testServiceStatus() *ServiceStatus {
+	return &ServiceStatus{
+		StackName:       "SampleProvision",
+		StackStatus:     "UPDATE_COMPLETE",
+		LastUpdated:     time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC),
+		DeployedVersion: "0.0.5",
+		LocalVersion:    SpartaVersion,
+		APIURL:          "https://abcdefghij.execute-api.us-west-2.amazonaws.com/v1",
+		Outputs: map[string]string{
+			OutputSpartaVersionKey: "0.0.5",
+			"URL":                  "https://abcdefghij.execute-api.us-west-2.amazonaws.com/v1",
+		},
+		Functions: []*FunctionStatus{
+			{
+				LogicalResourceID: "Lambdaf27edd097c0a7d67f932156408a483b551247e39",
+				FunctionName:      "SampleProvision-Lambdaf27edd097c0a7d-1ABCDEF",
+				FunctionARN:       "arn:aws:lambda:us-west-2:123412341234:function:SampleProvision-Lambdaf27edd097c0a7d-1ABCDEF",
+				MemorySize:        128,
+				Timeout:           3,
+				LastModified:      "2016-01-01T00:00:00.000+0000",
+			},
+		},
+		FailedEvents: []*StackEventStatus{
+			{
+				Timestamp:         time.Date(2015, time.December, 31, 0, 0, 0, 0, time.UTC),
+				LogicalResourceID: "Lambdaf27edd097c0a7d67f932156408a483b551247e39",
+				ResourceType:      "AWS::Lambda::Function",
+				ResourceStatus:    "UPDATE_FAILED",
+				Reason:            "Timeout must be less than 300",
+			},
+		},
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	var output bytes.Buffer
+	err := writeServiceStatus(testServiceStatus(), StatusOutputText, &output)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	for _, eachExpected := range []string{
+		"UPDATE_COMPLETE",
+		"0.0.5 (local: " + SpartaVersion + ")",
+		"SampleProvision-Lambdaf27edd097c0a7d-1ABCDEF",
+		"Timeout must be less than 300",
+	} {
+		if !strings.Contains(output.String(), eachExpected) {
+			t.Errorf("Expected status output to include %s:\n%s", eachExpected, output.String())
+		}
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	var output bytes.Buffer
+	status := testServiceStatus()
+	err := writeServiceStatus(status, StatusOutputJSON, &output)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	decoded := &ServiceStatus{}
+	err = json.Unmarshal(output.Bytes(), decoded)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(status, decoded) {
+		t.Errorf("Unexpected JSON status: %s", output.String())
+	}
+	err = writeServiceStatus(status, "yaml", &output)
+	if nil == err {
+		t.Error("Expected unsupported output format to fail")
+	}
+}
+
+// Returns canned events, newest first, for each stack ID
+type fakeStackEventsDescriber struct {
+	events map[string][]*cloudformation.StackEvent
+	// Number of events read from each stack
+	readCounts map[string]int
+}
+
+func (fake *fakeStackEventsDescriber) DescribeStackEventsPages(params *cloudformation.DescribeStackEventsInput,
+	fn func(*cloudformation.DescribeStackEventsOutput, bool) bool) error {
+	events := fake.events[*params.StackName]
+	// Return one event per page
+	for index, eachEvent := range events {
+		fake.readCounts[*params.StackName]++
+		page := &cloudformation.DescribeStackEventsOutput{
+			StackEvents: []*cloudformation.StackEvent{eachEvent},
+		}
+		if !fn(page, index == len(events)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func testStackEvent(stackID string, minute int, logicalID string, physicalID string, resourceType string, status string) *cloudformation.StackEvent {
+	return &cloudformation.StackEvent{
+		StackId:            aws.String(stackID),
+		StackName:          aws.String(strings.Split(stackID, "/")[1]),
+		Timestamp:          aws.Time(time.Date(2016, time.January, 1, 0, minute, 0, 0, time.UTC)),
+		LogicalResourceId:  aws.String(logicalID),
+		PhysicalResourceId: aws.String(physicalID),
+		ResourceType:       aws.String(resourceType),
+		ResourceStatus:     aws.String(status),
+	}
+}
+
+func TestLastOperationFailureEvents(t *testing.T) {
+	parentID := "arn:aws:cloudformation:us-west-2:123412341234:stack/SampleProvision/1"
+	nestedID := "arn:aws:cloudformation:us-west-2:123412341234:stack/SampleProvision-Nested/2"
+	stackType := "AWS::CloudFormation::Stack"
+	fake := &fakeStackEventsDescriber{
+		events: map[string][]*cloudformation.StackEvent{
+			parentID: {
+				testStackEvent(parentID, 9, "SampleProvision", parentID, stackType, "UPDATE_ROLLBACK_COMPLETE"),
+				testStackEvent(parentID, 8, "Nested", nestedID, stackType, "UPDATE_FAILED"),
+				testStackEvent(parentID, 7, "SampleProvision", parentID, stackType, "UPDATE_ROLLBACK_IN_PROGRESS"),
+				testStackEvent(parentID, 6, "Table", "Table", "AWS::DynamoDB::Table", "UPDATE_FAILED"),
+				testStackEvent(parentID, 5, "Nested", nestedID, stackType, "UPDATE_IN_PROGRESS"),
+				testStackEvent(parentID, 4, "SampleProvision", parentID, stackType, "UPDATE_IN_PROGRESS"),
+				// Previous operation
+				testStackEvent(parentID, 1, "Table", "Table", "AWS::DynamoDB::Table", "CREATE_FAILED"),
+				testStackEvent(parentID, 0, "SampleProvision", parentID, stackType, "CREATE_IN_PROGRESS"),
+			},
+			nestedID: {
+				testStackEvent(nestedID, 8, "LambdaFunction", "Function", "AWS::Lambda::Function", "UPDATE_FAILED"),
+				testStackEvent(nestedID, 5, "SampleProvision-Nested", nestedID, stackType, "UPDATE_IN_PROGRESS"),
+				testStackEvent(nestedID, 1, "LambdaFunction", "Function", "AWS::Lambda::Function", "CREATE_FAILED"),
+			},
+		},
+		readCounts: make(map[string]int, 0),
+	}
+	events, err := lastOperationFailureEvents(parentID, fake)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	var actual []string
+	for _, eachEvent := range events {
+		actual = append(actual, *eachEvent.StackName+"/"+*eachEvent.LogicalResourceId)
+	}
+	expected := []string{
+		"SampleProvision/Table",
+		"SampleProvision/Nested",
+		"SampleProvision-Nested/LambdaFunction",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected failure events: %v", actual)
+	}
+	// Paging stops at the start of each stack's last operation
+	if fake.readCounts[parentID] != 6 || fake.readCounts[nestedID] != 2 {
+		t.Errorf("Unexpected event read counts: %v", fake.readCounts)
+	}
+}
@@ -0,0 +1,193 @@
+package sparta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DeploymentTarget defines a region and account that a service is
+// provisioned to.  A targets file is a JSON array of DeploymentTarget
+// objects:
+//
+//	[
+//	  {
+//	    "name": "production-east",
+//	    "region": "us-east-1",
+//	    "profile": "production",
+//	    "s3Bucket": "my-lambda-code-us-east-1",
+//	    "stackNameSuffix": "-east"
+//	  },
+//	  {
+//	    "region": "eu-west-1",
+//	    "roleArn": "arn:aws:iam::123412341234:role/SpartaDeployer",
+//	    "s3Bucket": "my-lambda-code-eu-west-1"
+//	  }
+//	]
+//
+// AWS Lambda requires the code ZIP to be hosted in the same region as the
+// function, so each target's S3Bucket must be in the target's region.
+type DeploymentTarget struct {
+	// Optional display name.  Defaults to the target's stack name and region.
+	Name string `json:"name,omitempty"`
+	// AWS region.  Defaults to the region resolved by the SDK's default
+	// configuration (eg, AWS_REGION).
+	Region string `json:"region,omitempty"`
+	// Optional shared credentials profile name
+	Profile string `json:"profile,omitempty"`
+	// Optional IAM role ARN to assume for all target operations.  The role
+	// is assumed using the credentials from Profile, if defined.
+	RoleARN string `json:"roleArn,omitempty"`
+	// S3 Bucket to use for Lambda source
+	S3Bucket string `json:"s3Bucket,omitempty"`
+	// Optional suffix appended to the service name to produce the
+	// CloudFormation stack name
+	StackNameSuffix string `json:"stackNameSuffix,omitempty"`
+}
+
+// Returns the CloudFormation stack name for serviceName in this target
+func (target *DeploymentTarget) stackName(serviceName string) string {
+	return fmt.Sprintf("%s%s", serviceName, target.StackNameSuffix)
+}
+
+// Returns a name suitable for identifying this target in log output
+func (target *DeploymentTarget) displayName(serviceName string) string {
+	if "" != target.Name {
+		return target.Name
+	}
+	region := target.Region
+	if "" == region {
+		region = "default"
+	}
+	return fmt.Sprintf("%s (%s)", target.stackName(serviceName), region)
+}
+
+// Returns an AWS Session scoped to this target's region and credentials
+func (target *DeploymentTarget) awsSession(logger *logrus.Logger) (*session.Session, error) {
+	if "" == target.Region && "" == target.Profile && "" == target.RoleARN {
+		return awsSession(logger), nil
+	}
+	config := aws.Config{}
+	if "" != target.Region {
+		config.Region = aws.String(target.Region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           target.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if nil != err {
+		return nil, err
+	}
+	if "" != target.RoleARN {
+		sess = sess.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(sess, target.RoleARN),
+		})
+	}
+	return logAWSRequests(sess, logger), nil
+}
+
+// LoadDeploymentTargets returns the DeploymentTarget values defined in the
+// JSON targets file at path.
+func LoadDeploymentTargets(path string) ([]*DeploymentTarget, error) {
+	contents, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to read targets file %s: %s", path, err.Error())
+	}
+	var targets []*DeploymentTarget
+	err = json.Unmarshal(contents, &targets)
+	if nil != err {
+		return nil, fmt.Errorf("Failed to parse targets file %s: %s", path, err.Error())
+	}
+	if len(targets) <= 0 {
+		return nil, fmt.Errorf("No deployment targets defined in %s", path)
+	}
+	return targets, nil
+}
+
+// Verify that the target's S3 bucket is in the same region as the target,
+// since AWS Lambda only accepts code ZIPs from regional buckets.
+func verifyTargetBucketRegion(target *DeploymentTarget, awsSession *session.Session) error {
+	sessionRegion := aws.StringValue(awsSession.Config.Region)
+	if "" == sessionRegion {
+		return nil
+	}
+	locationOutput, err := s3.New(awsSession).GetBucketLocation(&s3.GetBucketLocationInput{
+		Bucket: aws.String(target.S3Bucket),
+	})
+	if nil != err {
+		return err
+	}
+	// Legacy location constraints
+	bucketRegion := aws.StringValue(locationOutput.LocationConstraint)
+	switch bucketRegion {
+	case "":
+		bucketRegion = "us-east-1"
+	case "EU":
+		bucketRegion = "eu-west-1"
+	}
+	if bucketRegion != sessionRegion {
+		return fmt.Errorf("S3 bucket %s is in region %s, not %s",
+			target.S3Bucket,
+			bucketRegion,
+			sessionRegion)
+	}
+	return nil
+}
+
+// Apply the operation to each target.  A failed target doesn't prevent the
+// operation from being applied to the remaining targets.  The per-target
+// results are logged and any failures are summarized in the returned error.
+func forEachTarget(serviceName string,
+	targets []*DeploymentTarget,
+	operationName string,
+	logger *logrus.Logger,
+	operation func(target *DeploymentTarget, awsSession *session.Session) error) error {
+
+	var failedTargets []string
+	results := make([]error, len(targets))
+	for index, eachTarget := range targets {
+		targetName := eachTarget.displayName(serviceName)
+		logger.WithFields(logrus.Fields{
+			"Target":    targetName,
+			"StackName": eachTarget.stackName(serviceName),
+			"Region":    eachTarget.Region,
+		}).Info(fmt.Sprintf("Starting %s", operationName))
+
+		awsSession, err := eachTarget.awsSession(logger)
+		if nil == err {
+			err = operation(eachTarget, awsSession)
+		}
+		results[index] = err
+		if nil != err {
+			logger.WithFields(logrus.Fields{
+				"Target": targetName,
+				"Error":  err.Error(),
+			}).Error(fmt.Sprintf("Target %s failed", operationName))
+			failedTargets = append(failedTargets, targetName)
+		}
+	}
+
+	logger.Info(fmt.Sprintf("%s results:", strings.Title(operationName)))
+	for index, eachTarget := range targets {
+		status := "SUCCEEDED"
+		if nil != results[index] {
+			status = "FAILED"
+		}
+		logger.WithFields(logrus.Fields{
+			"Target": eachTarget.displayName(serviceName),
+			"Status": status,
+		}).Info("\tTarget")
+	}
+	if len(failedTargets) > 0 {
+		return fmt.Errorf("Failed to %s targets: %s", operationName, strings.Join(failedTargets, ", "))
+	}
+	return nil
+}
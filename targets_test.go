@@ -0,0 +1,37 @@
+package sparta
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoadDeploymentTargets(t *testing.T) {
+	targetsFile, err := ioutil.TempFile("", "SpartaTargets")
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(targetsFile.Name())
+	targetsFile.WriteString(`[
+		{"region": "us-east-1", "s3Bucket": "code-us-east-1"},
+		{"name": "west", "region": "us-west-2", "s3Bucket": "code-us-west-2", "stackNameSuffix": "-west"}
+	]`)
+	targetsFile.Close()
+
+	targets, err := LoadDeploymentTargets(targetsFile.Name())
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if len(targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(targets))
+	}
+	if targets[0].stackName("SampleService") != "SampleService" {
+		t.Errorf("Unexpected stack name: %s", targets[0].stackName("SampleService"))
+	}
+	if targets[1].stackName("SampleService") != "SampleService-west" {
+		t.Errorf("Unexpected stack name: %s", targets[1].stackName("SampleService"))
+	}
+	if targets[1].displayName("SampleService") != "west" {
+		t.Errorf("Unexpected display name: %s", targets[1].displayName("SampleService"))
+	}
+}
@@ -0,0 +1,266 @@
+package sparta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// TransportHTTP forwards each event from the NodeJS proxy to the golang
+	// process via an HTTP request to localhost:9999
+	TransportHTTP = "http"
+	// TransportStdio forwards each event from the NodeJS proxy to the golang
+	// process as a length-prefixed JSON frame over the child process's stdio
+	// pipes.  Requests are written to the child's stdin and responses are
+	// read from file descriptor 3, so that handler and log output written to
+	// stdout and stderr doesn't corrupt the frames.
+	TransportStdio = "stdio"
+	// TransportUnixSocket forwards each event from the NodeJS proxy to the
+	// golang process as a length-prefixed JSON frame over a Unix domain socket
+	TransportUnixSocket = "unix"
+)
+
+// File descriptor of the pipe that TransportStdio responses are written to
+const stdioResponseFD = 3
+
+// ID of the handshake frame that's written once the golang process is
+// serving frames.  The NodeJS proxy's request IDs start at 1.
+const handshakeFrameID = 0
+
+// Maximum frame size.  AWS Lambda limits request and response payloads to 6MB.
+const maxFrameSize = 16 * 1024 * 1024
+
+// Returns an error if transport isn't a supported transport value
+func validateTransport(transport string) error {
+	switch transport {
+	case "", TransportHTTP, TransportStdio, TransportUnixSocket:
+		return nil
+	default:
+		return fmt.Errorf("Unsupported transport: %s. Valid values: %s",
+			transport,
+			strings.Join([]string{TransportHTTP, TransportStdio, TransportUnixSocket}, ", "))
+	}
+}
+
+// Request frame written by the NodeJS proxy
+type frameRequest struct {
+	ID   int64  `json:"id"`
+	Path string `json:"path"`
+	lambdaRequest
+}
+
+// Response frame written by the golang process
+type frameResponse struct {
+	ID      int64             `json:"id"`
+	Code    int               `json:"code"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// Read a single frame: a 4 byte big-endian length followed by the JSON body
+func readFrame(reader io.Reader, value interface{}) error {
+	var frameLength uint32
+	err := binary.Read(reader, binary.BigEndian, &frameLength)
+	if nil != err {
+		return err
+	}
+	if frameLength > maxFrameSize {
+		return fmt.Errorf("Frame size exceeds maximum: %d", frameLength)
+	}
+	frameBody := make([]byte, frameLength)
+	_, err = io.ReadFull(reader, frameBody)
+	if nil != err {
+		return err
+	}
+	return json.Unmarshal(frameBody, value)
+}
+
+// Write value as a single frame
+func writeFrame(writer io.Writer, value interface{}) error {
+	frameBody, err := json.Marshal(value)
+	if nil != err {
+		return err
+	}
+	var frame bytes.Buffer
+	binary.Write(&frame, binary.BigEndian, uint32(len(frameBody)))
+	frame.Write(frameBody)
+	_, err = writer.Write(frame.Bytes())
+	return err
+}
+
+// http.ResponseWriter that accumulates a handler's response for a frame
+type frameResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (writer *frameResponseWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *frameResponseWriter) Write(data []byte) (int, error) {
+	if 0 == writer.code {
+		writer.WriteHeader(http.StatusOK)
+	}
+	return writer.body.Write(data)
+}
+
+func (writer *frameResponseWriter) WriteHeader(code int) {
+	if 0 == writer.code {
+		writer.code = code
+	}
+}
+
+// Dispatch the request and return its response frame
+func (handler *lambdaHandler) dispatchFrame(request *frameRequest) *frameResponse {
+	writer := &frameResponseWriter{
+		header: make(http.Header, 0),
+	}
+	handler.dispatch(request.Path, &request.lambdaRequest, writer)
+	if 0 == writer.code {
+		writer.code = http.StatusOK
+	}
+	// Match the net/http Content-Type sniffing
+	if "" == writer.header.Get("Content-Type") && writer.body.Len() > 0 {
+		writer.header.Set("Content-Type", http.DetectContentType(writer.body.Bytes()))
+	}
+	// Match the lowercase header names of NodeJS HTTP responses
+	headers := make(map[string]string, len(writer.header))
+	for eachKey, eachValues := range writer.header {
+		headers[strings.ToLower(eachKey)] = strings.Join(eachValues, ", ")
+	}
+	return &frameResponse{
+		ID:      request.ID,
+		Code:    writer.code,
+		Headers: headers,
+		Body:    writer.body.String(),
+	}
+}
+
+// Write the handshake frame, then read request frames from reader until EOF
+// and write each response frame to writer.  Requests are dispatched
+// concurrently.
+func (handler *lambdaHandler) serveFrames(reader io.Reader, writer io.Writer) error {
+	var writerMutex sync.Mutex
+	var dispatchGroup sync.WaitGroup
+	defer dispatchGroup.Wait()
+
+	// The NodeJS proxy records the process as ready once the handshake arrives
+	err := writeFrame(writer, &frameResponse{
+		ID:   handshakeFrameID,
+		Code: http.StatusOK,
+	})
+	if nil != err {
+		return err
+	}
+
+	for {
+		request := &frameRequest{}
+		err := readFrame(reader, request)
+		if io.EOF == err {
+			return nil
+		}
+		if nil != err {
+			return err
+		}
+		dispatchGroup.Add(1)
+		go func(request *frameRequest) {
+			defer dispatchGroup.Done()
+			writeResponse := func(response *frameResponse) {
+				writerMutex.Lock()
+				defer writerMutex.Unlock()
+				err := writeFrame(writer, response)
+				if nil != err {
+					handler.logger.Error("Failed to write response frame: ", err.Error())
+				}
+			}
+			// A panicking handler fails its own request rather than the process
+			defer func() {
+				if recovered := recover(); nil != recovered {
+					handler.logger.WithFields(logrus.Fields{
+						"ID":    request.ID,
+						"Path":  request.Path,
+						"Panic": recovered,
+						"Stack": string(debug.Stack()),
+					}).Error("Request handler panicked")
+					writeResponse(&frameResponse{
+						ID:   request.ID,
+						Code: http.StatusInternalServerError,
+						Headers: map[string]string{
+							"content-type": "text/plain; charset=utf-8",
+						},
+						Body: fmt.Sprintf("%v", recovered),
+					})
+				}
+			}()
+			writeResponse(handler.dispatchFrame(request))
+		}(request)
+	}
+}
+
+// Serve frames over the stdio pipes until stdin is closed
+func executeStdio(handler *lambdaHandler, logger *logrus.Logger) error {
+	responseWriter := os.NewFile(stdioResponseFD, "responses")
+	if nil == responseWriter {
+		return errors.New("Response pipe is not available")
+	}
+	defer responseWriter.Close()
+	logger.Debug("Reading request frames from stdin")
+	return handler.serveFrames(os.Stdin, responseWriter)
+}
+
+// Serve frames over each connection to the Unix domain socket
+func executeUnixSocket(handler *lambdaHandler, socketPath string, parentProcessPID int, logger *logrus.Logger) error {
+	if "" == socketPath {
+		return errors.New("Unix domain socket path is required")
+	}
+	// Remove the socket left by a previous process
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if nil != err {
+		logger.Error("FAILURE: " + err.Error())
+		return err
+	}
+	defer listener.Close()
+	if 0 != parentProcessPID {
+		logger.Debug("Sending SIGUSR2 to parent process: ", parentProcessPID)
+		syscall.Kill(parentProcessPID, syscall.SIGUSR2)
+	}
+	logger.Debug("Listening on socket: ", socketPath)
+	for {
+		conn, err := listener.Accept()
+		if nil != err {
+			logger.Error("FAILURE: " + err.Error())
+			return err
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			defer func() {
+				if recovered := recover(); nil != recovered {
+					logger.WithFields(logrus.Fields{
+						"Panic": recovered,
+						"Stack": string(debug.Stack()),
+					}).Error("Unix domain socket connection panicked")
+				}
+			}()
+			err := handler.serveFrames(conn, conn)
+			if nil != err {
+				logger.Error("Failed to read request frame: ", err.Error())
+			}
+		}(conn)
+	}
+}
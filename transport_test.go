@@ -0,0 +1,104 @@
+package sparta
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func mockPanicLambda(event *json.RawMessage, context *LambdaContext, w http.ResponseWriter, logger *logrus.Logger) {
+	panic("mockPanicLambda!")
+}
+
+func TestServeFrames(t *testing.T) {
+	logger, err := NewLogger("info")
+	lookupMap := make(dispatchMap, 0)
+	for _, eachLambda := range testLambdaData() {
+		lookupMap[eachLambda.lambdaFnName] = eachLambda
+	}
+	panicLambda := NewLambda(LambdaExecuteARN, mockPanicLambda, nil)
+	lookupMap[panicLambda.lambdaFnName] = panicLambda
+	SetMetricsSink(&MemoryMetricsSink{})
+	defer SetMetricsSink(nil)
+	handler := &lambdaHandler{
+		lambdaDispatchMap: lookupMap,
+		config:            make(LambdaConfig, 0),
+		logger:            logger,
+	}
+
+	var requests bytes.Buffer
+	paths := []string{
+		"/github.com/mweagle/Sparta.mockLambda1",
+		"/github.com/mweagle/Sparta.mockLambda2",
+		"/undefined",
+		"/github.com/mweagle/Sparta.mockPanicLambda",
+	}
+	for index, eachPath := range paths {
+		err = writeFrame(&requests, &frameRequest{
+			ID:   int64(index + 1),
+			Path: eachPath,
+			lambdaRequest: lambdaRequest{
+				Event: json.RawMessage(`{}`),
+			},
+		})
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+	}
+	var responses bytes.Buffer
+	err = handler.serveFrames(&requests, &responses)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+
+	handshake := &frameResponse{}
+	err = readFrame(&responses, handshake)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if handshake.ID != handshakeFrameID {
+		t.Errorf("Expected handshake frame first: %#v", handshake)
+	}
+	results := make(map[int64]*frameResponse, 0)
+	for {
+		response := &frameResponse{}
+		err = readFrame(&responses, response)
+		if io.EOF == err {
+			break
+		}
+		if nil != err {
+			t.Fatal(err.Error())
+		}
+		results[response.ID] = response
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("Expected %d responses, got: %d", len(paths), len(results))
+	}
+	if results[1].Code != http.StatusOK || results[1].Body != "mockLambda1!" {
+		t.Errorf("Unexpected response: %#v", results[1])
+	}
+	if results[2].Code != http.StatusOK || results[2].Body != "mockLambda2!" {
+		t.Errorf("Unexpected response: %#v", results[2])
+	}
+	if results[3].Code != http.StatusBadRequest {
+		t.Errorf("Expected unsupported path to fail: %#v", results[3])
+	}
+	if results[4].Code != http.StatusInternalServerError || results[4].Body != "mockPanicLambda!" {
+		t.Errorf("Expected panicking handler to fail its request: %#v", results[4])
+	}
+}
+
+func TestValidateTransport(t *testing.T) {
+	for _, eachTransport := range []string{"", TransportHTTP, TransportStdio, TransportUnixSocket} {
+		if nil != validateTransport(eachTransport) {
+			t.Errorf("Expected transport to be valid: %s", eachTransport)
+		}
+	}
+	if nil == validateTransport("tcp") {
+		t.Error("Expected unsupported transport to be invalid")
+	}
+}
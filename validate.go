@@ -0,0 +1,226 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Placeholder S3 key used when validating a template without
+// building the code archive
+const validateS3KeyPlaceholder = "{{SpartaValidateS3Key}}"
+
+// CloudFormation resource specification
+// (http://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/cfn-resource-specification.html)
+// bundled in resources/cloudformation/spec.json.  `go generate` refreshes
+// the bundled copy from the published specification.
+type resourceSpecification struct {
+	ResourceTypes map[string]*resourceTypeSpecification
+}
+
+type resourceTypeSpecification struct {
+	Attributes map[string]interface{}
+	Properties map[string]*propertySpecification
+}
+
+type propertySpecification struct {
+	Required bool
+}
+
+var cachedResourceSpecification *resourceSpecification
+
+// Returns the bundled CloudFormation resource specification
+func cloudFormationResourceSpecification() (*resourceSpecification, error) {
+	if nil == cachedResourceSpecification {
+		var spec resourceSpecification
+		err := json.Unmarshal(escFSMustByte(false, "/resources/cloudformation/spec.json"), &spec)
+		if nil != err {
+			return nil, fmt.Errorf("Failed to parse CloudFormation resource specification: %s", err.Error())
+		}
+		cachedResourceSpecification = &spec
+	}
+	return cachedResourceSpecification, nil
+}
+
+// Returns true if the resource type accepts arbitrary properties
+// and attributes
+func isCustomResourceType(resourceType string) bool {
+	return resourceType == "AWS::CloudFormation::CustomResource" ||
+		strings.HasPrefix(resourceType, "Custom::")
+}
+
+// Validate the template's resources against the resource specification and
+// verify that all Ref, Fn::GetAtt and DependsOn values resolve to defined
+// logical IDs.  Returns the validation errors.
+func validateTemplate(template map[string]interface{}, logger *logrus.Logger) ([]error, error) {
+	spec, err := cloudFormationResourceSpecification()
+	if nil != err {
+		return nil, err
+	}
+	resources, _ := template["Resources"].(map[string]interface{})
+	parameters, _ := template["Parameters"].(map[string]interface{})
+
+	var validationErrors []error
+	addError := func(source string, format string, args ...interface{}) {
+		validationErrors = append(validationErrors,
+			fmt.Errorf("%s: %s", source, fmt.Sprintf(format, args...)))
+	}
+	resourceType := func(logicalID string) string {
+		resource, _ := resources[logicalID].(map[string]interface{})
+		typeName, _ := resource["Type"].(string)
+		return typeName
+	}
+	// Returns a resolve function that validates references made by source
+	referenceValidator := func(source string) func(string, string) interface{} {
+		return func(logicalID string, attribute string) interface{} {
+			_, isResource := resources[logicalID]
+			if "" == attribute {
+				_, isParameter := parameters[logicalID]
+				if !isResource && !isParameter && !strings.HasPrefix(logicalID, "AWS::") {
+					addError(source, "Ref to undefined logical ID %s", logicalID)
+				}
+				return nil
+			}
+			if !isResource {
+				addError(source, "Fn::GetAtt to undefined resource %s", logicalID)
+				return nil
+			}
+			targetType := resourceType(logicalID)
+			typeSpec, known := spec.ResourceTypes[targetType]
+			switch {
+			case !known || isCustomResourceType(targetType):
+				// Not validated
+			case targetType == "AWS::CloudFormation::Stack" && strings.HasPrefix(attribute, "Outputs."):
+				// Nested stack outputs aren't known until the nested stack is created
+			default:
+				if _, exists := typeSpec.Attributes[attribute]; !exists {
+					addError(source, "Fn::GetAtt to undefined attribute %s of %s (%s)", attribute, logicalID, targetType)
+				}
+			}
+			return nil
+		}
+	}
+
+	// Sort the logical IDs s.t. the errors are reported in a stable order
+	var logicalIDs []string
+	for eachKey := range resources {
+		logicalIDs = append(logicalIDs, eachKey)
+	}
+	sort.Strings(logicalIDs)
+
+	for _, eachLogicalID := range logicalIDs {
+		source := fmt.Sprintf("Resources.%s", eachLogicalID)
+		resource, ok := resources[eachLogicalID].(map[string]interface{})
+		if !ok {
+			addError(source, "Resource must be an object")
+			continue
+		}
+		typeName := resourceType(eachLogicalID)
+		properties, _ := resource["Properties"].(map[string]interface{})
+		typeSpec, known := spec.ResourceTypes[typeName]
+		switch {
+		case "" == typeName:
+			addError(source, "Resource Type is not defined")
+		case isCustomResourceType(typeName):
+			if _, exists := properties["ServiceToken"]; !exists {
+				addError(source, "Required property ServiceToken is not defined")
+			}
+		case !known:
+			logger.WithFields(logrus.Fields{
+				"Resource": eachLogicalID,
+				"Type":     typeName,
+			}).Warn("Resource type not in bundled CloudFormation specification. Properties not validated.")
+		default:
+			for eachProperty := range properties {
+				if _, exists := typeSpec.Properties[eachProperty]; !exists {
+					addError(source, "Unknown property %s for %s", eachProperty, typeName)
+				}
+			}
+			for eachProperty, eachPropertySpec := range typeSpec.Properties {
+				if _, exists := properties[eachProperty]; eachPropertySpec.Required && !exists {
+					addError(source, "Required property %s is not defined", eachProperty)
+				}
+			}
+		}
+		for _, eachDependency := range resourceDependencies(resource) {
+			if _, exists := resources[eachDependency]; !exists {
+				addError(source, "DependsOn undefined resource %s", eachDependency)
+			}
+		}
+		rewriteReferences(resource, referenceValidator(source))
+	}
+	if outputs, ok := template["Outputs"].(map[string]interface{}); ok {
+		for eachKey, eachOutput := range outputs {
+			rewriteReferences(eachOutput, referenceValidator(fmt.Sprintf("Outputs.%s", eachKey)))
+		}
+	}
+	sort.Sort(errorsByMessage(validationErrors))
+	return validationErrors, nil
+}
+
+type errorsByMessage []error
+
+func (errs errorsByMessage) Len() int           { return len(errs) }
+func (errs errorsByMessage) Swap(i, j int)      { errs[i], errs[j] = errs[j], errs[i] }
+func (errs errorsByMessage) Less(i, j int) bool { return errs[i].Error() < errs[j].Error() }
+
+// Workflow step that validates the CloudFormation template
+func validateCloudFormationTemplate(ctx *workflowContext, cloudFormationTemplate ArbitraryJSONObject) error {
+	template, err := normalizedJSON(cloudFormationTemplate)
+	if nil != err {
+		return err
+	}
+	validationErrors, err := validateTemplate(template, ctx.logger)
+	if nil != err {
+		return err
+	}
+	if len(validationErrors) > 0 {
+		for _, eachError := range validationErrors {
+			ctx.logger.Error("\t", eachError.Error())
+		}
+		return fmt.Errorf("CloudFormation template validation failed. Errors: %d", len(validationErrors))
+	}
+	ctx.logger.WithFields(logrus.Fields{
+		"ResourceCount": len(ctx.cloudformationResources),
+	}).Info("CloudFormation template validated")
+	return nil
+}
+
+// Validate builds the CloudFormation template for the service and validates
+// each resource against the bundled CloudFormation resource specification
+// (types, required properties, attribute names).  It also verifies that every
+// Ref, Fn::GetAtt and DependsOn value resolves to a defined logical ID.  No
+// AWS API calls are made and the code archive is not built.  Provision()
+// applies the same validation before uploading the template.
+func Validate(serviceName string,
+	serviceDescription string,
+	lambdaAWSInfos []*LambdaAWSInfo,
+	api *API,
+	options *ProvisionOptions,
+	logger *logrus.Logger) error {
+
+	if len(lambdaAWSInfos) <= 0 {
+		return errors.New("No lambda functions provided to Sparta.Validate()")
+	}
+	ctx := &workflowContext{
+		validateOnly:            true,
+		serviceName:             serviceName,
+		stackName:               serviceName,
+		serviceDescription:      serviceDescription,
+		lambdaAWSInfos:          lambdaAWSInfos,
+		api:                     api,
+		cloudformationResources: make(ArbitraryJSONObject, 0),
+		cloudformationOutputs:   make(ArbitraryJSONObject, 0),
+		s3Bucket:                PackageS3BucketPlaceholder,
+		options:                 options,
+		awsSession:              awsSession(logger),
+		logger:                  logger,
+	}
+	return runWorkflow(ctx)
+}
@@ -0,0 +1,119 @@
+package sparta
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func invalidTemplateDecorator(lambdaResourceName string,
+	lambdaResourceDefinition ArbitraryJSONObject,
+	resources ArbitraryJSONObject,
+	outputs ArbitraryJSONObject,
+	logger *logrus.Logger) error {
+
+	resources["InvalidTopic"] = ArbitraryJSONObject{
+		"Type": "AWS::SNS::Topic",
+		"Properties": ArbitraryJSONObject{
+			"TopicNam": "misspelled",
+		},
+		"DependsOn": []string{"UndefinedResource"},
+	}
+	resources["InvalidAlarm"] = ArbitraryJSONObject{
+		"Type": "AWS::CloudWatch::Alarm",
+		"Properties": ArbitraryJSONObject{
+			"ComparisonOperator": "GreaterThanThreshold",
+			"EvaluationPeriods":  "1",
+			"AlarmActions": []interface{}{ArbitraryJSONObject{
+				"Fn::GetAtt": []string{"InvalidTopic", "Arn"},
+			}},
+		},
+	}
+	outputs["InvalidOutput"] = ArbitraryJSONObject{
+		"Value": ArbitraryJSONObject{
+			"Ref": "UndefinedOutputResource",
+		},
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdas := testLambdaData()
+	lambdas[0].Decorator = templateDecorator
+	err = Validate("SampleProvision", "", lambdas, nil, nil, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestValidateTemplateErrors(t *testing.T) {
+	logger, err := NewLogger("info")
+	resources := make(ArbitraryJSONObject, 0)
+	outputs := make(ArbitraryJSONObject, 0)
+	invalidTemplateDecorator("", nil, resources, outputs, logger)
+	template, err := normalizedJSON(ArbitraryJSONObject{
+		"Resources": resources,
+		"Outputs":   outputs,
+	})
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	validationErrors, err := validateTemplate(template, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	expected := []string{
+		"Resources.InvalidAlarm: Required property Threshold is not defined",
+		"Resources.InvalidAlarm: Fn::GetAtt to undefined attribute Arn of InvalidTopic (AWS::SNS::Topic)",
+		"Resources.InvalidTopic: Unknown property TopicNam for AWS::SNS::Topic",
+		"Resources.InvalidTopic: DependsOn undefined resource UndefinedResource",
+		"Outputs.InvalidOutput: Ref to undefined logical ID UndefinedOutputResource",
+	}
+	var messages []string
+	for _, eachError := range validationErrors {
+		messages = append(messages, eachError.Error())
+	}
+	if len(messages) != len(expected) {
+		t.Errorf("Expected %d validation errors, got: %s", len(expected), strings.Join(messages, "\n"))
+	}
+	for _, eachMessage := range expected {
+		found := false
+		for _, eachActual := range messages {
+			found = found || eachActual == eachMessage
+		}
+		if !found {
+			t.Errorf("Missing validation error: %s", eachMessage)
+		}
+	}
+}
+
+func TestVerifyIAMRolesNoop(t *testing.T) {
+	logger, _ := NewLogger("info")
+	lambdas := testLambdaData()
+	lambdas[0].RoleName = "PreexistingRole"
+	lambdas[0].RoleDefinition = nil
+	// GetRole would fail against the unreachable endpoint
+	ctx := &workflowContext{
+		noop:           true,
+		lambdaAWSInfos: lambdas[0:1],
+		cloudformationResources: make(ArbitraryJSONObject, 0),
+		awsSession: session.New(&aws.Config{
+			Region:     aws.String("us-east-1"),
+			Endpoint:   aws.String("http://127.0.0.1:1"),
+			MaxRetries: aws.Int(0),
+		}),
+		logger: logger,
+	}
+	_, err := verifyIAMRoles(ctx)
+	if nil != err {
+		t.Fatalf("Expected noop IAM role verification to be offline: %s", err.Error())
+	}
+	if !reflect.DeepEqual(ctx.lambdaIAMRoleNameMap["PreexistingRole"], iamRoleArn("PreexistingRole")) {
+		t.Errorf("Unexpected role ARN: %v", ctx.lambdaIAMRoleNameMap["PreexistingRole"])
+	}
+}
@@ -0,0 +1,357 @@
+// +build !lambdabinary
+
+package sparta
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// Maximum time between rollback alarm checks during a traffic shift
+const trafficShiftPollInterval = 30 * time.Second
+
+// Pending alias traffic shift for a versioned lambda
+type trafficShift struct {
+	info *LambdaAWSInfo
+	// Physical name of the function
+	functionName string
+	// Logical ID of the version resource that receives the traffic
+	versionLogicalID string
+	// Version that the alias currently routes to
+	previousVersion string
+	// Version published by the stack update
+	newVersion string
+	// Template properties of the alias and the version they route to once
+	// the shift completes
+	aliasProperties ArbitraryJSONObject
+	functionVersion ArbitraryJSONObject
+}
+
+// Returns the logical ID -> physical ID map for every resource in the stack,
+// including resources in nested stacks
+func stackPhysicalResourceIDs(stackName string, cf *cloudformation.CloudFormation) (map[string]string, error) {
+	physicalIDs := make(map[string]string, 0)
+	var nestedStackIDs []string
+	params := &cloudformation.ListStackResourcesInput{
+		StackName: aws.String(stackName),
+	}
+	err := cf.ListStackResourcesPages(params, func(page *cloudformation.ListStackResourcesOutput, lastPage bool) bool {
+		for _, eachSummary := range page.StackResourceSummaries {
+			physicalID := aws.StringValue(eachSummary.PhysicalResourceId)
+			if "" == physicalID {
+				continue
+			}
+			physicalIDs[aws.StringValue(eachSummary.LogicalResourceId)] = physicalID
+			if aws.StringValue(eachSummary.ResourceType) == "AWS::CloudFormation::Stack" {
+				nestedStackIDs = append(nestedStackIDs, physicalID)
+			}
+		}
+		return true
+	})
+	if nil != err {
+		return nil, err
+	}
+	for _, eachNestedStackID := range nestedStackIDs {
+		nestedPhysicalIDs, err := stackPhysicalResourceIDs(eachNestedStackID, cf)
+		if nil != err {
+			return nil, err
+		}
+		for eachKey, eachValue := range nestedPhysicalIDs {
+			physicalIDs[eachKey] = eachValue
+		}
+	}
+	return physicalIDs, nil
+}
+
+// Returns the version number from an AWS::Lambda::Version physical ID (ARN)
+func lambdaVersionNumber(versionARN string) string {
+	return versionARN[strings.LastIndex(versionARN, ":")+1:]
+}
+
+// Returns the traffic shifts required by the update and rewrites each
+// shifted alias resource s.t. the update initially routes the first step's
+// weight to the new version.  New stacks, new aliases and aliases that
+// already route to the current version are not shifted.  An alias that
+// was previously rolled back is shifted again.
+func prepareTrafficShifts(ctx *workflowContext) ([]*trafficShift, error) {
+	var shiftedLambdas []*LambdaAWSInfo
+	for _, eachLambda := range ctx.lambdaAWSInfos {
+		if nil != eachLambda.Versioning && len(eachLambda.Versioning.TrafficShift) > 0 {
+			shiftedLambdas = append(shiftedLambdas, eachLambda)
+		}
+	}
+	if len(shiftedLambdas) <= 0 {
+		return nil, nil
+	}
+	awsCloudFormation := cloudformation.New(ctx.awsSession)
+	exists, err := stackExists(ctx.stackName, awsCloudFormation, ctx.logger)
+	if nil != err || !exists {
+		return nil, err
+	}
+	physicalIDs, err := stackPhysicalResourceIDs(ctx.stackName, awsCloudFormation)
+	if nil != err {
+		return nil, err
+	}
+	lambdaSvc := lambda.New(ctx.awsSession)
+
+	var shifts []*trafficShift
+	for _, eachLambda := range shiftedLambdas {
+		aliasResource, _ := ctx.cloudformationResources[eachLambda.aliasLogicalName()].(ArbitraryJSONObject)
+		aliasProperties, _ := aliasResource["Properties"].(ArbitraryJSONObject)
+		functionVersion, _ := aliasProperties["FunctionVersion"].(ArbitraryJSONObject)
+		versionAttr, _ := functionVersion["Fn::GetAtt"].([]string)
+		if len(versionAttr) <= 0 {
+			return nil, fmt.Errorf("Failed to find version resource for %s", eachLambda.lambdaFnName)
+		}
+		shift := &trafficShift{
+			info:             eachLambda,
+			functionName:     physicalIDs[eachLambda.logicalName()],
+			versionLogicalID: versionAttr[0],
+			aliasProperties:  aliasProperties,
+			functionVersion:  functionVersion,
+		}
+		_, aliasExists := physicalIDs[eachLambda.aliasLogicalName()]
+		if "" == shift.functionName || !aliasExists {
+			continue
+		}
+		aliasConfig, err := lambdaSvc.GetAlias(&lambda.GetAliasInput{
+			FunctionName: aws.String(shift.functionName),
+			Name:         aws.String(eachLambda.aliasName()),
+		})
+		if nil != err {
+			return nil, err
+		}
+		shift.previousVersion = aws.StringValue(aliasConfig.FunctionVersion)
+		if versionARN, exists := physicalIDs[shift.versionLogicalID]; exists &&
+			lambdaVersionNumber(versionARN) == shift.previousVersion {
+			continue
+		}
+		firstStep := eachLambda.Versioning.TrafficShift[0]
+		aliasProperties["FunctionVersion"] = shift.previousVersion
+		aliasProperties["RoutingConfig"] = ArbitraryJSONObject{
+			"AdditionalVersionWeights": []ArbitraryJSONObject{
+				ArbitraryJSONObject{
+					"FunctionVersion": functionVersion,
+					"FunctionWeight":  firstStep.Percentage / 100,
+				},
+			},
+		}
+		ctx.logger.WithFields(logrus.Fields{
+			"Lambda":          eachLambda.lambdaFnName,
+			"Alias":           eachLambda.aliasName(),
+			"PreviousVersion": shift.previousVersion,
+			"Percentage":      firstStep.Percentage,
+		}).Info("Shifting alias traffic to new version")
+		shifts = append(shifts, shift)
+	}
+	return shifts, nil
+}
+
+// Alias routing subset of the Lambda API
+type aliasUpdater interface {
+	UpdateAlias(*lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error)
+}
+
+// Alarm state subset of the CloudWatch API
+type alarmsDescriber interface {
+	DescribeAlarms(*cloudwatch.DescribeAlarmsInput) (*cloudwatch.DescribeAlarmsOutput, error)
+}
+
+// Returns the names of the alarms that are in the ALARM state
+func triggeredAlarms(alarmNames []string, cw alarmsDescriber) ([]string, error) {
+	if len(alarmNames) <= 0 {
+		return nil, nil
+	}
+	describeAlarmsOutput, err := cw.DescribeAlarms(&cloudwatch.DescribeAlarmsInput{
+		AlarmNames: aws.StringSlice(alarmNames),
+		StateValue: aws.String(cloudwatch.StateValueAlarm),
+	})
+	if nil != err {
+		return nil, err
+	}
+	var triggered []string
+	for _, eachAlarm := range describeAlarmsOutput.MetricAlarms {
+		triggered = append(triggered, aws.StringValue(eachAlarm.AlarmName))
+	}
+	return triggered, nil
+}
+
+// Route the alias to functionVersion, with an optional weight for additionalVersion
+func updateAliasRouting(shift *trafficShift,
+	functionVersion string,
+	additionalVersion string,
+	weight float64,
+	lambdaSvc aliasUpdater) error {
+
+	weights := make(map[string]*float64, 0)
+	if "" != additionalVersion {
+		weights[additionalVersion] = aws.Float64(weight)
+	}
+	_, err := lambdaSvc.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    aws.String(shift.functionName),
+		Name:            aws.String(shift.info.aliasName()),
+		FunctionVersion: aws.String(functionVersion),
+		RoutingConfig: &lambda.AliasRoutingConfiguration{
+			AdditionalVersionWeights: weights,
+		},
+	})
+	return err
+}
+
+// Apply the traffic shift schedule to the provisioned alias.  The shift
+// stops early if any of the rollback alarms are triggered or the cancel
+// channel is closed.
+func shiftAliasTraffic(ctx *workflowContext,
+	shift *trafficShift,
+	cancel <-chan struct{},
+	lambdaSvc aliasUpdater,
+	cw alarmsDescriber) error {
+
+	versioning := shift.info.Versioning
+	for index, eachStep := range versioning.TrafficShift {
+		// The first step's weight is applied by the stack update
+		if index > 0 {
+			err := updateAliasRouting(shift, shift.previousVersion, shift.newVersion, eachStep.Percentage/100, lambdaSvc)
+			if nil != err {
+				return err
+			}
+		}
+		ctx.logger.WithFields(logrus.Fields{
+			"Lambda":     shift.info.lambdaFnName,
+			"Alias":      shift.info.aliasName(),
+			"Version":    shift.newVersion,
+			"Percentage": eachStep.Percentage,
+			"Interval":   eachStep.Interval.String(),
+		}).Info("Alias traffic shifted")
+
+		deadline := time.Now().Add(eachStep.Interval)
+		for {
+			triggered, err := triggeredAlarms(versioning.RollbackAlarms, cw)
+			if nil != err {
+				return err
+			}
+			if len(triggered) > 0 {
+				return fmt.Errorf("Rollback alarm triggered for %s alias %s. Alarms: %s",
+					shift.info.lambdaFnName,
+					shift.info.aliasName(),
+					strings.Join(triggered, ", "))
+			}
+			remaining := deadline.Sub(time.Now())
+			if remaining <= 0 {
+				break
+			}
+			if remaining > trafficShiftPollInterval {
+				remaining = trafficShiftPollInterval
+			}
+			select {
+			case <-cancel:
+				return fmt.Errorf("Traffic shift for %s alias %s cancelled",
+					shift.info.lambdaFnName,
+					shift.info.aliasName())
+			case <-time.After(remaining):
+			}
+		}
+	}
+	return nil
+}
+
+// Concurrently apply the traffic shift schedules.  If any shift fails,
+// the remaining shifts are cancelled and every alias is rolled back to its
+// previous version.  Otherwise every alias is routed to its new version.
+func shiftAliasesTraffic(ctx *workflowContext, shifts []*trafficShift) error {
+	if len(shifts) <= 0 {
+		return nil
+	}
+	physicalIDs, err := stackPhysicalResourceIDs(ctx.stackName, cloudformation.New(ctx.awsSession))
+	if nil != err {
+		return err
+	}
+	for _, eachShift := range shifts {
+		versionARN, exists := physicalIDs[eachShift.versionLogicalID]
+		if !exists {
+			return fmt.Errorf("Failed to find provisioned version for %s", eachShift.info.lambdaFnName)
+		}
+		eachShift.newVersion = lambdaVersionNumber(versionARN)
+	}
+	return applyTrafficShifts(ctx, shifts, lambda.New(ctx.awsSession), cloudwatch.New(ctx.awsSession))
+}
+
+// Run the traffic shifts, rolling back every alias if any shift fails
+func applyTrafficShifts(ctx *workflowContext,
+	shifts []*trafficShift,
+	lambdaSvc aliasUpdater,
+	cw alarmsDescriber) error {
+
+	cancel := make(chan struct{})
+	var cancelOnce sync.Once
+	shiftErrors := make([]error, len(shifts))
+	var wg sync.WaitGroup
+	for index, eachShift := range shifts {
+		wg.Add(1)
+		go func(index int, shift *trafficShift) {
+			defer wg.Done()
+			shiftErrors[index] = shiftAliasTraffic(ctx, shift, cancel, lambdaSvc, cw)
+			if nil != shiftErrors[index] {
+				cancelOnce.Do(func() {
+					close(cancel)
+				})
+			}
+		}(index, eachShift)
+	}
+	wg.Wait()
+
+	var errorMessages []string
+	for _, eachError := range shiftErrors {
+		if nil != eachError {
+			errorMessages = append(errorMessages, eachError.Error())
+		}
+	}
+	if len(errorMessages) <= 0 {
+		for _, eachShift := range shifts {
+			err := updateAliasRouting(eachShift, eachShift.newVersion, "", 0, lambdaSvc)
+			if nil != err {
+				errorMessages = append(errorMessages, err.Error())
+				break
+			}
+		}
+	}
+	if len(errorMessages) > 0 {
+		for _, eachShift := range shifts {
+			ctx.logger.WithFields(logrus.Fields{
+				"Lambda":  eachShift.info.lambdaFnName,
+				"Alias":   eachShift.info.aliasName(),
+				"Version": eachShift.previousVersion,
+			}).Warn("Rolling back alias")
+			rollbackErr := updateAliasRouting(eachShift, eachShift.previousVersion, "", 0, lambdaSvc)
+			if nil != rollbackErr {
+				errorMessages = append(errorMessages, rollbackErr.Error())
+			}
+		}
+		return fmt.Errorf("Alias traffic shift failed: %s", strings.Join(errorMessages, "; "))
+	}
+	for _, eachShift := range shifts {
+		ctx.logger.WithFields(logrus.Fields{
+			"Lambda":  eachShift.info.lambdaFnName,
+			"Alias":   eachShift.info.aliasName(),
+			"Version": eachShift.newVersion,
+		}).Info("Alias traffic shift complete")
+	}
+	return nil
+}
+
+// Restore the template's alias properties s.t. each alias routes to the
+// version published by the stack update
+func completeTrafficShifts(shifts []*trafficShift) {
+	for _, eachShift := range shifts {
+		eachShift.aliasProperties["FunctionVersion"] = eachShift.functionVersion
+		delete(eachShift.aliasProperties, "RoutingConfig")
+	}
+}
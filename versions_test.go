@@ -0,0 +1,206 @@
+package sparta
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+func testVersionedLambdaData() []*LambdaAWSInfo {
+	lambdas := testLambdaData()
+	lambdas[0].Versioning = &LambdaVersionOptions{
+		TrafficShift: []TrafficShiftStep{
+			{
+				Percentage: 10,
+				Interval:   5 * time.Minute,
+			},
+			{
+				Percentage: 50,
+				Interval:   5 * time.Minute,
+			},
+		},
+		RollbackAlarms: []string{"SampleErrorAlarm"},
+	}
+	return lambdas
+}
+
+func TestVersionedLambdaExport(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdaFn := testVersionedLambdaData()[0]
+	resources := make(ArbitraryJSONObject, 0)
+	outputs := make(ArbitraryJSONObject, 0)
+	err = lambdaFn.export("S3Bucket", "S3Key", make(map[string]interface{}, 0), nil, resources, outputs, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	alias, _ := resources[lambdaFn.aliasLogicalName()].(ArbitraryJSONObject)
+	if nil == alias || alias["Type"] != "AWS::Lambda::Alias" {
+		t.Fatalf("Failed to find alias resource %s", lambdaFn.aliasLogicalName())
+	}
+	aliasProperties := alias["Properties"].(ArbitraryJSONObject)
+	if aliasProperties["Name"] != DefaultLambdaAliasName {
+		t.Errorf("Unexpected alias name: %v", aliasProperties["Name"])
+	}
+	versionAttr := aliasProperties["FunctionVersion"].(ArbitraryJSONObject)["Fn::GetAtt"].([]string)
+	version, _ := resources[versionAttr[0]].(ArbitraryJSONObject)
+	if nil == version || version["Type"] != "AWS::Lambda::Version" {
+		t.Fatalf("Failed to find version resource %s", versionAttr[0])
+	}
+
+	// Invokers must target the alias
+	aliasRef := ArbitraryJSONObject{"Ref": lambdaFn.aliasLogicalName()}
+	mappingCount := 0
+	for _, eachResource := range resources {
+		resource := eachResource.(ArbitraryJSONObject)
+		if resource["Type"] != "AWS::Lambda::EventSourceMapping" {
+			continue
+		}
+		mappingCount++
+		functionName := resource["Properties"].(ArbitraryJSONObject)["FunctionName"]
+		if !reflect.DeepEqual(functionName, aliasRef) {
+			t.Errorf("Event source mapping doesn't target the alias: %v", functionName)
+		}
+	}
+	if mappingCount <= 0 {
+		t.Error("Failed to find event source mapping")
+	}
+
+	// New code publishes a new version
+	updatedResources := make(ArbitraryJSONObject, 0)
+	err = lambdaFn.export("S3Bucket", "UpdatedS3Key", make(map[string]interface{}, 0), nil, updatedResources, outputs, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+	if _, exists := updatedResources[versionAttr[0]]; exists {
+		t.Errorf("Expected a new version resource for updated code")
+	}
+}
+
+func TestVersionedLambdaValidate(t *testing.T) {
+	logger, err := NewLogger("info")
+	err = Validate("SampleProvision", "", testVersionedLambdaData(), nil, nil, logger)
+	if nil != err {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestInvalidTrafficShift(t *testing.T) {
+	logger, err := NewLogger("info")
+	lambdas := testVersionedLambdaData()
+	lambdas[0].Versioning.TrafficShift[1].Percentage = 100
+	err = Validate("SampleProvision", "", lambdas, nil, nil, logger)
+	if nil == err {
+		t.Fatal("Expected invalid TrafficShift percentage to fail validation")
+	}
+}
+
+type fakeAliasUpdater struct {
+	mutex   sync.Mutex
+	updates map[string][]string
+}
+
+func (updater *fakeAliasUpdater) UpdateAlias(input *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	updater.mutex.Lock()
+	defer updater.mutex.Unlock()
+	functionName := aws.StringValue(input.FunctionName)
+	updater.updates[functionName] = append(updater.updates[functionName], aws.StringValue(input.FunctionVersion))
+	return &lambda.AliasConfiguration{}, nil
+}
+
+type fakeAlarmsDescriber struct {
+	triggered map[string]bool
+}
+
+func (describer *fakeAlarmsDescriber) DescribeAlarms(input *cloudwatch.DescribeAlarmsInput) (*cloudwatch.DescribeAlarmsOutput, error) {
+	output := &cloudwatch.DescribeAlarmsOutput{}
+	for _, eachName := range input.AlarmNames {
+		if describer.triggered[aws.StringValue(eachName)] {
+			output.MetricAlarms = append(output.MetricAlarms, &cloudwatch.MetricAlarm{
+				AlarmName: eachName,
+			})
+		}
+	}
+	return output, nil
+}
+
+func testTrafficShift(functionName string, alarmName string) *trafficShift {
+	info := testLambdaData()[0]
+	info.Versioning = &LambdaVersionOptions{
+		TrafficShift: []TrafficShiftStep{
+			{
+				Percentage: 10,
+				Interval:   time.Hour,
+			},
+		},
+		RollbackAlarms: []string{alarmName},
+	}
+	return &trafficShift{
+		info:            info,
+		functionName:    functionName,
+		previousVersion: "1",
+		newVersion:      "2",
+	}
+}
+
+func TestApplyTrafficShiftsRollback(t *testing.T) {
+	logger, _ := NewLogger("info")
+	ctx := &workflowContext{
+		logger: logger,
+	}
+	shifts := []*trafficShift{
+		testTrafficShift("Healthy", "HealthyAlarm"),
+		testTrafficShift("Failing", "FailingAlarm"),
+	}
+	updater := &fakeAliasUpdater{
+		updates: make(map[string][]string, 0),
+	}
+	describer := &fakeAlarmsDescriber{
+		triggered: map[string]bool{"FailingAlarm": true},
+	}
+	done := make(chan error)
+	go func() {
+		done <- applyTrafficShifts(ctx, shifts, updater, describer)
+	}()
+	select {
+	case err := <-done:
+		if nil == err {
+			t.Fatal("Expected triggered alarm to fail the traffic shift")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected triggered alarm to cancel the remaining traffic shifts")
+	}
+	for _, eachShift := range shifts {
+		expected := []string{"1"}
+		if !reflect.DeepEqual(expected, updater.updates[eachShift.functionName]) {
+			t.Fatalf("Expected %s alias to be rolled back to %v. Updates: %v",
+				eachShift.functionName,
+				expected,
+				updater.updates[eachShift.functionName])
+		}
+	}
+}
+
+func TestCompleteTrafficShifts(t *testing.T) {
+	functionVersion := ArbitraryJSONObject{
+		"Fn::GetAtt": []string{"VersionResource", "Version"},
+	}
+	aliasProperties := ArbitraryJSONObject{
+		"FunctionVersion": "1",
+		"RoutingConfig":   ArbitraryJSONObject{},
+	}
+	shift := testTrafficShift("Function", "Alarm")
+	shift.aliasProperties = aliasProperties
+	shift.functionVersion = functionVersion
+	completeTrafficShifts([]*trafficShift{shift})
+	if !reflect.DeepEqual(functionVersion, aliasProperties["FunctionVersion"]) {
+		t.Fatalf("Expected alias to route to the new version: %v", aliasProperties["FunctionVersion"])
+	}
+	if _, exists := aliasProperties["RoutingConfig"]; exists {
+		t.Fatal("Expected alias RoutingConfig to be removed")
+	}
+}